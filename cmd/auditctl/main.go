@@ -0,0 +1,294 @@
+// Package main provides a command line tool to query, summarize and replay audit logs
+//
+// Usage:
+//
+//	auditctl [audit flags] query [filter flags] [-output path] [files...]
+//	auditctl [audit flags] summary [filter flags] [-top n] [files...]
+//	auditctl [audit flags] replay [filter flags] [-url url] [files...]
+//
+// Files default to the audit log of -audit-file and its rotated files, compressed ones included.
+// The replay target and its batching, compression and signing use the same flags and
+// environment variables as the server, e.g. -audit-url or -audit-signing-key.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/auditlog"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/config"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+)
+
+// main tool entry point
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "auditctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the configuration and executes the requested subcommand
+func run() error {
+	flag.Usage = usage
+
+	appConfig, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+
+	if flag.NArg() == 0 {
+		usage()
+		return errors.New("missing command")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "query":
+		return runQuery(ctx, appConfig, args)
+	case "summary":
+		return runSummary(ctx, appConfig, args)
+	case "replay":
+		return runReplay(ctx, appConfig, args)
+	}
+
+	usage()
+	return fmt.Errorf("unknown command %q", command)
+}
+
+// runQuery writes the matching events as JSONL to a file or stdout
+func runQuery(ctx context.Context, appConfig *config.Config, args []string) (err error) {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	filter := filterFlags(flags)
+	output := flags.String("output", "", "Output file path, stdout when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, file.Close())
+		}()
+		out = file
+	}
+
+	enc := json.NewEncoder(out)
+	matched := 0
+
+	err = scan(ctx, appConfig, flags, filter, func(event audit.Event) error {
+		matched++
+		return enc.Encode(event)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "matched %d events\n", matched)
+
+	return nil
+}
+
+// runSummary prints event counts per action, URL and user
+func runSummary(ctx context.Context, appConfig *config.Config, args []string) error {
+	flags := flag.NewFlagSet("summary", flag.ContinueOnError)
+	filter := filterFlags(flags)
+	top := flags.Int("top", 10, "Number of URLs and users to print, all when zero")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	summarizer := auditlog.NewSummarizer()
+
+	err := scan(ctx, appConfig, flags, filter, func(event audit.Event) error {
+		summarizer.Add(event)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return printSummary(os.Stdout, summarizer.Summary(*top))
+}
+
+// runReplay sends the matching events to the audit server
+func runReplay(ctx context.Context, appConfig *config.Config, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	filter := filterFlags(flags)
+	url := flags.String("url", appConfig.AuditURL, "Audit server URL")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *url == "" {
+		return errors.New("no audit server configured, set -url or -audit-url")
+	}
+
+	observer, err := audit.NewHTTPObserver(*url, audit.HTTPObserverOptions{
+		BatchSize:     appConfig.AuditBatchSize,
+		FlushInterval: appConfig.AuditFlush,
+		Format:        appConfig.AuditFormat,
+		Gzip:          appConfig.AuditGzip,
+		SigningKey:    appConfig.AuditSigningKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	replayer := auditlog.NewReplayer(observer)
+
+	err = scan(ctx, appConfig, flags, filter, replayer.Add)
+	if err == nil {
+		err = replayer.Flush()
+	}
+
+	fmt.Fprintf(os.Stderr, "replayed %d events\n", replayer.Sent())
+
+	if err != nil {
+		return fmt.Errorf("replay failed: %w", err)
+	}
+
+	return nil
+}
+
+// filterFlags defines the flags selecting events and returns a function building the filter once they are parsed
+func filterFlags(flags *flag.FlagSet) func() (auditlog.Filter, error) {
+	from := flags.String("from", "", "Events recorded at or after the time, RFC 3339, a date or a duration back from now such as 24h")
+	to := flags.String("to", "", "Events recorded before the time, in the format of -from")
+	actions := flags.String("action", "", "Comma separated actions such as shorten,delete")
+	userID := flags.String("user", "", "Events of the user ID")
+	url := flags.String("url-match", "", "Events whose URL contains the value or whose short code equals it")
+
+	return func() (auditlog.Filter, error) {
+		filter := auditlog.Filter{UserID: *userID, URL: *url}
+
+		var err error
+		if filter.From, err = parseTime(*from); err != nil {
+			return filter, fmt.Errorf("invalid -from: %w", err)
+		}
+		if filter.To, err = parseTime(*to); err != nil {
+			return filter, fmt.Errorf("invalid -to: %w", err)
+		}
+
+		for action := range strings.SplitSeq(*actions, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				filter.Actions = append(filter.Actions, action)
+			}
+		}
+
+		return filter, nil
+	}
+}
+
+// scan calls fn for the events matching the filter in the files given as arguments or in the configured audit log
+func scan(ctx context.Context, appConfig *config.Config, flags *flag.FlagSet, buildFilter func() (auditlog.Filter, error), fn func(audit.Event) error) error {
+	filter, err := buildFilter()
+	if err != nil {
+		return err
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		if appConfig.AuditFile == "" {
+			return errors.New("no audit log given, pass files or set -audit-file")
+		}
+
+		if files, err = auditlog.Files(appConfig.AuditFile); err != nil {
+			return err
+		}
+	}
+
+	skipped, err := auditlog.ScanFiles(files, filter, func(event audit.Event) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(event)
+	})
+
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d malformed lines\n", skipped)
+	}
+
+	return err
+}
+
+// parseTime parses an RFC 3339 time, a date or a duration back from now, the zero time when empty
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// printSummary prints the summary as aligned tables
+func printSummary(out io.Writer, summary auditlog.Summary) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Events:\t%d\n", summary.Total)
+	if summary.Total > 0 {
+		fmt.Fprintf(w, "From:\t%s\n", summary.From.UTC().Format(time.RFC3339))
+		fmt.Fprintf(w, "To:\t%s\n", summary.To.UTC().Format(time.RFC3339))
+	}
+
+	printCounts(w, "ACTION", summary.ByAction)
+	printCounts(w, "URL", summary.TopURLs)
+	printCounts(w, "USER", summary.ByUser)
+
+	return w.Flush()
+}
+
+// printCounts prints a table of counts under the heading
+func printCounts(w io.Writer, heading string, counts []auditlog.Count) {
+	if len(counts) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%s\tEVENTS\n", heading)
+	for _, count := range counts {
+		fmt.Fprintf(w, "%s\t%d\n", count.Key, count.Count)
+	}
+}
+
+// usage prints the command line help
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  auditctl [audit flags] query [filter flags] [-output path] [files...]")
+	fmt.Fprintln(out, "  auditctl [audit flags] summary [filter flags] [-top n] [files...]")
+	fmt.Fprintln(out, "  auditctl [audit flags] replay [filter flags] [-url url] [files...]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Filter flags:")
+	fmt.Fprintln(out, "  -from, -to time   RFC 3339 time, date or duration back from now")
+	fmt.Fprintln(out, "  -action list      comma separated actions")
+	fmt.Fprintln(out, "  -user id          user ID")
+	fmt.Fprintln(out, "  -url-match value  URL substring or short code")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Audit flags:")
+	flag.PrintDefaults()
+}
@@ -11,24 +11,35 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/alikhanturusbekov/go-url-shortener/internal/config"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/generator"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/handler"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/policy"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	pb "github.com/alikhanturusbekov/go-url-shortener/pkg/api/shortener"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/compress"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/metrics"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/ratelimit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/requestid"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
 )
 
 const (
@@ -37,6 +48,22 @@ const (
 	readTimeout        = 10 * time.Second
 	writeTimeout       = 10 * time.Second
 	idleTimeout        = 60 * time.Second
+
+	expiredURLsReapInterval = time.Minute
+	fileSyncInterval        = time.Second
+	fileCompactionInterval  = 10 * time.Minute
+	blocklistReloadInterval = 10 * time.Second
+
+	// serviceName identifies the application in traces
+	serviceName = "go-url-shortener"
+)
+
+// Rate limit scopes of the limited routes
+const (
+	rateLimitShorten = "shorten"
+	rateLimitBatch   = "batch"
+	rateLimitResolve = "resolve"
+	rateLimitDelete  = "delete"
 )
 
 var (
@@ -76,6 +103,19 @@ func run() error {
 		return err
 	}
 
+	shutdownTracing, err := tracing.Setup(ctx, serviceName, appConfig.TraceExporter, appConfig.TraceEndpoint)
+	if err != nil {
+		return fmt.Errorf("setup tracing: %w", err)
+	}
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownCtxTimeout)
+		defer shutdownCancel()
+
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Log.Error("failed to shutdown tracing", zap.Error(err))
+		}
+	}()
+
 	database, err := sql.Open("pgx", appConfig.DatabaseDSN)
 	if err != nil {
 		return err
@@ -85,7 +125,7 @@ func run() error {
 	}()
 
 	if appConfig.DatabaseDSN != "" {
-		if err := applyMigrations(database, "migrations"); err != nil {
+		if err := repository.ApplyMigrations(database, "migrations"); err != nil {
 			log.Fatalf("failed to apply migrations: %v", err)
 		}
 	}
@@ -106,15 +146,87 @@ func run() error {
 		}
 	}()
 
-	deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, 500)
+	deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, auditPublisher, 500)
+	metrics.RegisterDeleteWorkerQueueDepth(deleteURLWorker.QueueLen)
 	go deleteURLWorker.Run(ctx)
 
-	urlService := service.NewURLService(urlRepo, appConfig.BaseURL, deleteURLWorker, auditPublisher)
+	expiredURLReaper := worker.NewExpiredURLReaper(urlRepo, expiredURLsReapInterval)
+	go expiredURLReaper.Run(ctx)
+
+	clickRepo, closeClickRepo, err := setupClickRepository(appConfig, database)
+	if err != nil {
+		return err
+	}
+	defer closeClickRepo()
+
+	clickWorker := worker.NewClickWorker(clickRepo, 1000)
+	clickWorkerDone := make(chan struct{})
+	go func() {
+		defer close(clickWorkerDone)
+		clickWorker.Run(ctx)
+	}()
+	// the click storage is closed only after the worker flushed the pending clicks
+	defer func() {
+		cancel()
+		<-clickWorkerDone
+	}()
+
+	shortCodeGenerator, err := setupShortCodeGenerator(appConfig, database)
+	if err != nil {
+		return fmt.Errorf("setup short code generator: %w", err)
+	}
+
+	destinationPolicy, err := setupDestinationPolicy(ctx, appConfig)
+	if err != nil {
+		return fmt.Errorf("setup destination policy: %w", err)
+	}
+
+	timeouts := service.Timeouts{
+		Shorten: appConfig.ShortenTimeout,
+		Resolve: appConfig.ResolveTimeout,
+		List:    appConfig.ListTimeout,
+		Stats:   appConfig.StatsTimeout,
+	}
+
+	urlService := service.NewURLService(urlRepo, appConfig.BaseURL, shortCodeGenerator, destinationPolicy, deleteURLWorker, clickWorker, auditPublisher, timeouts)
 	urlHandler := handler.NewURLHandler(urlService, database)
 
+	statsService := service.NewStatsService(urlRepo, clickRepo, appConfig.BaseURL, timeouts)
+	statsHandler := handler.NewStatsHandler(statsService)
+
+	qrService := service.NewQRService(urlRepo, appConfig.BaseURL, timeouts)
+	qrHandler := handler.NewQRHandler(qrService)
+
+	trustedSubnet, err := subnet.ParseTrustedSubnet(appConfig.TrustedSubnet)
+	if err != nil {
+		return fmt.Errorf("parse trusted subnet: %w", err)
+	}
+
+	trustedProxies, err := subnet.ParseTrustedProxies(appConfig.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("parse trusted proxies: %w", err)
+	}
+
+	rateLimits, err := parseRateLimits(appConfig)
+	if err != nil {
+		return err
+	}
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.KeyByUserOrIP)
+
 	r := chi.NewRouter()
+	r.Use(tracing.HTTPMiddleware())
+	r.Use(requestid.Middleware())
+	r.Use(subnet.ClientIPMiddleware(trustedProxies))
+	r.Use(audit.OriginMiddleware())
+	r.Use(metrics.HTTPMiddleware())
 
 	r.Mount("/debug", middleware.Profiler())
+	r.Handle("/metrics", metrics.Handler())
+
+	// QR codes are served without compression and authorization cookies so that CDNs can cache them
+	// Rendering is costly, so they share the resolve rate limit keyed by client IP
+	r.With(logger.RequestLogger(), limiter.Middleware(rateLimitResolve, rateLimits[rateLimitResolve])).
+		Get(`/{id}/qr`, qrHandler.GetQRCode)
 
 	r.Group(func(r chi.Router) {
 		r.Use(logger.RequestLogger())
@@ -122,16 +234,23 @@ func run() error {
 		r.Use(authorization.AuthMiddleware([]byte(appConfig.AuthorizationKey)))
 
 		r.Get("/ping", urlHandler.Ping)
-		r.Get(`/{id}`, urlHandler.ResolveURL)
-		r.With(middleware.AllowContentType("text/plain")).
+		r.With(limiter.Middleware(rateLimitResolve, rateLimits[rateLimitResolve])).
+			Get(`/{id}`, urlHandler.ResolveURL)
+		r.With(limiter.Middleware(rateLimitShorten, rateLimits[rateLimitShorten]), middleware.AllowContentType("text/plain")).
 			Post(`/`, urlHandler.ShortenURLAsText)
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(limiter.Middleware(rateLimitShorten, rateLimits[rateLimitShorten]), middleware.AllowContentType("application/json")).
 			Post(`/api/shorten`, urlHandler.ShortenURLAsJSON)
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(limiter.Middleware(rateLimitBatch, rateLimits[rateLimitBatch]), middleware.AllowContentType("application/json")).
 			Post(`/api/shorten/batch`, urlHandler.BatchShortenURL)
 
 		r.Get(`/api/user/urls`, urlHandler.GetUserURLs)
+		r.Get(`/api/user/urls/{id}/stats`, statsHandler.GetURLStats)
 		r.With(middleware.AllowContentType("application/json")).
+			Put(`/api/user/urls/{id}/redirect`, urlHandler.UpdateRedirectSettings)
+
+		r.With(subnet.TrustedSubnetMiddleware(trustedSubnet)).
+			Get(`/api/internal/stats`, statsHandler.GetServiceStats)
+		r.With(limiter.Middleware(rateLimitDelete, rateLimits[rateLimitDelete]), middleware.AllowContentType("application/json")).
 			Delete(`/api/user/urls`, urlHandler.DeleteUserURLs)
 	})
 
@@ -144,17 +263,23 @@ func run() error {
 		IdleTimeout:       idleTimeout,
 	}
 
-	serverErr := make(chan error, 1)
+	if appConfig.EnableHTTPS {
+		if err := certs.EnsureCertificates(appConfig.HTTPSCertFile, appConfig.HTTPSKeyFile); err != nil {
+			return fmt.Errorf("ensure certificates: %w", err)
+		}
+	}
+
+	grpcServer, err := setupGRPCServer(appConfig, urlService, database, trustedProxies, limiter, rateLimits)
+	if err != nil {
+		return err
+	}
+
+	serverErr := make(chan error, 2)
 
 	go func() {
 		var err error
 
 		if appConfig.EnableHTTPS {
-			if err = certs.EnsureCertificates(appConfig.HTTPSCertFile, appConfig.HTTPSKeyFile); err != nil {
-				serverErr <- fmt.Errorf("ensure certificates: %w", err)
-				return
-			}
-
 			logger.Log.Info("starting HTTPS server",
 				zap.String("address", appConfig.Address),
 				zap.String("cert_file", appConfig.HTTPSCertFile),
@@ -178,6 +303,25 @@ func run() error {
 		serverErr <- nil
 	}()
 
+	if grpcServer != nil {
+		go func() {
+			listener, err := net.Listen("tcp", appConfig.GRPCAddress)
+			if err != nil {
+				serverErr <- fmt.Errorf("grpc listen: %w", err)
+				return
+			}
+
+			logger.Log.Info("running gRPC server...", zap.String("address", appConfig.GRPCAddress))
+
+			if err := grpcServer.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				serverErr <- err
+				return
+			}
+
+			serverErr <- nil
+		}()
+	}
+
 	sigCtx, stop := signal.NotifyContext(
 		context.Background(),
 		syscall.SIGINT,
@@ -196,6 +340,10 @@ func run() error {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownCtxTimeout)
 	defer shutdownCancel()
 
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		return fmt.Errorf("shutdown failed: %w", err)
 	}
@@ -206,6 +354,103 @@ func run() error {
 	return nil
 }
 
+// setupGRPCServer configures the gRPC server, returns nil if it is disabled
+func setupGRPCServer(
+	config *config.Config,
+	urlService *service.URLService,
+	database *sql.DB,
+	trustedProxies subnet.TrustedProxies,
+	limiter *ratelimit.Limiter,
+	rateLimits map[string]ratelimit.Limit,
+) (*grpc.Server, error) {
+	if config.GRPCAddress == "" {
+		return nil, nil
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			logger.UnaryServerInterceptor(),
+			subnet.UnaryServerInterceptor(trustedProxies),
+			authorization.UnaryServerInterceptor([]byte(config.AuthorizationKey)),
+			limiter.UnaryServerInterceptor(grpcRateLimits(rateLimits)),
+			handler.AuditOriginInterceptor(),
+		),
+	}
+
+	if config.EnableHTTPS {
+		creds, err := credentials.NewServerTLSFromFile(config.HTTPSCertFile, config.HTTPSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load grpc credentials: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+	pb.RegisterShortenerServer(grpcServer, handler.NewGRPCHandler(urlService, database))
+
+	return grpcServer, nil
+}
+
+// setupDestinationPolicy builds the destination URL policy from configuration
+// The domain blocklist is reloaded in the background until the context is cancelled
+func setupDestinationPolicy(ctx context.Context, config *config.Config) (*policy.Policy, error) {
+	options := policy.Options{
+		AllowedSchemes:    strings.Split(config.AllowedSchemes, ","),
+		BlockPrivateHosts: config.BlockPrivateHosts,
+		MaxURLLength:      config.MaxURLLength,
+	}
+
+	if config.DomainBlocklist != "" {
+		blocklist, err := policy.NewBlocklist(config.DomainBlocklist)
+		if err != nil {
+			return nil, err
+		}
+		go blocklist.Run(ctx, blocklistReloadInterval)
+
+		options.Blocklist = blocklist
+	}
+
+	return policy.NewPolicy(options), nil
+}
+
+// grpcRateLimits assigns the rate limits of the HTTP routes to the gRPC methods doing the same
+func grpcRateLimits(rateLimits map[string]ratelimit.Limit) map[string]ratelimit.MethodLimit {
+	methods := map[string]string{
+		pb.Shortener_Shorten_FullMethodName:        rateLimitShorten,
+		pb.Shortener_BatchShorten_FullMethodName:   rateLimitBatch,
+		pb.Shortener_Resolve_FullMethodName:        rateLimitResolve,
+		pb.Shortener_DeleteUserURLs_FullMethodName: rateLimitDelete,
+	}
+
+	limits := make(map[string]ratelimit.MethodLimit, len(methods))
+	for method, scope := range methods {
+		limits[method] = ratelimit.MethodLimit{Scope: scope, Limit: rateLimits[scope]}
+	}
+
+	return limits
+}
+
+// parseRateLimits parses the configured rate limits keyed by scope
+func parseRateLimits(config *config.Config) (map[string]ratelimit.Limit, error) {
+	values := map[string]string{
+		rateLimitShorten: config.RateLimitShorten,
+		rateLimitBatch:   config.RateLimitBatch,
+		rateLimitResolve: config.RateLimitResolve,
+		rateLimitDelete:  config.RateLimitDelete,
+	}
+
+	limits := make(map[string]ratelimit.Limit, len(values))
+	for scope, value := range values {
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s rate limit: %w", scope, err)
+		}
+		limits[scope] = limit
+	}
+
+	return limits, nil
+}
+
 // setupRepository initializes the storage based on configuration
 func setupRepository(config *config.Config) (repository.URLRepository, func(), error) {
 	if config.DatabaseDSN != "" {
@@ -223,95 +468,187 @@ func setupRepository(config *config.Config) (repository.URLRepository, func(), e
 			}
 		}
 
-		return databaseRepo, cleanup, nil
+		return repository.NewInstrumentedURLRepository(databaseRepo, "database"), cleanup, nil
 	}
 
 	if config.FileStoragePath != "" {
 		logger.Log.Info("Using the file system for storage...")
 
-		fileRepo, err := repository.NewURLFileRepository(config.FileStoragePath)
+		fileRepo, err := repository.NewURLFileRepository(config.FileStoragePath, repository.FileRepositoryOptions{
+			SyncPolicy:         repository.SyncPolicy(config.FileSyncPolicy),
+			SyncInterval:       fileSyncInterval,
+			CompactionInterval: fileCompactionInterval,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
 
-		return fileRepo, func() {}, err
+		cleanup := func() {
+			if err := fileRepo.Close(); err != nil {
+				logger.Log.Error("failed to close file storage", zap.Error(err))
+			}
+		}
+
+		return repository.NewInstrumentedURLRepository(fileRepo, "file"), cleanup, nil
 	}
 
 	logger.Log.Info("Using the in-memory repository...")
 
-	return repository.NewURLInMemoryRepository(), func() {}, nil
+	return repository.NewInstrumentedURLRepository(repository.NewURLInMemoryRepository(), "memory"), func() {}, nil
 }
 
-// setupAudit configures the audit events publisher
-func setupAudit(ctx context.Context, config *config.Config) (audit.Publisher, []io.Closer, error) {
-	if config.AuditFile == "" && config.AuditURL == "" {
-		return audit.NewNoop(), nil, nil
+// setupClickRepository initializes the click storage based on configuration
+func setupClickRepository(config *config.Config, database *sql.DB) (repository.ClickRepository, func(), error) {
+	if config.DatabaseDSN != "" {
+		return repository.NewInstrumentedClickRepository(repository.NewClickDatabaseRepository(database), "database"), func() {}, nil
 	}
 
-	svc := audit.NewService(ctx, 100)
-
-	var closers []io.Closer
-
-	if config.AuditFile != "" {
-		fileObserver, err := audit.NewFileObserver(config.AuditFile)
+	if config.FileStoragePath != "" {
+		fileRepo, err := repository.NewClickFileRepository(config.FileStoragePath + ".clicks")
 		if err != nil {
 			return nil, nil, err
 		}
-		svc.Register(fileObserver)
-		closers = append(closers, fileObserver)
-	}
 
-	if config.AuditURL != "" {
-		httpObserver := audit.NewHTTPObserver(config.AuditURL)
-		svc.Register(httpObserver)
+		cleanup := func() {
+			if err := fileRepo.Close(); err != nil {
+				logger.Log.Error("failed to close click storage", zap.Error(err))
+			}
+		}
+
+		return repository.NewInstrumentedClickRepository(fileRepo, "file"), cleanup, nil
 	}
 
-	closers = append(closers, svc)
+	return repository.NewInstrumentedClickRepository(repository.NewClickInMemoryRepository(), "memory"), func() {}, nil
+}
+
+// setupShortCodeGenerator initializes the short code generation strategy based on configuration
+// Counter-based strategies keep their sequence in the database
+func setupShortCodeGenerator(config *config.Config, database *sql.DB) (generator.ShortCodeGenerator, error) {
+	switch config.ShortCodeStrategy {
+	case generator.StrategyHash:
+		return generator.NewHashGenerator(config.ShortCodeLength, config.ShortCodeAlphabet)
 
-	return svc, closers, nil
+	case generator.StrategyRandom:
+		return generator.NewRandomGenerator(config.ShortCodeLength, config.ShortCodeAlphabet)
+
+	case generator.StrategyCounter, generator.StrategyHashids:
+		if config.DatabaseDSN == "" {
+			return nil, fmt.Errorf("short code strategy %q requires the database", config.ShortCodeStrategy)
+		}
+
+		sequence := repository.NewSequenceDatabaseRepository(database, repository.ShortCodeSequenceName)
+		if config.ShortCodeStrategy == generator.StrategyCounter {
+			return generator.NewCounterGenerator(sequence, config.ShortCodeLength, config.ShortCodeAlphabet)
+		}
+
+		return generator.NewHashidsGenerator(sequence, config.ShortCodeLength, config.ShortCodeAlphabet, config.ShortCodeSalt)
+	}
+
+	return nil, fmt.Errorf("unknown short code strategy %q", config.ShortCodeStrategy)
 }
 
-// applyMigrations executes SQL migrations from the migrations directory
-func applyMigrations(db *sql.DB, dir string) error {
-	_, err := db.Exec(`
-        CREATE TABLE IF NOT EXISTS schema_migrations (
-            version VARCHAR(255) PRIMARY KEY
-        );
-    `)
-	if err != nil {
-		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+// setupAudit configures the audit events publisher
+func setupAudit(ctx context.Context, config *config.Config) (audit.Publisher, []io.Closer, error) {
+	if config.AuditFile == "" && config.AuditURL == "" && config.AuditSyslog == "" {
+		return audit.NewNoop(), nil, nil
 	}
 
-	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	options := audit.DefaultOptions()
+	options.SpoolDir = config.AuditSpoolDir
+	options.DeadLetterFile = config.AuditDeadLetter
+	options.Retry.MaxAttempts = config.AuditMaxAttempts
+
+	svc, err := audit.NewService(ctx, options)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	for _, file := range files {
-		version := filepath.Base(file)
+	// The service is closed first so pending events reach the observers
+	closers := []io.Closer{svc}
 
-		var exists string
-		err := db.QueryRow("SELECT version FROM schema_migrations WHERE version=$1", version).Scan(&exists)
-		if err == nil {
-			continue
-		} else if err != sql.ErrNoRows {
-			return fmt.Errorf("failed to check migration %s: %w", version, err)
+	if config.AuditFile != "" {
+		fileObserver, err := audit.NewFileObserver(config.AuditFile, audit.FileObserverOptions{
+			MaxSize:     int64(config.AuditFileMaxSize) << 20,
+			RotateEvery: config.AuditFileRotate,
+			MaxBackups:  config.AuditFileBackups,
+			MaxAge:      config.AuditFileMaxAge,
+			Compress:    config.AuditFileCompress,
+		})
+		if err != nil {
+			return nil, nil, errors.Join(err, svc.Close())
 		}
+		closers = append(closers, fileObserver)
 
-		sqlBytes, err := os.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", file, err)
+		if err := svc.Register(fileObserver); err != nil {
+			return nil, nil, errors.Join(err, closeAll(closers))
 		}
 
-		_, err = db.Exec(string(sqlBytes))
+		go reopenOnHangup(ctx, fileObserver)
+	}
+
+	if config.AuditSyslog != "" {
+		options := audit.DefaultSyslogObserverOptions()
+		if options.Facility, err = audit.ParseFacility(config.AuditFacility); err != nil {
+			return nil, nil, errors.Join(err, closeAll(closers))
+		}
+
+		syslogObserver, err := audit.NewSyslogObserver(config.AuditSyslog, options)
 		if err != nil {
-			return fmt.Errorf("failed to apply migration %s: %w", file, err)
+			return nil, nil, errors.Join(err, closeAll(closers))
 		}
+		closers = append(closers, syslogObserver)
+
+		if err := svc.Register(syslogObserver); err != nil {
+			return nil, nil, errors.Join(err, closeAll(closers))
+		}
+	}
 
-		_, err = db.Exec("INSERT INTO schema_migrations(version) VALUES ($1)", version)
+	if config.AuditURL != "" {
+		httpObserver, err := audit.NewHTTPObserver(config.AuditURL, audit.HTTPObserverOptions{
+			BatchSize:     config.AuditBatchSize,
+			FlushInterval: config.AuditFlush,
+			Format:        config.AuditFormat,
+			Gzip:          config.AuditGzip,
+			SigningKey:    config.AuditSigningKey,
+		})
 		if err != nil {
-			return fmt.Errorf("failed to record applied migration %s: %w", version, err)
+			return nil, nil, errors.Join(err, closeAll(closers))
 		}
 
-		logger.Log.Info("Applied migration: " + version)
+		if err := svc.Register(httpObserver); err != nil {
+			return nil, nil, errors.Join(err, closeAll(closers))
+		}
 	}
 
-	return nil
+	return svc, closers, nil
+}
+
+// reopenOnHangup reopens the audit log file on SIGHUP so it can be rotated by logrotate
+func reopenOnHangup(ctx context.Context, fileObserver *audit.FileObserver) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			if err := fileObserver.Reopen(); err != nil {
+				logger.Log.Error("failed to reopen audit file", zap.Error(err))
+				continue
+			}
+			logger.Log.Info("audit file reopened")
+		}
+	}
+}
+
+// closeAll closes the closers in order and joins their errors
+func closeAll(closers []io.Closer) error {
+	var err error
+	for _, closer := range closers {
+		err = errors.Join(err, closer.Close())
+	}
+
+	return err
 }
@@ -0,0 +1,219 @@
+// Package main provides a command line tool for bulk export and import of URL pairs
+//
+// Usage:
+//
+//	shortenerctl [storage flags] export [-format jsonl|csv] [-output path]
+//	shortenerctl [storage flags] import [-format jsonl|csv] [-input path] [-migrations dir]
+//
+// The storage is selected with the same flags and environment variables as the server,
+// e.g. -d for the database or -f for the file storage.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/config"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/transfer"
+)
+
+const (
+	fileSyncInterval = time.Second
+
+	// exitConflicts is the exit code of an import that reported conflicts
+	exitConflicts = 2
+)
+
+// errConflicts is returned when some imported URL pairs could not be stored
+var errConflicts = errors.New("import finished with conflicts")
+
+// main tool entry point
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "shortenerctl: %s\n", err)
+
+		if errors.Is(err, errConflicts) {
+			os.Exit(exitConflicts)
+		}
+		os.Exit(1)
+	}
+}
+
+// run parses the configuration and executes the requested subcommand
+func run() error {
+	flag.Usage = usage
+
+	appConfig, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+
+	if flag.NArg() == 0 {
+		usage()
+		return errors.New("missing command")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "export":
+		return runExport(ctx, appConfig, args)
+	case "import":
+		return runImport(ctx, appConfig, args)
+	}
+
+	usage()
+	return fmt.Errorf("unknown command %q", command)
+}
+
+// runExport writes all URL pairs of the configured storage to a file or stdout
+func runExport(ctx context.Context, appConfig *config.Config, args []string) (err error) {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", transfer.FormatJSONL, "Output format: jsonl or csv")
+	output := flags.String("output", "", "Output file path, stdout when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, file.Close())
+		}()
+		out = file
+	}
+
+	writer, err := transfer.NewWriter(*format, out)
+	if err != nil {
+		return err
+	}
+
+	repo, err := openRepository(appConfig, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, repo.Close())
+	}()
+
+	exported, err := transfer.Export(ctx, repo, writer)
+	if err != nil {
+		return fmt.Errorf("export failed after %d URL pairs: %w", exported, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d URL pairs\n", exported)
+
+	return nil
+}
+
+// runImport stores URL pairs from a file or stdin in the configured storage
+func runImport(ctx context.Context, appConfig *config.Config, args []string) (err error) {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", transfer.FormatJSONL, "Input format: jsonl or csv")
+	input := flags.String("input", "", "Input file path, stdin when empty")
+	migrationsDir := flags.String("migrations", "migrations", "Database migrations directory, migrations are skipped when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	reader, err := transfer.NewReader(*format, in)
+	if err != nil {
+		return err
+	}
+
+	repo, err := openRepository(appConfig, *migrationsDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, repo.Close())
+	}()
+
+	stats, err := transfer.Import(ctx, repo, reader, func(conflict transfer.Conflict) {
+		fmt.Fprintf(os.Stderr, "conflict: %s -> %s: %s\n", conflict.URLPair.Short, conflict.URLPair.Long, conflict.Reason)
+	})
+
+	fmt.Fprintf(os.Stderr, "imported %d, skipped %d, conflicts %d\n", stats.Imported, stats.Skipped, stats.Conflicts)
+
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+	if stats.Conflicts > 0 {
+		return errConflicts
+	}
+
+	return nil
+}
+
+// closableRepository is a URL repository that holds resources to release
+type closableRepository interface {
+	repository.URLRepository
+	io.Closer
+}
+
+// openRepository opens the persistent storage selected by the configuration
+// Database migrations are applied from migrationsDir unless it is empty
+func openRepository(appConfig *config.Config, migrationsDir string) (closableRepository, error) {
+	if appConfig.DatabaseDSN != "" {
+		database, err := sql.Open("pgx", appConfig.DatabaseDSN)
+		if err != nil {
+			return nil, err
+		}
+
+		if migrationsDir != "" {
+			if err := repository.ApplyMigrations(database, migrationsDir); err != nil {
+				return nil, errors.Join(fmt.Errorf("failed to apply migrations: %w", err), database.Close())
+			}
+		}
+
+		return repository.NewURLDatabaseRepository(database), nil
+	}
+
+	if appConfig.FileStoragePath != "" {
+		return repository.NewURLFileRepository(appConfig.FileStoragePath, repository.FileRepositoryOptions{
+			SyncPolicy:   repository.SyncPolicy(appConfig.FileSyncPolicy),
+			SyncInterval: fileSyncInterval,
+		})
+	}
+
+	return nil, errors.New("no persistent storage configured, set -d or -f")
+}
+
+// usage prints the command line help
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  shortenerctl [storage flags] export [-format jsonl|csv] [-output path]")
+	fmt.Fprintln(out, "  shortenerctl [storage flags] import [-format jsonl|csv] [-input path] [-migrations dir]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Storage flags:")
+	flag.PrintDefaults()
+}
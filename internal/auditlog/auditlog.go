@@ -0,0 +1,158 @@
+// Package auditlog reads, filters and summarizes the JSONL files written by audit.FileObserver
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+)
+
+// Filter selects audit events, empty fields match every event
+type Filter struct {
+	// From matches events recorded at or after the time
+	From time.Time
+	// To matches events recorded before the time
+	To time.Time
+	// Actions matches events of any of the actions
+	Actions []string
+	// UserID matches events of the user
+	UserID string
+	// URL matches events whose URL contains it or whose short code equals it
+	URL string
+}
+
+// Match reports whether the event is selected by the filter
+func (f Filter) Match(event audit.Event) bool {
+	ts := time.Unix(event.TS, 0)
+
+	if !f.From.IsZero() && ts.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !ts.Before(f.To) {
+		return false
+	}
+	if len(f.Actions) > 0 && !slices.Contains(f.Actions, event.Action) {
+		return false
+	}
+	if f.UserID != "" && event.UserID != f.UserID {
+		return false
+	}
+	if f.URL != "" && !strings.Contains(event.URL, f.URL) && event.Short != f.URL {
+		return false
+	}
+
+	return true
+}
+
+// Files returns the audit log at path preceded by its rotated files from the oldest to the newest
+// A missing audit log is skipped when rotated files exist, it may have just been rotated
+func Files(path string) ([]string, error) {
+	rotated, err := audit.RotatedFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(rotated)+1)
+	for _, file := range rotated {
+		files = append(files, file.Path)
+	}
+
+	if _, err := os.Stat(path); err == nil || len(files) == 0 {
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+// Open opens an audit log file, rotated files compressed with gzip are decompressed
+func Open(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+
+	return &gzipFile{Reader: gz, file: file}, nil
+}
+
+// gzipFile closes both the decompressor and the underlying file
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+// Close closes the decompressor and the file
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Reader.Close(), g.file.Close())
+}
+
+// Scan calls fn for every event of the reader matching the filter
+// Lines that are not valid events, such as a partially written last line, are skipped and counted
+func Scan(r io.Reader, filter Filter, fn func(audit.Event) error) (skipped int, err error) {
+	reader := bufio.NewReader(r)
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return skipped, readErr
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var event audit.Event
+			if err := json.Unmarshal(line, &event); err != nil {
+				skipped++
+			} else if filter.Match(event) {
+				if err := fn(event); err != nil {
+					return skipped, err
+				}
+			}
+		}
+
+		if readErr != nil {
+			return skipped, nil
+		}
+	}
+}
+
+// ScanFiles scans the files in order, see Scan
+func ScanFiles(paths []string, filter Filter, fn func(audit.Event) error) (skipped int, err error) {
+	for _, path := range paths {
+		n, err := scanFile(path, filter, fn)
+		skipped += n
+		if err != nil {
+			return skipped, err
+		}
+	}
+
+	return skipped, nil
+}
+
+// scanFile scans one file, see Scan
+func scanFile(path string, filter Filter, fn func(audit.Event) error) (skipped int, err error) {
+	file, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	return Scan(file, filter, fn)
+}
@@ -0,0 +1,189 @@
+package auditlog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+)
+
+var testEvents = []audit.Event{
+	{TS: 1700000000, Action: audit.ActionShorten, Outcome: audit.OutcomeSuccess, UserID: "user-1", URL: "https://yandex.ru", Short: "abc"},
+	{TS: 1700000100, Action: audit.ActionFollow, Outcome: audit.OutcomeSuccess, UserID: "user-2", URL: "https://yandex.ru", Short: "abc"},
+	{TS: 1700000200, Action: audit.ActionShorten, Outcome: audit.OutcomeSuccess, UserID: "user-2", URL: "https://google.com", Short: "xyz"},
+	{TS: 1700000300, Action: audit.ActionDelete, Outcome: audit.OutcomeSuccess, UserID: "user-1", URL: "https://yandex.ru", Short: "abc"},
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int64
+	}{
+		{
+			name: "Positive case: empty filter matches every event",
+			want: []int64{1700000000, 1700000100, 1700000200, 1700000300},
+		},
+		{
+			name:   "Positive case: time range includes from and excludes to",
+			filter: Filter{From: time.Unix(1700000100, 0), To: time.Unix(1700000300, 0)},
+			want:   []int64{1700000100, 1700000200},
+		},
+		{
+			name:   "Positive case: actions",
+			filter: Filter{Actions: []string{audit.ActionShorten, audit.ActionDelete}},
+			want:   []int64{1700000000, 1700000200, 1700000300},
+		},
+		{
+			name:   "Positive case: user and URL substring",
+			filter: Filter{UserID: "user-2", URL: "yandex"},
+			want:   []int64{1700000100},
+		},
+		{
+			name:   "Positive case: short code",
+			filter: Filter{URL: "xyz"},
+			want:   []int64{1700000200},
+		},
+		{
+			name:   "Negative case: unknown user",
+			filter: Filter{UserID: "user-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			for _, event := range testEvents {
+				if tt.filter.Match(event) {
+					got = append(got, event.TS)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScanFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	// Rotated files as written by audit.FileObserver, the current file ends with a partially written line
+	writeAuditFile(t, strings.TrimSuffix(path, ".log")+"-2023-11-14T22-13-00.000.log.gz", testEvents[:1], "")
+	writeAuditFile(t, strings.TrimSuffix(path, ".log")+"-2023-11-14T22-15-00.000.log", testEvents[1:2], "{not json}\n")
+	writeAuditFile(t, path, testEvents[2:], `{"ts":17000`)
+
+	files, err := Files(path)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, path, files[2])
+
+	var got []audit.Event
+	skipped, err := ScanFiles(files, Filter{}, func(event audit.Event) error {
+		got = append(got, event)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, skipped)
+	assert.Equal(t, testEvents, got)
+
+	errStop := errors.New("stop")
+	_, err = ScanFiles(files, Filter{}, func(audit.Event) error { return errStop })
+	assert.ErrorIs(t, err, errStop)
+
+	_, err = ScanFiles([]string{filepath.Join(t.TempDir(), "missing.log")}, Filter{}, func(audit.Event) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestSummarizer(t *testing.T) {
+	summarizer := NewSummarizer()
+	for _, event := range testEvents {
+		summarizer.Add(event)
+	}
+
+	summary := summarizer.Summary(1)
+
+	assert.Equal(t, 4, summary.Total)
+	assert.Equal(t, time.Unix(1700000000, 0), summary.From)
+	assert.Equal(t, time.Unix(1700000300, 0), summary.To)
+	assert.Equal(t, []Count{{Key: audit.ActionShorten, Count: 2}, {Key: audit.ActionDelete, Count: 1}, {Key: audit.ActionFollow, Count: 1}}, summary.ByAction)
+	assert.Equal(t, []Count{{Key: "https://yandex.ru", Count: 3}}, summary.TopURLs)
+	assert.Equal(t, []Count{{Key: "user-1", Count: 2}}, summary.ByUser)
+
+	assert.Len(t, summarizer.Summary(0).ByUser, 2)
+	assert.Zero(t, NewSummarizer().Summary(10).From)
+}
+
+func TestReplayer(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]audit.Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var events []audit.Event
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		batches = append(batches, events)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	observer, err := audit.NewHTTPObserver(srv.URL, audit.HTTPObserverOptions{BatchSize: 3, FlushInterval: time.Second, Format: audit.FormatJSON})
+	require.NoError(t, err)
+
+	replayer := NewReplayer(observer)
+	for _, event := range testEvents {
+		require.NoError(t, replayer.Add(event))
+	}
+	assert.Equal(t, 3, replayer.Sent())
+
+	require.NoError(t, replayer.Flush())
+	assert.Equal(t, 4, replayer.Sent())
+
+	require.Len(t, batches, 2)
+	assert.Equal(t, testEvents[:3], batches[0])
+	assert.Equal(t, testEvents[3:], batches[1])
+}
+
+// writeAuditFile writes the events as JSONL followed by the tail, compressed when the path ends with .gz
+func writeAuditFile(t *testing.T, path string, events []audit.Event, tail string) {
+	t.Helper()
+
+	var sb strings.Builder
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		require.NoError(t, err)
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(tail)
+
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	if !strings.HasSuffix(path, ".gz") {
+		_, err = file.WriteString(sb.String())
+		require.NoError(t, err)
+		return
+	}
+
+	gz := gzip.NewWriter(file)
+	_, err = gz.Write([]byte(sb.String()))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+}
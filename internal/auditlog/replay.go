@@ -0,0 +1,54 @@
+package auditlog
+
+import (
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+)
+
+// Replayer sends events to an observer in batches of the size the observer is configured with
+type Replayer struct {
+	observer audit.BatchObserver
+	size     int
+	batch    []audit.Event
+	sent     int
+}
+
+// NewReplayer creates a new Replayer
+func NewReplayer(observer audit.BatchObserver) *Replayer {
+	size, _ := observer.Batching()
+
+	return &Replayer{
+		observer: observer,
+		size:     max(size, 1),
+	}
+}
+
+// Add queues the event and sends the batch once it is full
+func (r *Replayer) Add(event audit.Event) error {
+	r.batch = append(r.batch, event)
+	if len(r.batch) < r.size {
+		return nil
+	}
+
+	return r.Flush()
+}
+
+// Flush sends the queued events
+func (r *Replayer) Flush() error {
+	if len(r.batch) == 0 {
+		return nil
+	}
+
+	if err := r.observer.SendBatch(r.batch); err != nil {
+		return err
+	}
+
+	r.sent += len(r.batch)
+	r.batch = r.batch[:0]
+
+	return nil
+}
+
+// Sent returns the number of events delivered so far
+func (r *Replayer) Sent() int {
+	return r.sent
+}
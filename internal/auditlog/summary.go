@@ -0,0 +1,115 @@
+package auditlog
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+)
+
+// Count is the number of events of a key such as a URL or a user
+type Count struct {
+	Key   string
+	Count int
+}
+
+// Summary aggregates audit events
+type Summary struct {
+	Total int
+	// From and To are the times of the oldest and the newest event
+	From time.Time
+	To   time.Time
+	// ByAction counts events per action, ordered by count
+	ByAction []Count
+	// TopURLs counts events per URL, ordered by count
+	TopURLs []Count
+	// ByUser counts events per user, ordered by count
+	ByUser []Count
+}
+
+// Summarizer accumulates events into a Summary
+type Summarizer struct {
+	total    int
+	from, to int64
+	actions  map[string]int
+	urls     map[string]int
+	users    map[string]int
+}
+
+// NewSummarizer creates a new Summarizer
+func NewSummarizer() *Summarizer {
+	return &Summarizer{
+		actions: make(map[string]int),
+		urls:    make(map[string]int),
+		users:   make(map[string]int),
+	}
+}
+
+// Add counts the event
+// Events without a URL or user, such as deletions of unknown short codes, are only counted in totals
+func (s *Summarizer) Add(event audit.Event) {
+	if s.total == 0 || event.TS < s.from {
+		s.from = event.TS
+	}
+	if s.total == 0 || event.TS > s.to {
+		s.to = event.TS
+	}
+	s.total++
+
+	s.actions[event.Action]++
+
+	if url := eventURL(event); url != "" {
+		s.urls[url]++
+	}
+	if event.UserID != "" {
+		s.users[event.UserID]++
+	}
+}
+
+// Summary returns the summary of the added events keeping at most limit URLs and users, all when limit is zero
+func (s *Summarizer) Summary(limit int) Summary {
+	summary := Summary{
+		Total:    s.total,
+		ByAction: topCounts(s.actions, 0),
+		TopURLs:  topCounts(s.urls, limit),
+		ByUser:   topCounts(s.users, limit),
+	}
+
+	if s.total > 0 {
+		summary.From = time.Unix(s.from, 0)
+		summary.To = time.Unix(s.to, 0)
+	}
+
+	return summary
+}
+
+// eventURL returns the destination URL of the event, the short code when the URL is not recorded
+func eventURL(event audit.Event) string {
+	if event.URL != "" {
+		return event.URL
+	}
+
+	return event.Short
+}
+
+// topCounts returns the counts ordered by count and key keeping at most limit of them, all when limit is zero
+func topCounts(counts map[string]int, limit int) []Count {
+	result := make([]Count, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, Count{Key: key, Count: count})
+	}
+
+	slices.SortFunc(result, func(a, b Count) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result
+}
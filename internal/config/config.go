@@ -9,37 +9,112 @@ import (
 	"go.uber.org/zap"
 	"os"
 	"strings"
+	"time"
 )
 
 // Config structure of application configuration
 type Config struct {
-	Address          string `env:"SERVER_ADDRESS" json:"server_address"`
-	BaseURL          string `env:"BASE_URL" json:"base_url"`
-	LogLevel         string `env:"LOG_LEVEL" json:"log_level"`
-	FileStoragePath  string `env:"FILE_STORAGE_PATH" json:"file_storage_path"`
-	DatabaseDSN      string `env:"DATABASE_DSN" json:"database_dsn"`
-	AuthorizationKey string `env:"AUTHORIZATION_KEY" json:"authorization_key"`
-	AuditFile        string `env:"AUDIT_FILE" json:"audit_file"`
-	AuditURL         string `env:"AUDIT_URL" json:"audit_url"`
-	EnableHTTPS      bool   `env:"ENABLE_HTTPS" json:"enable_https"`
-	HTTPSCertFile    string `env:"HTTPS_CERT_FILE" json:"https_cert_file"`
-	HTTPSKeyFile     string `env:"HTTPS_KEY_FILE" json:"https_key_file"`
+	Address           string        `env:"SERVER_ADDRESS" json:"server_address"`
+	BaseURL           string        `env:"BASE_URL" json:"base_url"`
+	LogLevel          string        `env:"LOG_LEVEL" json:"log_level"`
+	FileStoragePath   string        `env:"FILE_STORAGE_PATH" json:"file_storage_path"`
+	FileSyncPolicy    string        `env:"FILE_SYNC_POLICY" json:"file_sync_policy"`
+	DatabaseDSN       string        `env:"DATABASE_DSN" json:"database_dsn"`
+	AuthorizationKey  string        `env:"AUTHORIZATION_KEY" json:"authorization_key"`
+	AuditFile         string        `env:"AUDIT_FILE" json:"audit_file"`
+	AuditFileMaxSize  int           `env:"AUDIT_FILE_MAX_SIZE" json:"audit_file_max_size"`
+	AuditFileRotate   time.Duration `env:"AUDIT_FILE_ROTATE_INTERVAL" json:"audit_file_rotate_interval"`
+	AuditFileBackups  int           `env:"AUDIT_FILE_MAX_BACKUPS" json:"audit_file_max_backups"`
+	AuditFileMaxAge   time.Duration `env:"AUDIT_FILE_MAX_AGE" json:"audit_file_max_age"`
+	AuditFileCompress bool          `env:"AUDIT_FILE_COMPRESS" json:"audit_file_compress"`
+	AuditURL          string        `env:"AUDIT_URL" json:"audit_url"`
+	AuditSyslog       string        `env:"AUDIT_SYSLOG" json:"audit_syslog"`
+	AuditFacility     string        `env:"AUDIT_SYSLOG_FACILITY" json:"audit_syslog_facility"`
+	AuditSpoolDir     string        `env:"AUDIT_SPOOL_DIR" json:"audit_spool_dir"`
+	AuditDeadLetter   string        `env:"AUDIT_DEAD_LETTER_FILE" json:"audit_dead_letter_file"`
+	AuditMaxAttempts  int           `env:"AUDIT_MAX_ATTEMPTS" json:"audit_max_attempts"`
+	AuditBatchSize    int           `env:"AUDIT_BATCH_SIZE" json:"audit_batch_size"`
+	AuditFlush        time.Duration `env:"AUDIT_FLUSH_INTERVAL" json:"audit_flush_interval"`
+	AuditFormat       string        `env:"AUDIT_FORMAT" json:"audit_format"`
+	AuditGzip         bool          `env:"AUDIT_GZIP" json:"audit_gzip"`
+	AuditSigningKey   string        `env:"AUDIT_SIGNING_KEY" json:"audit_signing_key"`
+	EnableHTTPS       bool          `env:"ENABLE_HTTPS" json:"enable_https"`
+	HTTPSCertFile     string        `env:"HTTPS_CERT_FILE" json:"https_cert_file"`
+	HTTPSKeyFile      string        `env:"HTTPS_KEY_FILE" json:"https_key_file"`
+	GRPCAddress       string        `env:"GRPC_ADDRESS" json:"grpc_address"`
+	TrustedSubnet     string        `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
+	TrustedProxies    string        `env:"TRUSTED_PROXIES" json:"trusted_proxies"`
+	ShortCodeStrategy string        `env:"SHORT_CODE_STRATEGY" json:"short_code_strategy"`
+	ShortCodeLength   int           `env:"SHORT_CODE_LENGTH" json:"short_code_length"`
+	ShortCodeAlphabet string        `env:"SHORT_CODE_ALPHABET" json:"short_code_alphabet"`
+	ShortCodeSalt     string        `env:"SHORT_CODE_SALT" json:"short_code_salt"`
+	ShortenTimeout    time.Duration `env:"SHORTEN_TIMEOUT" json:"shorten_timeout"`
+	ResolveTimeout    time.Duration `env:"RESOLVE_TIMEOUT" json:"resolve_timeout"`
+	ListTimeout       time.Duration `env:"LIST_TIMEOUT" json:"list_timeout"`
+	StatsTimeout      time.Duration `env:"STATS_TIMEOUT" json:"stats_timeout"`
+	TraceExporter     string        `env:"TRACE_EXPORTER" json:"trace_exporter"`
+	TraceEndpoint     string        `env:"TRACE_ENDPOINT" json:"trace_endpoint"`
+	RateLimitShorten  string        `env:"RATE_LIMIT_SHORTEN" json:"rate_limit_shorten"`
+	RateLimitBatch    string        `env:"RATE_LIMIT_BATCH" json:"rate_limit_batch"`
+	RateLimitResolve  string        `env:"RATE_LIMIT_RESOLVE" json:"rate_limit_resolve"`
+	RateLimitDelete   string        `env:"RATE_LIMIT_DELETE" json:"rate_limit_delete"`
+	AllowedSchemes    string        `env:"ALLOWED_SCHEMES" json:"allowed_schemes"`
+	BlockPrivateHosts bool          `env:"BLOCK_PRIVATE_HOSTS" json:"block_private_hosts"`
+	DomainBlocklist   string        `env:"DOMAIN_BLOCKLIST_FILE" json:"domain_blocklist_file"`
+	MaxURLLength      int           `env:"MAX_URL_LENGTH" json:"max_url_length"`
 }
 
 // NewConfig loads configuration from defaults, environment variables and flags
 func NewConfig() (*Config, error) {
 	config := Config{
-		Address:          ":8080",
-		BaseURL:          "http://localhost:8080",
-		LogLevel:         "info",
-		FileStoragePath:  "",
-		DatabaseDSN:      "",
-		AuthorizationKey: "secret_auth_key",
-		AuditFile:        "",
-		AuditURL:         "",
-		EnableHTTPS:      false,
-		HTTPSCertFile:    "certs/server.crt",
-		HTTPSKeyFile:     "certs/server.key",
+		Address:           ":8080",
+		BaseURL:           "http://localhost:8080",
+		LogLevel:          "info",
+		FileStoragePath:   "",
+		FileSyncPolicy:    "always",
+		DatabaseDSN:       "",
+		AuthorizationKey:  "secret_auth_key",
+		AuditFile:         "",
+		AuditFileMaxSize:  0,
+		AuditFileRotate:   0,
+		AuditFileBackups:  0,
+		AuditFileMaxAge:   0,
+		AuditFileCompress: false,
+		AuditURL:          "",
+		AuditSyslog:       "",
+		AuditFacility:     "authpriv",
+		AuditSpoolDir:     "",
+		AuditDeadLetter:   "",
+		AuditMaxAttempts:  10,
+		AuditBatchSize:    1,
+		AuditFlush:        time.Second,
+		AuditFormat:       "json",
+		AuditGzip:         false,
+		AuditSigningKey:   "",
+		EnableHTTPS:       false,
+		HTTPSCertFile:     "certs/server.crt",
+		HTTPSKeyFile:      "certs/server.key",
+		GRPCAddress:       "",
+		TrustedSubnet:     "",
+		TrustedProxies:    "",
+		ShortCodeStrategy: "hash",
+		ShortCodeLength:   7,
+		ShortCodeAlphabet: "",
+		ShortCodeSalt:     "",
+		ShortenTimeout:    2 * time.Second,
+		ResolveTimeout:    500 * time.Millisecond,
+		ListTimeout:       time.Second,
+		StatsTimeout:      time.Second,
+		TraceExporter:     "none",
+		TraceEndpoint:     "",
+		RateLimitShorten:  "0",
+		RateLimitBatch:    "0",
+		RateLimitResolve:  "0",
+		RateLimitDelete:   "0",
+		AllowedSchemes:    "http,https",
+		BlockPrivateHosts: true,
+		DomainBlocklist:   "",
+		MaxURLLength:      2048,
 	}
 	var configPath string
 
@@ -50,13 +125,50 @@ func NewConfig() (*Config, error) {
 	flag.StringVar(&config.Address, "a", config.Address, "HTTP server start address")
 	flag.StringVar(&config.BaseURL, "b", config.BaseURL, "The base URL of shortened url")
 	flag.StringVar(&config.FileStoragePath, "f", config.FileStoragePath, "The file path for url pairs storage")
+	flag.StringVar(&config.FileSyncPolicy, "file-sync", config.FileSyncPolicy, "File storage fsync policy: always, interval or never")
 	flag.StringVar(&config.DatabaseDSN, "d", config.DatabaseDSN, "Database connection string")
 	flag.StringVar(&config.AuthorizationKey, "ak", config.AuthorizationKey, "Authorization Key")
 	flag.StringVar(&config.AuditFile, "audit-file", config.AuditFile, "Path to audit log file")
+	flag.IntVar(&config.AuditFileMaxSize, "audit-file-max-size", config.AuditFileMaxSize, "Size in megabytes at which the audit log file is rotated, disabled when zero")
+	flag.DurationVar(&config.AuditFileRotate, "audit-file-rotate", config.AuditFileRotate, "How long the audit log file is written before it is rotated, disabled when zero")
+	flag.IntVar(&config.AuditFileBackups, "audit-file-max-backups", config.AuditFileBackups, "Number of rotated audit log files to keep, all when zero")
+	flag.DurationVar(&config.AuditFileMaxAge, "audit-file-max-age", config.AuditFileMaxAge, "How long rotated audit log files are kept, forever when zero")
+	flag.BoolVar(&config.AuditFileCompress, "audit-file-compress", config.AuditFileCompress, "Compress rotated audit log files with gzip")
 	flag.StringVar(&config.AuditURL, "audit-url", config.AuditURL, "Remote audit server URL")
+	flag.StringVar(&config.AuditSyslog, "audit-syslog", config.AuditSyslog, "Syslog address of audit events as udp://host:port, tcp://host:port or unix:///path, disabled when empty")
+	flag.StringVar(&config.AuditFacility, "audit-syslog-facility", config.AuditFacility, "Syslog facility of audit events such as authpriv or local0")
+	flag.StringVar(&config.AuditSpoolDir, "audit-spool", config.AuditSpoolDir, "Directory keeping undelivered audit events across restarts, in memory when empty")
+	flag.StringVar(&config.AuditDeadLetter, "audit-dead-letter", config.AuditDeadLetter, "Path to the file of audit events that could not be delivered")
+	flag.IntVar(&config.AuditMaxAttempts, "audit-max-attempts", config.AuditMaxAttempts, "Delivery attempts of an audit event, unlimited when zero")
+	flag.IntVar(&config.AuditBatchSize, "audit-batch-size", config.AuditBatchSize, "Maximum number of audit events per request to the audit server")
+	flag.DurationVar(&config.AuditFlush, "audit-flush-interval", config.AuditFlush, "How long a batch of audit events may wait to fill up")
+	flag.StringVar(&config.AuditFormat, "audit-format", config.AuditFormat, "Payload format of audit event batches: json or ndjson")
+	flag.BoolVar(&config.AuditGzip, "audit-gzip", config.AuditGzip, "Compress requests to the audit server with gzip")
+	flag.StringVar(&config.AuditSigningKey, "audit-signing-key", config.AuditSigningKey, "HMAC-SHA256 key signing requests to the audit server, unsigned when empty")
 	flag.BoolVar(&config.EnableHTTPS, "s", config.EnableHTTPS, "Enable HTTPS")
 	flag.StringVar(&config.HTTPSCertFile, "https-cert", config.HTTPSCertFile, "Path to TLS certificate")
 	flag.StringVar(&config.HTTPSKeyFile, "https-key", config.HTTPSKeyFile, "Path to TLS private key")
+	flag.StringVar(&config.GRPCAddress, "g", config.GRPCAddress, "gRPC server start address, disabled when empty")
+	flag.StringVar(&config.TrustedSubnet, "t", config.TrustedSubnet, "Trusted subnet in CIDR notation for internal endpoints")
+	flag.StringVar(&config.TrustedProxies, "trusted-proxies", config.TrustedProxies, "Comma separated addresses or CIDRs of reverse proxies whose X-Real-IP and X-Forwarded-For headers are believed, none when empty")
+	flag.StringVar(&config.ShortCodeStrategy, "code-strategy", config.ShortCodeStrategy, "Short code generation strategy: hash, counter, random or hashids")
+	flag.IntVar(&config.ShortCodeLength, "code-length", config.ShortCodeLength, "Short code length")
+	flag.StringVar(&config.ShortCodeAlphabet, "code-alphabet", config.ShortCodeAlphabet, "Short code alphabet, strategy default when empty")
+	flag.StringVar(&config.ShortCodeSalt, "code-salt", config.ShortCodeSalt, "Salt of the hashids short code strategy")
+	flag.DurationVar(&config.ShortenTimeout, "shorten-timeout", config.ShortenTimeout, "Timeout of URL shortening, unlimited when zero")
+	flag.DurationVar(&config.ResolveTimeout, "resolve-timeout", config.ResolveTimeout, "Timeout of short URL resolving, unlimited when zero")
+	flag.DurationVar(&config.ListTimeout, "list-timeout", config.ListTimeout, "Timeout of user URLs listing, unlimited when zero")
+	flag.DurationVar(&config.StatsTimeout, "stats-timeout", config.StatsTimeout, "Timeout of statistics reading, unlimited when zero")
+	flag.StringVar(&config.TraceExporter, "trace-exporter", config.TraceExporter, "Trace exporter: none, otlp, stdout or file")
+	flag.StringVar(&config.TraceEndpoint, "trace-endpoint", config.TraceEndpoint, "OTLP collector URL or trace file path")
+	flag.StringVar(&config.RateLimitShorten, "rate-limit-shorten", config.RateLimitShorten, "Rate limit of URL shortening per client as count/period such as 120/1m, disabled when 0")
+	flag.StringVar(&config.RateLimitBatch, "rate-limit-batch", config.RateLimitBatch, "Rate limit of batch shortening per client as count/period, disabled when 0")
+	flag.StringVar(&config.RateLimitResolve, "rate-limit-resolve", config.RateLimitResolve, "Rate limit of short URL resolving and QR code rendering per client as count/period, disabled when 0")
+	flag.StringVar(&config.RateLimitDelete, "rate-limit-delete", config.RateLimitDelete, "Rate limit of URL deletion per client as count/period, disabled when 0")
+	flag.StringVar(&config.AllowedSchemes, "allowed-schemes", config.AllowedSchemes, "Comma-separated URL schemes that may be shortened")
+	flag.BoolVar(&config.BlockPrivateHosts, "block-private-hosts", config.BlockPrivateHosts, "Reject URLs of loopback, private and link-local hosts")
+	flag.StringVar(&config.DomainBlocklist, "domain-blocklist", config.DomainBlocklist, "Path to the domain blocklist file, reloaded on change")
+	flag.IntVar(&config.MaxURLLength, "max-url-length", config.MaxURLLength, "Maximum length of shortened URLs, unlimited when zero")
 	flag.StringVar(&configPath, "c", os.Getenv("CONFIG"), "Path to config file")
 	flag.Parse()
 
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration decodes a duration of the config file written as a string such as "1m30s"
+// Integers are accepted as nanoseconds as before
+type Duration time.Duration
+
+// UnmarshalJSON parses the duration with time.ParseDuration
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(duration)
+
+	case float64:
+		*d = Duration(value)
+
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+
+	return nil
+}
+
+// UnmarshalJSON decodes the config file, the durations through Duration
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plain Config
+
+	file := struct {
+		*plain
+		AuditFileRotate *Duration `json:"audit_file_rotate_interval"`
+		AuditFileMaxAge *Duration `json:"audit_file_max_age"`
+		AuditFlush      *Duration `json:"audit_flush_interval"`
+		ShortenTimeout  *Duration `json:"shorten_timeout"`
+		ResolveTimeout  *Duration `json:"resolve_timeout"`
+		ListTimeout     *Duration `json:"list_timeout"`
+		StatsTimeout    *Duration `json:"stats_timeout"`
+	}{
+		plain:           (*plain)(c),
+		AuditFileRotate: (*Duration)(&c.AuditFileRotate),
+		AuditFileMaxAge: (*Duration)(&c.AuditFileMaxAge),
+		AuditFlush:      (*Duration)(&c.AuditFlush),
+		ShortenTimeout:  (*Duration)(&c.ShortenTimeout),
+		ResolveTimeout:  (*Duration)(&c.ResolveTimeout),
+		ListTimeout:     (*Duration)(&c.ListTimeout),
+		StatsTimeout:    (*Duration)(&c.StatsTimeout),
+	}
+
+	return json.Unmarshal(data, &file)
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "Positive case: duration strings",
+			data: `{"shorten_timeout":"3s","resolve_timeout":"250ms","list_timeout":"1m30s","stats_timeout":"2s",` +
+				`"audit_file_rotate_interval":"24h","audit_file_max_age":"168h","audit_flush_interval":"500ms"}`,
+			want: Config{
+				Address:         ":8080",
+				ShortenTimeout:  3 * time.Second,
+				ResolveTimeout:  250 * time.Millisecond,
+				ListTimeout:     90 * time.Second,
+				StatsTimeout:    2 * time.Second,
+				AuditFileRotate: 24 * time.Hour,
+				AuditFileMaxAge: 168 * time.Hour,
+				AuditFlush:      500 * time.Millisecond,
+			},
+		},
+		{
+			name: "Positive case: nanoseconds and other fields",
+			data: `{"server_address":":9090","shorten_timeout":1000000000}`,
+			want: Config{
+				Address:        ":9090",
+				ShortenTimeout: time.Second,
+				ResolveTimeout: time.Minute,
+			},
+		},
+		{
+			name:    "Negative case: malformed duration",
+			data:    `{"shorten_timeout":"3 seconds"}`,
+			wantErr: true,
+		},
+		{
+			name:    "Negative case: duration of another type",
+			data:    `{"shorten_timeout":true}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{Address: ":8080", ResolveTimeout: time.Minute}
+
+			err := json.Unmarshal([]byte(tt.data), &config)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, config)
+		})
+	}
+}
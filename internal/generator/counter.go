@@ -0,0 +1,38 @@
+package generator
+
+import "context"
+
+// CounterGenerator encodes numbers of a shared sequence with the alphabet
+//
+// Codes are as short as possible and never collide while the sequence is not reset,
+// but consecutive codes are easy to guess.
+type CounterGenerator struct {
+	sequence Sequence
+	length   int
+	alphabet string
+}
+
+// NewCounterGenerator creates a new CounterGenerator instance
+// The length is the minimal length of a code, shorter codes are padded with the first character of the alphabet
+func NewCounterGenerator(sequence Sequence, length int, alphabet string) (*CounterGenerator, error) {
+	length, alphabet, err := normalizeOptions(length, alphabet, Base62Alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CounterGenerator{
+		sequence: sequence,
+		length:   length,
+		alphabet: alphabet,
+	}, nil
+}
+
+// Generate returns the next number of the sequence encoded with the alphabet
+func (g *CounterGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	n, err := g.sequence.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeNumber(n, g.alphabet, g.length), nil
+}
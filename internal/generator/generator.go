@@ -0,0 +1,131 @@
+// Package generator provides strategies of short code generation
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Strategy names selectable in configuration
+const (
+	StrategyHash    = "hash"
+	StrategyCounter = "counter"
+	StrategyRandom  = "random"
+	StrategyHashids = "hashids"
+)
+
+// Default alphabets of the strategies
+const (
+	// Base64Alphabet is the URL-safe base64 alphabet used by the hash strategy
+	Base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	// Base62Alphabet is the alphanumeric alphabet used by the counter and hashids strategies
+	Base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+const (
+	// DefaultLength is the short code length used when the length is not configured
+	DefaultLength = 7
+
+	minLength         = 4
+	maxLength         = 32
+	minAlphabetLength = 16
+)
+
+// ShortCodeGenerator generates short codes for original URLs
+type ShortCodeGenerator interface {
+	// Generate returns a short code candidate for the original URL
+	// The attempt is incremented each time the previous candidate turned out to be taken
+	Generate(ctx context.Context, originalURL string, attempt int) (string, error)
+}
+
+// Sequence provides monotonically increasing numbers for counter-based strategies
+type Sequence interface {
+	// Next returns the next number of the sequence
+	Next(ctx context.Context) (uint64, error)
+}
+
+// normalizeOptions applies defaults to the length and alphabet and validates them
+func normalizeOptions(length int, alphabet string, defaultAlphabet string) (int, string, error) {
+	if length == 0 {
+		length = DefaultLength
+	}
+
+	if alphabet == "" {
+		alphabet = defaultAlphabet
+	}
+
+	if length < minLength || length > maxLength {
+		return 0, "", fmt.Errorf("short code length must be between %d and %d", minLength, maxLength)
+	}
+
+	if len(alphabet) < minAlphabetLength {
+		return 0, "", fmt.Errorf("alphabet must contain at least %d characters", minAlphabetLength)
+	}
+
+	for i := 0; i < len(alphabet); i++ {
+		if !isURLSafe(alphabet[i]) {
+			return 0, "", fmt.Errorf("alphabet contains character %q that is not URL-safe", alphabet[i])
+		}
+
+		if strings.IndexByte(alphabet[i+1:], alphabet[i]) >= 0 {
+			return 0, "", fmt.Errorf("alphabet contains duplicate character %q", alphabet[i])
+		}
+	}
+
+	return length, alphabet, nil
+}
+
+// isURLSafe reports whether the character can be used in a path segment without escaping
+func isURLSafe(c byte) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		c == '-' || c == '_'
+}
+
+// encodeNumber writes the number in the positional system of the alphabet
+// The result is left-padded with the zero digit up to the given length
+func encodeNumber(n uint64, alphabet string, length int) string {
+	base := uint64(len(alphabet))
+
+	var digits []byte
+	for n > 0 {
+		digits = append(digits, alphabet[n%base])
+		n /= base
+	}
+
+	for len(digits) < length {
+		digits = append(digits, alphabet[0])
+	}
+
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+
+	return string(digits)
+}
+
+// decodeNumber parses a number written in the positional system of the alphabet
+func decodeNumber(code string, alphabet string) (uint64, error) {
+	base := uint64(len(alphabet))
+
+	var n uint64
+	for i := 0; i < len(code); i++ {
+		digit := strings.IndexByte(alphabet, code[i])
+		if digit < 0 {
+			return 0, fmt.Errorf("character %q is not in the alphabet", code[i])
+		}
+
+		hi, lo := bits.Mul64(n, base)
+		sum, carry := bits.Add64(lo, uint64(digit), 0)
+		if hi != 0 || carry != 0 {
+			return 0, errors.New("short code is out of range")
+		}
+		n = sum
+	}
+
+	return n, nil
+}
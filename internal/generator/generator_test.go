@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSequence is an in-process Sequence starting from one
+type testSequence struct {
+	last atomic.Uint64
+}
+
+func (s *testSequence) Next(_ context.Context) (uint64, error) {
+	return s.last.Add(1), nil
+}
+
+func TestGenerators(t *testing.T) {
+	const originalURL = "https://practicum.yandex.ru"
+
+	tests := []struct {
+		name      string
+		generator func() (ShortCodeGenerator, error)
+		length    int
+		alphabet  string
+	}{
+		{
+			name:      "Hash with defaults",
+			generator: func() (ShortCodeGenerator, error) { return NewHashGenerator(0, "") },
+			length:    DefaultLength,
+			alphabet:  Base64Alphabet,
+		},
+		{
+			name:      "Hash with custom alphabet",
+			generator: func() (ShortCodeGenerator, error) { return NewHashGenerator(10, "0123456789abcdef") },
+			length:    10,
+			alphabet:  "0123456789abcdef",
+		},
+		{
+			name:      "Counter",
+			generator: func() (ShortCodeGenerator, error) { return NewCounterGenerator(&testSequence{}, 5, "") },
+			length:    5,
+			alphabet:  Base62Alphabet,
+		},
+		{
+			name:      "Random",
+			generator: func() (ShortCodeGenerator, error) { return NewRandomGenerator(12, "") },
+			length:    12,
+			alphabet:  Base64Alphabet,
+		},
+		{
+			name: "Hashids",
+			generator: func() (ShortCodeGenerator, error) {
+				return NewHashidsGenerator(&testSequence{}, 8, "", "salt")
+			},
+			length:   8,
+			alphabet: Base62Alphabet,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := tt.generator()
+			require.NoError(t, err)
+
+			seen := make(map[string]struct{})
+			for attempt := 0; attempt < 100; attempt++ {
+				code, err := g.Generate(context.Background(), originalURL, attempt)
+				require.NoError(t, err)
+
+				assert.Len(t, code, tt.length)
+				for _, c := range code {
+					assert.True(t, strings.ContainsRune(tt.alphabet, c), "unexpected character %q", c)
+				}
+
+				seen[code] = struct{}{}
+			}
+
+			assert.Len(t, seen, 100)
+		})
+	}
+}
+
+func TestHashGeneratorKeepsLegacyCodes(t *testing.T) {
+	const originalURL = "https://practicum.yandex.ru"
+
+	g, err := NewHashGenerator(DefaultLength, "")
+	require.NoError(t, err)
+
+	hash := sha1.Sum([]byte(originalURL))
+	want := base64.URLEncoding.EncodeToString(hash[:])[:7]
+
+	for i := 0; i < 3; i++ {
+		code, err := g.Generate(context.Background(), originalURL, 0)
+		require.NoError(t, err)
+		assert.Equal(t, want, code)
+	}
+}
+
+func TestHashidsGeneratorDecode(t *testing.T) {
+	g, err := NewHashidsGenerator(&testSequence{}, DefaultLength, "", "salt")
+	require.NoError(t, err)
+
+	other, err := NewHashidsGenerator(&testSequence{}, DefaultLength, "", "pepper")
+	require.NoError(t, err)
+
+	for _, n := range []uint64{0, 1, 61, 62, 1_000_000, 1<<63 + 12345} {
+		code := g.Encode(n)
+
+		decoded, err := g.Decode(code)
+		require.NoError(t, err)
+		assert.Equal(t, n, decoded)
+
+		assert.NotEqual(t, code, other.Encode(n))
+	}
+
+	_, err = g.Decode("!abcdef")
+	assert.Error(t, err)
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int
+		alphabet string
+	}{
+		{name: "Too short", length: 2},
+		{name: "Too long", length: 64},
+		{name: "Small alphabet", alphabet: "abc"},
+		{name: "Duplicate characters", alphabet: "aabcdefghijklmnop"},
+		{name: "Unsafe characters", alphabet: "abcdefghijklmnop/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRandomGenerator(tt.length, tt.alphabet)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewHashGenerator(30, "")
+	assert.Error(t, err, "hash has fewer bits than the code needs")
+}
@@ -0,0 +1,96 @@
+package generator
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// HashGenerator derives short codes from the SHA1 hash of the original URL
+//
+// The same URL always gets the same code on the first attempt, so repeated
+// shortening is detected by the short code lookup. Later attempts hash the URL
+// with a random salt to get around collisions.
+type HashGenerator struct {
+	length   int
+	alphabet string
+	encoding *base64.Encoding
+}
+
+// NewHashGenerator creates a new HashGenerator instance
+func NewHashGenerator(length int, alphabet string) (*HashGenerator, error) {
+	length, alphabet, err := normalizeOptions(length, alphabet, Base64Alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	if length*bitsPerChar(len(alphabet)) > sha1.Size*8 {
+		return nil, fmt.Errorf("short code length %d exceeds the hash size for the alphabet", length)
+	}
+
+	generator := &HashGenerator{
+		length:   length,
+		alphabet: alphabet,
+	}
+
+	if len(alphabet) == 64 {
+		generator.encoding = base64.NewEncoding(alphabet)
+	}
+
+	return generator, nil
+}
+
+// Generate returns the hash of the original URL, salted on retries
+func (g *HashGenerator) Generate(_ context.Context, originalURL string, attempt int) (string, error) {
+	if attempt > 0 {
+		salted, err := addSalt(originalURL)
+		if err != nil {
+			return "", err
+		}
+		originalURL = salted
+	}
+
+	hash := sha1.Sum([]byte(originalURL))
+
+	return g.encode(hash[:]), nil
+}
+
+// encode writes the hash with the alphabet and keeps the first characters
+// 64 character alphabets use base64 encoding, so the default alphabet keeps the codes of the previous versions
+func (g *HashGenerator) encode(hash []byte) string {
+	if g.encoding != nil {
+		return g.encoding.EncodeToString(hash)[:g.length]
+	}
+
+	n := new(big.Int).SetBytes(hash)
+	base := big.NewInt(int64(len(g.alphabet)))
+	digit := new(big.Int)
+
+	code := make([]byte, g.length)
+	for i := range code {
+		n.DivMod(n, base, digit)
+		code[i] = g.alphabet[digit.Int64()]
+	}
+
+	return string(code)
+}
+
+// addSalt appends random data to avoid hash collisions
+func addSalt(url string) (string, error) {
+	b := make([]byte, 4)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return url + ":" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// bitsPerChar returns the upper bound of hash bits consumed by a single character of the alphabet
+func bitsPerChar(alphabetLength int) int {
+	return bits.Len(uint(alphabetLength - 1))
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// HashidsGenerator encodes numbers of a shared sequence into reversible codes that are hard to guess
+//
+// Like Hashids, every code starts with a lottery character picked by the number.
+// The alphabet is shuffled with the lottery character and the salt, and the number
+// is written in the shuffled alphabet, so consecutive numbers get unrelated codes.
+type HashidsGenerator struct {
+	sequence Sequence
+	length   int
+	alphabet string
+	salt     string
+}
+
+// NewHashidsGenerator creates a new HashidsGenerator instance
+// The length is the minimal length of a code
+func NewHashidsGenerator(sequence Sequence, length int, alphabet string, salt string) (*HashidsGenerator, error) {
+	length, alphabet, err := normalizeOptions(length, alphabet, Base62Alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HashidsGenerator{
+		sequence: sequence,
+		length:   length,
+		alphabet: alphabet,
+		salt:     salt,
+	}, nil
+}
+
+// Generate returns the next number of the sequence encoded into a code
+func (g *HashidsGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	n, err := g.sequence.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Encode(n), nil
+}
+
+// Encode converts the number into a code
+func (g *HashidsGenerator) Encode(n uint64) string {
+	lottery := g.alphabet[n%uint64(len(g.alphabet))]
+
+	return string(lottery) + encodeNumber(n, g.shuffledAlphabet(lottery), g.length-1)
+}
+
+// Decode converts the code back into the number
+func (g *HashidsGenerator) Decode(code string) (uint64, error) {
+	if len(code) < 2 {
+		return 0, errors.New("short code is too short")
+	}
+
+	lottery := code[0]
+	if strings.IndexByte(g.alphabet, lottery) < 0 {
+		return 0, errors.New("short code does not belong to the alphabet")
+	}
+
+	n, err := decodeNumber(code[1:], g.shuffledAlphabet(lottery))
+	if err != nil {
+		return 0, err
+	}
+
+	if g.Encode(n) != code {
+		return 0, errors.New("short code is not canonical")
+	}
+
+	return n, nil
+}
+
+// shuffledAlphabet returns the alphabet shuffled with the lottery character and the salt
+func (g *HashidsGenerator) shuffledAlphabet(lottery byte) string {
+	return consistentShuffle(g.alphabet, string(lottery)+g.salt)
+}
+
+// consistentShuffle deterministically permutes the alphabet by the salt, as Hashids does
+func consistentShuffle(alphabet string, salt string) string {
+	result := []byte(alphabet)
+
+	for i, v, p := len(result)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		p += int(salt[v])
+		j := (int(salt[v]) + v + p) % i
+		result[i], result[j] = result[j], result[i]
+		v++
+	}
+
+	return string(result)
+}
@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"context"
+	"crypto/rand"
+	"math/bits"
+)
+
+// RandomGenerator produces nanoid-style codes of cryptographically random characters
+type RandomGenerator struct {
+	length   int
+	alphabet string
+	mask     byte
+}
+
+// NewRandomGenerator creates a new RandomGenerator instance
+func NewRandomGenerator(length int, alphabet string) (*RandomGenerator, error) {
+	length, alphabet, err := normalizeOptions(length, alphabet, Base64Alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RandomGenerator{
+		length:   length,
+		alphabet: alphabet,
+		mask:     byte(1<<bits.Len(uint(len(alphabet)-1)) - 1),
+	}, nil
+}
+
+// Generate returns a random code
+// Random bytes are masked to the alphabet size and out of range values are rejected to keep characters uniform
+func (g *RandomGenerator) Generate(_ context.Context, _ string, _ int) (string, error) {
+	code := make([]byte, 0, g.length)
+	buf := make([]byte, 2*g.length)
+
+	for len(code) < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			if index := int(b & g.mask); index < len(g.alphabet) {
+				code = append(code, g.alphabet[index])
+
+				if len(code) == g.length {
+					break
+				}
+			}
+		}
+	}
+
+	return string(code), nil
+}
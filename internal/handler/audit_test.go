@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/requestid"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+func TestAuditEvents(t *testing.T) {
+	const (
+		jwtKey    = "test_key"
+		userID    = "user-1"
+		clientIP  = "203.0.113.7"
+		userAgent = "audit-test/1.0"
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	urlRepo := repository.NewURLInMemoryRepository()
+	require.NoError(t, urlRepo.Save(ctx, &model.URLPair{Short: "existing", Long: "https://yandex.ru", UserID: userID}))
+	require.NoError(t, urlRepo.Save(ctx, &model.URLPair{Short: "foreign", Long: "https://ya.ru", UserID: "user-2"}))
+
+	publisher := &recordingPublisher{}
+	deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, publisher, 500)
+	go deleteURLWorker.Run(ctx)
+	clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+	go clickWorker.Run(ctx)
+
+	urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, publisher, service.DefaultTimeouts())
+	urlHandler := NewURLHandler(urlService, database)
+
+	// httptest requests come from 192.0.2.1, which stands for the reverse proxy setting X-Real-IP
+	proxies, err := subnet.ParseTrustedProxies("192.0.2.1")
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(requestid.Middleware())
+	r.Use(subnet.ClientIPMiddleware(proxies))
+	r.Use(audit.OriginMiddleware())
+	r.Use(authorization.AuthMiddleware([]byte(jwtKey)))
+	r.Post("/api/shorten/batch", urlHandler.BatchShortenURL)
+	r.Delete("/api/user/urls", urlHandler.DeleteUserURLs)
+
+	send := func(method string, target string, body string, requestID string) int {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set(requestid.Header, requestID)
+		request.Header.Set("X-Real-IP", clientIP)
+		request.Header.Set("User-Agent", userAgent)
+		request.AddCookie(authCookie(t, jwtKey, userID))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, request)
+
+		return w.Code
+	}
+
+	status := send(http.MethodPost, "/api/shorten/batch", `[
+		{"correlation_id": "1", "original_url": "https://practicum.yandex.ru"},
+		{"correlation_id": "2", "original_url": "https://yandex.ru"}
+	]`, "req-batch")
+	require.Equal(t, http.StatusCreated, status)
+
+	status = send(http.MethodDelete, "/api/user/urls", `["existing", "foreign"]`, "req-delete")
+	require.Equal(t, http.StatusAccepted, status)
+
+	require.Eventually(t, func() bool { return len(publisher.published()) == 5 }, 2*time.Second, 10*time.Millisecond)
+
+	type summary struct {
+		Action    string
+		Outcome   string
+		URL       string
+		Short     string
+		RequestID string
+	}
+
+	var got []summary
+	for _, event := range publisher.published() {
+		assert.Equal(t, audit.SchemaVersion, event.Version)
+		assert.NotZero(t, event.TS)
+		assert.Equal(t, userID, event.UserID)
+
+		if event.Action != audit.ActionDelete {
+			assert.Equal(t, clientIP, event.ClientIP)
+			assert.Equal(t, userAgent, event.UserAgent)
+		}
+
+		short := event.Short
+		if event.Action == audit.ActionShorten && event.Outcome == audit.OutcomeSuccess {
+			assert.NotEmpty(t, short)
+			short = "generated"
+		}
+
+		got = append(got, summary{event.Action, event.Outcome, event.URL, short, event.RequestID})
+	}
+
+	assert.Equal(t, []summary{
+		{audit.ActionShorten, audit.OutcomeSuccess, "https://practicum.yandex.ru", "generated", "req-batch"},
+		{audit.ActionShorten, audit.OutcomeConflict, "https://yandex.ru", "existing", "req-batch"},
+		{audit.ActionDeleteRequest, audit.OutcomeAccepted, "", "existing", "req-delete"},
+		{audit.ActionDeleteRequest, audit.OutcomeAccepted, "", "foreign", "req-delete"},
+		{audit.ActionDelete, audit.OutcomeSuccess, "https://yandex.ru", "existing", "req-delete"},
+	}, got)
+}
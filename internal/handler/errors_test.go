@@ -0,0 +1,409 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/policy"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+)
+
+// errStorageUnavailable is returned by every operation of failingURLRepository
+var errStorageUnavailable = errors.New("storage is unavailable")
+
+// failingURLRepository is a URLRepository whose storage is always unavailable
+type failingURLRepository struct{}
+
+func (failingURLRepository) Save(context.Context, *model.URLPair) error {
+	return errStorageUnavailable
+}
+
+func (failingURLRepository) GetOrCreate(context.Context, *model.URLPair) (*model.URLPair, bool, error) {
+	return nil, false, errStorageUnavailable
+}
+
+func (failingURLRepository) GetByShort(context.Context, string) (*model.URLPair, bool) {
+	return nil, false
+}
+
+func (failingURLRepository) SaveMany(context.Context, []*model.URLPair) (map[string]*model.URLPair, error) {
+	return nil, errStorageUnavailable
+}
+
+func (failingURLRepository) GetAllByUserID(context.Context, string) ([]*model.URLPair, error) {
+	return nil, errStorageUnavailable
+}
+
+func (failingURLRepository) GetPageByUserID(context.Context, repository.UserURLsQuery) ([]*model.URLPair, error) {
+	return nil, errStorageUnavailable
+}
+
+func (failingURLRepository) UpdateRedirect(context.Context, string, model.RedirectSettings) error {
+	return errStorageUnavailable
+}
+
+func (failingURLRepository) DeleteByShorts(context.Context, string, []string) ([]*model.URLPair, error) {
+	return nil, errStorageUnavailable
+}
+
+func (failingURLRepository) DeleteExpired(context.Context, time.Time) (int64, error) {
+	return 0, errStorageUnavailable
+}
+
+func (failingURLRepository) CountURLs(context.Context) (int64, error) {
+	return 0, errStorageUnavailable
+}
+
+func (failingURLRepository) CountUsers(context.Context) (int64, error) {
+	return 0, errStorageUnavailable
+}
+
+func (failingURLRepository) ForEach(context.Context, func(*model.URLPair) error) error {
+	return errStorageUnavailable
+}
+
+func TestErrorResponses(t *testing.T) {
+	const (
+		jwtKey = "test_key"
+		userID = "user-1"
+	)
+
+	type requestData struct {
+		method     string
+		target     string
+		body       string
+		authorized bool
+	}
+	type want struct {
+		statusCode int
+		problem    bool
+		code       appError.ErrorCode
+	}
+	tests := []struct {
+		name        string
+		failingRepo bool
+		requestData requestData
+		want        want
+	}{
+		{
+			name:        "Shorten as JSON: malformed body",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten", body: `{"url":`},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidRequest},
+		},
+		{
+			name:        "Shorten as JSON: invalid URL",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten", body: `{"url": "yandex"}`},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidURL},
+		},
+		{
+			name:        "Shorten as JSON: invalid expiration",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten", body: `{"url": "https://ya.ru", "ttl_seconds": -1}`},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidExpiration},
+		},
+		{
+			name:        "Shorten as JSON: invalid alias",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten", body: `{"url": "https://ya.ru", "alias": "a/b"}`},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidAlias},
+		},
+		{
+			name:        "Shorten as JSON: alias taken",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten", body: `{"url": "https://ya.ru", "alias": "promo"}`},
+			want:        want{statusCode: http.StatusConflict, problem: true, code: appError.CodeAliasTaken},
+		},
+		{
+			name:        "Shorten as JSON: private destination",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten", body: `{"url": "http://169.254.169.254/latest/meta-data"}`},
+			want:        want{statusCode: http.StatusUnprocessableEntity, problem: true, code: appError.CodeDestinationBlocked},
+		},
+		{
+			name:        "Shorten as JSON: scheme not allowed",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten", body: `{"url": "ftp://yandex.ru/file"}`},
+			want:        want{statusCode: http.StatusUnprocessableEntity, problem: true, code: appError.CodeDestinationBlocked},
+		},
+		{
+			name:        "Shorten as JSON: storage failure",
+			failingRepo: true,
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten", body: `{"url": "https://ya.ru"}`},
+			want:        want{statusCode: http.StatusInternalServerError, problem: true, code: appError.CodeInternal},
+		},
+		{
+			name:        "Batch shorten: malformed body",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten/batch", body: `{}`},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidRequest},
+		},
+		{
+			name:        "Batch shorten: invalid URL",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten/batch", body: `[{"correlation_id": "1", "original_url": "yandex"}]`},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidURL},
+		},
+		{
+			name:        "Batch shorten: invalid expiration",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten/batch", body: `[{"correlation_id": "1", "original_url": "https://ya.ru", "expires_at": "2000-01-01T00:00:00Z"}]`},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidExpiration},
+		},
+		{
+			name:        "Batch shorten: loopback destination",
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten/batch", body: `[{"correlation_id": "1", "original_url": "https://ya.ru"}, {"correlation_id": "2", "original_url": "http://localhost:8080/admin"}]`},
+			want:        want{statusCode: http.StatusUnprocessableEntity, problem: true, code: appError.CodeDestinationBlocked},
+		},
+		{
+			name:        "Batch shorten: storage failure",
+			failingRepo: true,
+			requestData: requestData{method: http.MethodPost, target: "/api/shorten/batch", body: `[{"correlation_id": "1", "original_url": "https://ya.ru"}]`},
+			want:        want{statusCode: http.StatusInternalServerError, problem: true, code: appError.CodeInternal},
+		},
+		{
+			name:        "User URLs: unauthorized",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls"},
+			want:        want{statusCode: http.StatusUnauthorized, problem: true, code: appError.CodeUnauthorized},
+		},
+		{
+			name:        "User URLs: storage failure",
+			failingRepo: true,
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls", authorized: true},
+			want:        want{statusCode: http.StatusInternalServerError, problem: true, code: appError.CodeInternal},
+		},
+		{
+			name:        "User URLs: invalid limit",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls?limit=many", authorized: true},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidPagination},
+		},
+		{
+			name:        "User URLs: limit too large",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls?limit=100000", authorized: true},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidPagination},
+		},
+		{
+			name:        "User URLs: invalid cursor",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls?cursor=%21%21", authorized: true},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidPagination},
+		},
+		{
+			name:        "User URLs: unsupported sort",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls?sort=long", authorized: true},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidPagination},
+		},
+		{
+			name:        "User URLs: invalid include_deleted",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls?include_deleted=maybe", authorized: true},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidRequest},
+		},
+		{
+			name:        "Delete user URLs: unauthorized",
+			requestData: requestData{method: http.MethodDelete, target: "/api/user/urls", body: `["promo"]`},
+			want:        want{statusCode: http.StatusUnauthorized, problem: true, code: appError.CodeUnauthorized},
+		},
+		{
+			name:        "Delete user URLs: malformed body",
+			requestData: requestData{method: http.MethodDelete, target: "/api/user/urls", body: `"promo"`, authorized: true},
+			want:        want{statusCode: http.StatusBadRequest, problem: true, code: appError.CodeInvalidRequest},
+		},
+		{
+			name:        "URL stats: unauthorized",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls/promo/stats"},
+			want:        want{statusCode: http.StatusUnauthorized, problem: true, code: appError.CodeUnauthorized},
+		},
+		{
+			name:        "URL stats: unknown URL",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls/unknown/stats", authorized: true},
+			want:        want{statusCode: http.StatusNotFound, problem: true, code: appError.CodeURLNotFound},
+		},
+		{
+			name:        "URL stats: URL of another user",
+			requestData: requestData{method: http.MethodGet, target: "/api/user/urls/foreign/stats", authorized: true},
+			want:        want{statusCode: http.StatusForbidden, problem: true, code: appError.CodeForbidden},
+		},
+		{
+			name:        "Service stats: storage failure",
+			failingRepo: true,
+			requestData: requestData{method: http.MethodGet, target: "/api/internal/stats"},
+			want:        want{statusCode: http.StatusInternalServerError, problem: true, code: appError.CodeInternal},
+		},
+		{
+			name:        "Shorten as text: invalid URL",
+			requestData: requestData{method: http.MethodPost, target: "/", body: "yandex"},
+			want:        want{statusCode: http.StatusBadRequest},
+		},
+		{
+			name:        "Shorten as text: private destination",
+			requestData: requestData{method: http.MethodPost, target: "/", body: "http://10.0.0.1/"},
+			want:        want{statusCode: http.StatusUnprocessableEntity},
+		},
+		{
+			name:        "Shorten as text: storage failure",
+			failingRepo: true,
+			requestData: requestData{method: http.MethodPost, target: "/", body: "https://ya.ru"},
+			want:        want{statusCode: http.StatusInternalServerError},
+		},
+		{
+			name:        "Resolve: unknown URL",
+			requestData: requestData{method: http.MethodGet, target: "/unknown"},
+			want:        want{statusCode: http.StatusNotFound},
+		},
+		{
+			name:        "Resolve: deleted URL",
+			requestData: requestData{method: http.MethodGet, target: "/deleted"},
+			want:        want{statusCode: http.StatusGone},
+		},
+		{
+			name:        "QR code: non-integer size",
+			requestData: requestData{method: http.MethodGet, target: "/promo/qr?size=big"},
+			want:        want{statusCode: http.StatusBadRequest},
+		},
+		{
+			name:        "QR code: unknown URL",
+			requestData: requestData{method: http.MethodGet, target: "/unknown/qr"},
+			want:        want{statusCode: http.StatusNotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var urlRepo repository.URLRepository = failingURLRepository{}
+			if !tt.failingRepo {
+				memoryRepo := repository.NewURLInMemoryRepository()
+				_, err := memoryRepo.SaveMany(ctx, []*model.URLPair{
+					{Short: "promo", Long: "https://yandex.ru", UserID: userID},
+					{Short: "foreign", Long: "https://practicum.yandex.ru", UserID: "user-2"},
+					{Short: "deleted", Long: "https://ya.ru/deleted", UserID: userID, IsDeleted: true},
+				})
+				require.NoError(t, err)
+				urlRepo = memoryRepo
+			}
+
+			clickRepo := repository.NewClickInMemoryRepository()
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(clickRepo, 500)
+			go clickWorker.Run(ctx)
+
+			urlHandler := NewURLHandler(service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts()), database)
+			statsHandler := NewStatsHandler(service.NewStatsService(urlRepo, clickRepo, testConfig.BaseURL, service.DefaultTimeouts()))
+			qrHandler := NewQRHandler(service.NewQRService(urlRepo, testConfig.BaseURL, service.DefaultTimeouts()))
+
+			r := chi.NewRouter()
+			if tt.requestData.authorized {
+				r.Use(authorization.AuthMiddleware([]byte(jwtKey)))
+			}
+			r.Post("/", urlHandler.ShortenURLAsText)
+			r.Get("/{id}", urlHandler.ResolveURL)
+			r.Get("/{id}/qr", qrHandler.GetQRCode)
+			r.Post("/api/shorten", urlHandler.ShortenURLAsJSON)
+			r.Post("/api/shorten/batch", urlHandler.BatchShortenURL)
+			r.Get("/api/user/urls", urlHandler.GetUserURLs)
+			r.Delete("/api/user/urls", urlHandler.DeleteUserURLs)
+			r.Get("/api/user/urls/{id}/stats", statsHandler.GetURLStats)
+			r.Get("/api/internal/stats", statsHandler.GetServiceStats)
+
+			request := httptest.NewRequest(tt.requestData.method, tt.requestData.target, strings.NewReader(tt.requestData.body))
+			if tt.requestData.authorized {
+				request.AddCookie(authCookie(t, jwtKey, userID))
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+
+			if !tt.want.problem {
+				assert.Equal(t, "text/plain; charset=utf-8", result.Header.Get("Content-Type"))
+
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.NotEmpty(t, strings.TrimSpace(string(body)))
+				return
+			}
+
+			assert.Equal(t, appError.ProblemContentType, result.Header.Get("Content-Type"))
+
+			var problem appError.Problem
+			err := json.NewDecoder(result.Body).Decode(&problem)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want.code, problem.Code)
+			assert.Equal(t, "urn:go-url-shortener:problem:"+string(tt.want.code), problem.Type)
+			assert.Equal(t, tt.want.statusCode, problem.Status)
+			assert.Equal(t, request.URL.Path, problem.Instance)
+			assert.NotEmpty(t, problem.Title)
+
+			if tt.want.statusCode >= http.StatusInternalServerError {
+				assert.Empty(t, problem.Detail, "server error details must not be exposed")
+			}
+		})
+	}
+}
+
+// recordingPublisher is an audit.Publisher that keeps the published events
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (p *recordingPublisher) Notify(event audit.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func (p *recordingPublisher) published() []audit.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]audit.Event(nil), p.events...)
+}
+
+func TestBlockedDestinationAudit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	urlRepo := repository.NewURLInMemoryRepository()
+	deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+	go deleteURLWorker.Run(ctx)
+	clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+	go clickWorker.Run(ctx)
+
+	publisher := &recordingPublisher{}
+	urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, publisher, service.DefaultTimeouts())
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url": "http://127.0.0.1/admin"}`))
+	w := httptest.NewRecorder()
+	NewURLHandler(urlService, database).ShortenURLAsJSON(w, request)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	count, err := urlRepo.CountURLs(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, audit.ActionBlock, publisher.events[0].Action)
+	assert.Equal(t, "http://127.0.0.1/admin", publisher.events[0].URL)
+	assert.Equal(t, policy.RulePrivateHost, publisher.events[0].Reason)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,6 +11,9 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/alikhanturusbekov/go-url-shortener/internal/generator"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/policy"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
@@ -33,8 +37,10 @@ func Example() {
 	r := chi.NewRouter()
 
 	repo := repository.NewURLInMemoryRepository()
-	deleteWorker := worker.NewDeleteURLWorker(repo, 10)
-	urlService := service.NewURLService(repo, "http://localhost:8080", deleteWorker, audit.NewNoop())
+	deleteWorker := worker.NewDeleteURLWorker(repo, audit.NewNoop(), 10)
+	clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 10)
+	shortCodeGenerator, _ := generator.NewHashGenerator(generator.DefaultLength, "")
+	urlService := service.NewURLService(repo, "http://localhost:8080", shortCodeGenerator, policy.NewPolicy(policy.DefaultOptions()), deleteWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
 	handler := NewURLHandler(urlService, nil)
 
 	r.Post("/api/shorten", handler.ShortenURLAsJSON)
@@ -54,7 +60,7 @@ func Example() {
 
 	// 2. Resolve short URL
 
-	short, _ := urlService.ShortenURL("https://google.com", "")
+	short, _ := urlService.ShortenURL(context.Background(), model.Request{URL: "https://google.com"}, "")
 	shortID := short[len("http://localhost:8080/"):]
 
 	client := &http.Client{
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	pb "github.com/alikhanturusbekov/go-url-shortener/pkg/api/shortener"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+// GRPCHandler handles gRPC requests related to URLs
+type GRPCHandler struct {
+	pb.UnimplementedShortenerServer
+
+	service  *service.URLService
+	database *sql.DB
+}
+
+// NewGRPCHandler creates a new GRPCHandler instance
+func NewGRPCHandler(service *service.URLService, database *sql.DB) *GRPCHandler {
+	return &GRPCHandler{
+		service:  service,
+		database: database,
+	}
+}
+
+// Shorten creates a short URL for the original URL
+func (h *GRPCHandler) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	url, appErr := h.service.ShortenURL(ctx, model.Request{
+		URL:        req.GetUrl(),
+		Alias:      req.GetAlias(),
+		TTLSeconds: req.TtlSeconds,
+	}, grpcUserID(ctx))
+	if appErr != nil && url == "" {
+		return nil, grpcError(appErr)
+	}
+
+	return &pb.ShortenResponse{
+		Result:   url,
+		Conflict: appErr != nil && appErr.Code == http.StatusConflict,
+	}, nil
+}
+
+// BatchShorten creates short URLs for multiple original URLs
+func (h *GRPCHandler) BatchShorten(ctx context.Context, req *pb.BatchShortenRequest) (*pb.BatchShortenResponse, error) {
+	items := make([]model.BatchShortenURLRequest, 0, len(req.GetItems()))
+	for _, item := range req.GetItems() {
+		correlationID := item.GetCorrelationId()
+		items = append(items, model.BatchShortenURLRequest{
+			CorrelationID: &correlationID,
+			OriginalURL:   item.GetOriginalUrl(),
+		})
+	}
+
+	results, appErr := h.service.BatchShortenURL(ctx, items, grpcUserID(ctx))
+	if appErr != nil {
+		return nil, grpcError(appErr)
+	}
+
+	resp := &pb.BatchShortenResponse{Items: make([]*pb.BatchShortenResponse_Item, 0, len(results))}
+	for _, result := range results {
+		item := &pb.BatchShortenResponse_Item{
+			ShortUrl: result.ShortURL,
+			Conflict: result.Status == model.BatchStatusConflict,
+		}
+		if result.CorrelationID != nil {
+			item.CorrelationId = *result.CorrelationID
+		}
+		resp.Items = append(resp.Items, item)
+	}
+
+	return resp, nil
+}
+
+// Resolve returns the original URL for a short code
+func (h *GRPCHandler) Resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	redirect, appErr := h.service.ResolveShortURL(ctx, req.GetShort(), grpcVisit(ctx))
+	if appErr != nil {
+		return nil, grpcError(appErr)
+	}
+
+	return &pb.ResolveResponse{OriginalUrl: redirect.Location}, nil
+}
+
+// ListUserURLs returns all URLs shortened by the user
+// Calls without a valid token are rejected instead of listing the URLs of the user issued for them
+func (h *GRPCHandler) ListUserURLs(ctx context.Context, _ *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	userID, ok := authorization.UserIDFromContext(ctx)
+	if !ok || authorization.IsNewUser(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "need to authorize to access this method")
+	}
+
+	userURLs, appErr := h.service.GetUserURLs(ctx, userID)
+	if appErr != nil {
+		return nil, grpcError(appErr)
+	}
+
+	resp := &pb.ListUserURLsResponse{Items: make([]*pb.ListUserURLsResponse_Item, 0, len(userURLs))}
+	for _, userURL := range userURLs {
+		resp.Items = append(resp.Items, &pb.ListUserURLsResponse_Item{
+			ShortUrl:    userURL.ShortURL,
+			OriginalUrl: userURL.OriginalURL,
+		})
+	}
+
+	return resp, nil
+}
+
+// DeleteUserURLs schedules deletion of the user's short URLs
+func (h *GRPCHandler) DeleteUserURLs(ctx context.Context, req *pb.DeleteUserURLsRequest) (*pb.DeleteUserURLsResponse, error) {
+	userID, ok := authorization.UserIDFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "need to authorize to access this method")
+	}
+
+	if appErr := h.service.DeleteUserURLs(ctx, userID, req.GetShorts()); appErr != nil {
+		return nil, grpcError(appErr)
+	}
+
+	return &pb.DeleteUserURLsResponse{}, nil
+}
+
+// Ping checks database connectivity
+func (h *GRPCHandler) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if err := h.database.PingContext(ctx); err != nil {
+		return nil, status.Error(codes.Unavailable, "database is unavailable")
+	}
+
+	return &pb.PingResponse{}, nil
+}
+
+// grpcUserID extracts the user ID from call context
+func grpcUserID(ctx context.Context) string {
+	userID, ok := authorization.UserIDFromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	return userID
+}
+
+// grpcVisit builds visit details from call metadata and the client IP resolved by subnet.UnaryServerInterceptor
+// Without the interceptor the peer address is used, proxy metadata is never believed
+func grpcVisit(ctx context.Context) model.Visit {
+	var visit model.Visit
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("user-agent"); len(values) > 0 {
+		visit.UserAgent = values[0]
+	}
+	if values := md.Get("referer"); len(values) > 0 {
+		visit.Referrer = values[0]
+	}
+
+	if ip, ok := subnet.ClientIPFromContext(ctx); ok {
+		visit.ClientIP = ip
+	} else if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err == nil {
+			visit.ClientIP = host
+		}
+	}
+
+	return visit
+}
+
+// AuditOriginInterceptor stores the client address and user agent of every call for audit events
+func AuditOriginInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		visit := grpcVisit(ctx)
+
+		return handler(audit.WithOrigin(ctx, audit.Origin{
+			ClientIP:  visit.ClientIP,
+			UserAgent: visit.UserAgent,
+		}), req)
+	}
+}
+
+// grpcError converts an application error to a gRPC status error
+func grpcError(appErr *appError.HTTPError) error {
+	code := codes.Internal
+
+	switch appErr.Code {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusGone:
+		code = codes.FailedPrecondition
+	case http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	}
+
+	return status.Error(code, appErr.Message)
+}
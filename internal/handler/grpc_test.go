@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	pb "github.com/alikhanturusbekov/go-url-shortener/pkg/api/shortener"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/ratelimit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+func TestGRPCHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := setupGRPCClient(t, ctx)
+
+	t.Run("Shorten and resolve", func(t *testing.T) {
+		var header metadata.MD
+		shortenResp, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://yandex.ru"}, grpc.Header(&header))
+		require.NoError(t, err)
+		assert.False(t, shortenResp.GetConflict())
+		assert.NotEmpty(t, header.Get("authorization"))
+
+		short := shortenResp.GetResult()[len(testConfig.BaseURL)+1:]
+		resolveResp, err := client.Resolve(ctx, &pb.ResolveRequest{Short: short})
+		require.NoError(t, err)
+		assert.Equal(t, "https://yandex.ru", resolveResp.GetOriginalUrl())
+
+		shortenResp, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://yandex.ru"})
+		require.NoError(t, err)
+		assert.True(t, shortenResp.GetConflict())
+	})
+
+	t.Run("List URLs of authorized user", func(t *testing.T) {
+		var header metadata.MD
+		_, err := client.BatchShorten(ctx, &pb.BatchShortenRequest{
+			Items: []*pb.BatchShortenRequest_Item{
+				{CorrelationId: "1", OriginalUrl: "https://practicum.yandex.ru"},
+				{CorrelationId: "2", OriginalUrl: "https://ya.ru"},
+			},
+		}, grpc.Header(&header))
+		require.NoError(t, err)
+
+		authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", header.Get("authorization")[0])
+		listResp, err := client.ListUserURLs(authCtx, &pb.ListUserURLsRequest{})
+		require.NoError(t, err)
+		assert.Len(t, listResp.GetItems(), 2)
+	})
+
+	t.Run("List URLs without a valid token", func(t *testing.T) {
+		_, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		invalidCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer invalid")
+		_, err = client.ListUserURLs(invalidCtx, &pb.ListUserURLsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "yandex.ru"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Unknown short URL", func(t *testing.T) {
+		_, err := client.Resolve(ctx, &pb.ResolveRequest{Short: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Blocked destination", func(t *testing.T) {
+		_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "http://127.1/admin"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   codes.Code
+	}{
+		{name: "Positive case: invalid request", status: http.StatusBadRequest, want: codes.InvalidArgument},
+		{name: "Positive case: blocked destination", status: http.StatusUnprocessableEntity, want: codes.InvalidArgument},
+		{name: "Positive case: rate limit", status: http.StatusTooManyRequests, want: codes.ResourceExhausted},
+		{name: "Positive case: conflict", status: http.StatusConflict, want: codes.AlreadyExists},
+		{name: "Positive case: gone", status: http.StatusGone, want: codes.FailedPrecondition},
+		{name: "Negative case: unmapped status", status: http.StatusTeapot, want: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := grpcError(appError.NewHTTPError(tt.status, "code", "message", nil))
+			assert.Equal(t, tt.want, status.Code(err))
+			assert.Equal(t, "message", status.Convert(err).Message())
+		})
+	}
+}
+
+func TestGRPCVisit(t *testing.T) {
+	md := metadata.Pairs("x-real-ip", "203.0.113.1", "user-agent", "grpc-test/1.0")
+	ctx := peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 1234},
+	})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{
+			name: "Positive case: client IP resolved by the interceptor",
+			ctx:  subnet.WithClientIP(ctx, "192.0.2.10"),
+			want: "192.0.2.10",
+		},
+		{
+			name: "Positive case: peer address without the interceptor",
+			ctx:  ctx,
+			want: "198.51.100.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visit := grpcVisit(tt.ctx)
+			assert.Equal(t, tt.want, visit.ClientIP)
+			assert.Equal(t, "grpc-test/1.0", visit.UserAgent)
+		})
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.KeyByUserOrIP)
+	client := setupGRPCClient(t, ctx, limiter.UnaryServerInterceptor(map[string]ratelimit.MethodLimit{
+		pb.Shortener_Resolve_FullMethodName: {Scope: "resolve", Limit: ratelimit.Every(2, time.Minute)},
+	}))
+
+	for range 2 {
+		_, err := client.Resolve(ctx, &pb.ResolveRequest{Short: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+
+	// Every call gets a new user, which shares the bucket of the client IP
+	var header metadata.MD
+	_, err := client.Resolve(ctx, &pb.ResolveRequest{Short: "unknown"}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, header.Get("retry-after"))
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://yandex.ru"})
+	assert.NoError(t, err, "methods without a limit are not restricted")
+}
+
+func setupGRPCClient(t *testing.T, ctx context.Context, interceptors ...grpc.UnaryServerInterceptor) pb.ShortenerClient {
+	t.Helper()
+
+	repo := repository.NewURLInMemoryRepository()
+	deleteURLWorker := worker.NewDeleteURLWorker(repo, audit.NewNoop(), 10)
+	go deleteURLWorker.Run(ctx)
+	clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 10)
+	go clickWorker.Run(ctx)
+
+	urlService := service.NewURLService(repo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+
+	listener := bufconn.Listen(1024 * 1024)
+	interceptors = append([]grpc.UnaryServerInterceptor{authorization.UnaryServerInterceptor([]byte("test_key"))}, interceptors...)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	pb.RegisterShortenerServer(server, NewGRPCHandler(urlService, nil))
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewShortenerClient(conn)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+)
+
+// qrCodeCacheControl makes clients revalidate QR codes with the ETag on every use,
+// so that codes of deleted or expired links are not served from caches
+const qrCodeCacheControl = "private, no-cache"
+
+// QRHandler handles HTTP requests related to QR codes of short URLs
+type QRHandler struct {
+	service *service.QRService
+}
+
+// NewQRHandler creates a new QRHandler instance
+func NewQRHandler(service *service.QRService) *QRHandler {
+	return &QRHandler{service: service}
+}
+
+// GetQRCode renders a QR code pointing at the short URL
+// Supports size, format (png or svg) and ecc (L, M, Q or H) query parameters
+func (h *QRHandler) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	req := model.QRCodeRequest{
+		Format:          query.Get("format"),
+		ErrorCorrection: query.Get("ecc"),
+	}
+
+	if size := query.Get("size"); size != "" {
+		value, err := strconv.Atoi(size)
+		if err != nil {
+			appError.WriteText(w, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidQRCodeOptions, "Size must be an integer", err))
+			return
+		}
+		req.Size = value
+	}
+
+	qrCode, appErr := h.service.GetQRCode(r.Context(), r.PathValue("id"), req)
+	if appErr != nil {
+		appError.WriteText(w, appErr)
+		return
+	}
+
+	hash := sha256.Sum256(qrCode.Image)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", qrCodeCacheControl)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", qrCode.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(qrCode.Image)))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(qrCode.Image); err != nil {
+		logger.FromContext(r.Context()).Error("failed to write a response", zap.Error(err))
+	}
+}
+
+// etagMatches reports whether the If-None-Match header value matches the ETag
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+)
+
+func TestGetQRCode(t *testing.T) {
+	type want struct {
+		statusCode  int
+		contentType string
+		imageSize   int
+	}
+	tests := []struct {
+		name      string
+		target    string
+		matchETag bool
+		want      want
+	}{
+		{
+			name:   "Positive case: default PNG",
+			target: "/abcdefg/qr",
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "image/png",
+				imageSize:   256,
+			},
+		},
+		{
+			name:   "Positive case: PNG of requested size",
+			target: "/abcdefg/qr?size=512&ecc=H",
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "image/png",
+				imageSize:   512,
+			},
+		},
+		{
+			name:   "Positive case: SVG",
+			target: "/abcdefg/qr?format=svg&ecc=l",
+			want: want{
+				statusCode:  http.StatusOK,
+				contentType: "image/svg+xml",
+			},
+		},
+		{
+			name:      "Positive case: matching ETag",
+			target:    "/abcdefg/qr",
+			matchETag: true,
+			want:      want{statusCode: http.StatusNotModified},
+		},
+		{
+			name:   "Negative case: unknown short URL",
+			target: "/gfedcba/qr",
+			want:   want{statusCode: http.StatusNotFound},
+		},
+		{
+			name:   "Negative case: deleted short URL",
+			target: "/deleted/qr",
+			want:   want{statusCode: http.StatusGone},
+		},
+		{
+			name:   "Negative case: expired short URL",
+			target: "/expired/qr",
+			want:   want{statusCode: http.StatusGone},
+		},
+		{
+			name:   "Negative case: unknown format",
+			target: "/abcdefg/qr?format=gif",
+			want:   want{statusCode: http.StatusBadRequest},
+		},
+		{
+			name:   "Negative case: size out of range",
+			target: "/abcdefg/qr?size=10",
+			want:   want{statusCode: http.StatusBadRequest},
+		},
+		{
+			name:   "Negative case: size above the maximum",
+			target: "/abcdefg/qr?size=2048",
+			want:   want{statusCode: http.StatusBadRequest},
+		},
+		{
+			name:   "Negative case: size is not a number",
+			target: "/abcdefg/qr?size=big",
+			want:   want{statusCode: http.StatusBadRequest},
+		},
+		{
+			name:   "Negative case: unknown error correction level",
+			target: "/abcdefg/qr?ecc=X",
+			want:   want{statusCode: http.StatusBadRequest},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			expiredAt := time.Now().Add(-time.Hour)
+
+			urlRepo := repository.NewURLInMemoryRepository()
+			_, err := urlRepo.SaveMany(ctx, []*model.URLPair{
+				{Short: "abcdefg", Long: "https://yandex.ru", UserID: "user-1"},
+				{Short: "deleted", Long: "https://ya.ru", UserID: "user-1", IsDeleted: true},
+				{Short: "expired", Long: "https://practicum.yandex.ru", UserID: "user-1", ExpiresAt: &expiredAt},
+			})
+			require.NoError(t, err)
+
+			r := chi.NewRouter()
+			r.Get("/{id}/qr", NewQRHandler(service.NewQRService(urlRepo, testConfig.BaseURL, service.DefaultTimeouts())).GetQRCode)
+
+			request := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.matchETag {
+				first := httptest.NewRecorder()
+				r.ServeHTTP(first, httptest.NewRequest(http.MethodGet, tt.target, nil))
+				request.Header.Set("If-None-Match", first.Header().Get("ETag"))
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+
+			if tt.want.statusCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, tt.want.contentType, result.Header.Get("Content-Type"))
+			assert.NotEmpty(t, result.Header.Get("ETag"))
+			assert.Equal(t, "private, no-cache", result.Header.Get("Cache-Control"))
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+
+			if tt.want.imageSize != 0 {
+				img, err := png.Decode(bytes.NewReader(body))
+				require.NoError(t, err)
+				assert.Equal(t, tt.want.imageSize, img.Bounds().Dx())
+				assert.Equal(t, tt.want.imageSize, img.Bounds().Dy())
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+)
+
+func TestResolveURLRedirectSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		long         string
+		redirect     model.RedirectSettings
+		query        string
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name:         "Positive case: default settings redirect with 307",
+			long:         "https://yandex.ru/search?text=go",
+			query:        "ref=mail",
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://yandex.ru/search?text=go",
+		},
+		{
+			name:         "Positive case: permanent redirect with 301",
+			long:         "https://yandex.ru",
+			redirect:     model.RedirectSettings{Type: http.StatusMovedPermanently},
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://yandex.ru",
+		},
+		{
+			name:         "Positive case: found redirect with 302",
+			long:         "https://yandex.ru",
+			redirect:     model.RedirectSettings{Type: http.StatusFound},
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://yandex.ru",
+		},
+		{
+			name:         "Positive case: permanent redirect with 308",
+			long:         "https://yandex.ru",
+			redirect:     model.RedirectSettings{Type: http.StatusPermanentRedirect},
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://yandex.ru",
+		},
+		{
+			name:         "Positive case: query is passed through",
+			long:         "https://yandex.ru/search?text=go",
+			redirect:     model.RedirectSettings{PassQuery: true},
+			query:        "ref=mail&text=rust",
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://yandex.ru/search?ref=mail&text=go&text=rust",
+		},
+		{
+			name:         "Positive case: passthrough without request query keeps destination",
+			long:         "https://yandex.ru/search?text=go",
+			redirect:     model.RedirectSettings{PassQuery: true},
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://yandex.ru/search?text=go",
+		},
+		{
+			name: "Positive case: UTM parameters are appended",
+			long: "https://yandex.ru/search?text=go",
+			redirect: model.RedirectSettings{
+				UTM: &model.UTMParams{Source: "newsletter", Medium: "email", Campaign: "spring sale"},
+			},
+			query:        "ref=mail",
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://yandex.ru/search?text=go&utm_campaign=spring+sale&utm_medium=email&utm_source=newsletter",
+		},
+		{
+			name: "Positive case: UTM parameters override destination and request ones",
+			long: "https://yandex.ru/?utm_source=old",
+			redirect: model.RedirectSettings{
+				Type:      http.StatusFound,
+				PassQuery: true,
+				UTM:       &model.UTMParams{Source: "newsletter"},
+			},
+			query:        "utm_source=spoofed&ref=mail",
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://yandex.ru/?ref=mail&utm_source=newsletter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			urlRepo := repository.NewURLInMemoryRepository()
+			require.NoError(t, urlRepo.Save(ctx, &model.URLPair{Short: "abcdefg", Long: tt.long, Redirect: tt.redirect}))
+
+			urlService := newRedirectTestService(t, ctx, urlRepo)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/{id}", NewURLHandler(urlService, database).ResolveURL)
+
+			target := "/abcdefg"
+			if tt.query != "" {
+				target += "?" + tt.query
+			}
+			request := httptest.NewRequest(http.MethodGet, target, nil)
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, result.StatusCode)
+			assert.Equal(t, tt.wantLocation, result.Header.Get("Location"))
+		})
+	}
+}
+
+func TestShortenURLWithRedirectSettings(t *testing.T) {
+	const jwtKey = "test_key"
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantCode     appError.ErrorCode
+		wantRedirect int
+		wantLocation string
+	}{
+		{
+			name:         "Positive case: settings are stored at shorten time",
+			body:         `{"url":"https://yandex.ru","redirect":{"type":301,"utm":{"source":"ads"}}}`,
+			wantStatus:   http.StatusCreated,
+			wantRedirect: http.StatusMovedPermanently,
+			wantLocation: "https://yandex.ru?utm_source=ads",
+		},
+		{
+			name:         "Positive case: omitted settings default to 307",
+			body:         `{"url":"https://yandex.ru"}`,
+			wantStatus:   http.StatusCreated,
+			wantRedirect: http.StatusTemporaryRedirect,
+			wantLocation: "https://yandex.ru",
+		},
+		{
+			name:       "Negative case: unsupported redirect type",
+			body:       `{"url":"https://yandex.ru","redirect":{"type":303}}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   appError.CodeInvalidRedirect,
+		},
+		{
+			name:       "Negative case: UTM value is too long",
+			body:       `{"url":"https://yandex.ru","redirect":{"utm":{"campaign":"` + strings.Repeat("a", 257) + `"}}}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   appError.CodeInvalidRedirect,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			urlService := newRedirectTestService(t, ctx, repository.NewURLInMemoryRepository())
+			urlHandler := NewURLHandler(urlService, database)
+
+			r := chi.NewRouter()
+			r.Use(authorization.AuthMiddleware([]byte(jwtKey)))
+			r.Post("/api/shorten", urlHandler.ShortenURLAsJSON)
+			r.Get("/{id}", urlHandler.ResolveURL)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			require.Equal(t, tt.wantStatus, result.StatusCode)
+
+			if tt.wantCode != "" {
+				var problem appError.Problem
+				require.NoError(t, json.NewDecoder(result.Body).Decode(&problem))
+				assert.Equal(t, tt.wantCode, problem.Code)
+				return
+			}
+
+			var resp model.Response
+			require.NoError(t, json.NewDecoder(result.Body).Decode(&resp))
+
+			request = httptest.NewRequest(http.MethodGet, strings.TrimPrefix(resp.Result, testConfig.BaseURL), nil)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			resolved := w.Result()
+			defer resolved.Body.Close()
+
+			assert.Equal(t, tt.wantRedirect, resolved.StatusCode)
+			assert.Equal(t, tt.wantLocation, resolved.Header.Get("Location"))
+		})
+	}
+}
+
+func TestUpdateRedirectSettings(t *testing.T) {
+	const (
+		jwtKey = "test_key"
+		owner  = "user-1"
+	)
+
+	tests := []struct {
+		name         string
+		userID       string
+		short        string
+		body         string
+		wantStatus   int
+		wantCode     appError.ErrorCode
+		wantRedirect int
+		wantLocation string
+	}{
+		{
+			name:         "Positive case: owner changes redirect settings",
+			userID:       owner,
+			short:        "abcdefg",
+			body:         `{"type":308,"pass_query":true,"utm":{"medium":"qr"}}`,
+			wantStatus:   http.StatusOK,
+			wantRedirect: http.StatusPermanentRedirect,
+			wantLocation: "https://yandex.ru?ref=mail&utm_medium=qr",
+		},
+		{
+			name:         "Positive case: empty settings reset to defaults",
+			userID:       owner,
+			short:        "abcdefg",
+			body:         `{}`,
+			wantStatus:   http.StatusOK,
+			wantRedirect: http.StatusTemporaryRedirect,
+			wantLocation: "https://yandex.ru",
+		},
+		{
+			name:       "Negative case: URL belongs to another user",
+			userID:     "user-2",
+			short:      "abcdefg",
+			body:       `{"type":301}`,
+			wantStatus: http.StatusForbidden,
+			wantCode:   appError.CodeForbidden,
+		},
+		{
+			name:       "Negative case: URL does not exist",
+			userID:     owner,
+			short:      "missing",
+			body:       `{"type":301}`,
+			wantStatus: http.StatusNotFound,
+			wantCode:   appError.CodeURLNotFound,
+		},
+		{
+			name:       "Negative case: unsupported redirect type",
+			userID:     owner,
+			short:      "abcdefg",
+			body:       `{"type":200}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   appError.CodeInvalidRedirect,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			filePath := filepath.Join(t.TempDir(), "urls.json")
+			urlRepo, err := repository.NewURLFileRepository(filePath, repository.FileRepositoryOptions{})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = urlRepo.Close() })
+			require.NoError(t, urlRepo.Save(ctx, &model.URLPair{
+				Short:    "abcdefg",
+				Long:     "https://yandex.ru",
+				UserID:   owner,
+				Redirect: model.RedirectSettings{Type: http.StatusFound, UTM: &model.UTMParams{Source: "ads"}},
+			}))
+
+			urlHandler := NewURLHandler(newRedirectTestService(t, ctx, urlRepo), database)
+
+			r := chi.NewRouter()
+			r.Use(authorization.AuthMiddleware([]byte(jwtKey)))
+			r.Put("/api/user/urls/{id}/redirect", urlHandler.UpdateRedirectSettings)
+
+			request := httptest.NewRequest(http.MethodPut, "/api/user/urls/"+tt.short+"/redirect", strings.NewReader(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+			request.AddCookie(authCookie(t, jwtKey, tt.userID))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			require.Equal(t, tt.wantStatus, result.StatusCode)
+
+			if tt.wantCode != "" {
+				var problem appError.Problem
+				require.NoError(t, json.NewDecoder(result.Body).Decode(&problem))
+				assert.Equal(t, tt.wantCode, problem.Code)
+				return
+			}
+
+			var settings model.RedirectSettings
+			require.NoError(t, json.NewDecoder(result.Body).Decode(&settings))
+			assert.Equal(t, tt.wantRedirect, settings.Type)
+
+			// The change is read back from the file after a restart
+			reloaded, err := repository.NewURLFileRepository(filePath, repository.FileRepositoryOptions{})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = reloaded.Close() })
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/{id}", NewURLHandler(newRedirectTestService(t, ctx, reloaded), database).ResolveURL)
+
+			w = httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abcdefg?ref=mail", nil))
+
+			resolved := w.Result()
+			defer resolved.Body.Close()
+
+			assert.Equal(t, tt.wantRedirect, resolved.StatusCode)
+			assert.Equal(t, tt.wantLocation, resolved.Header.Get("Location"))
+		})
+	}
+}
+
+// newRedirectTestService builds a URL service with running workers over the repository
+func newRedirectTestService(t *testing.T, ctx context.Context, urlRepo repository.URLRepository) *service.URLService {
+	t.Helper()
+
+	deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+	go deleteURLWorker.Run(ctx)
+	clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+	go clickWorker.Run(ctx)
+
+	return service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+)
+
+// StatsHandler handles HTTP requests related to URL statistics
+type StatsHandler struct {
+	service *service.StatsService
+}
+
+// NewStatsHandler creates a new StatsHandler instance
+func NewStatsHandler(service *service.StatsService) *StatsHandler {
+	return &StatsHandler{service: service}
+}
+
+// GetURLStats returns click statistics of a short URL owned by the user
+// Errors are returned as RFC 7807 problem details
+func (h *StatsHandler) GetURLStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authorization.UserIDFromContext(r.Context())
+	if !ok {
+		appError.WriteProblem(w, r, errUnauthorized)
+		return
+	}
+
+	stats, appErr := h.service.GetURLStats(r.Context(), r.PathValue("id"), userID)
+	if appErr != nil {
+		appError.WriteProblem(w, r, appErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		logger.FromContext(r.Context()).Error("failed to encode response", zap.Error(err))
+		return
+	}
+}
+
+// GetServiceStats returns the number of shortened URLs and users of the service
+// Errors are returned as RFC 7807 problem details
+func (h *StatsHandler) GetServiceStats(w http.ResponseWriter, r *http.Request) {
+	stats, appErr := h.service.GetServiceStats(r.Context())
+	if appErr != nil {
+		appError.WriteProblem(w, r, appErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		logger.FromContext(r.Context()).Error("failed to encode response", zap.Error(err))
+		return
+	}
+}
@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+func TestGetURLStats(t *testing.T) {
+	const (
+		jwtKey  = "test_key"
+		ownerID = "7d9b1c2e-0000-4000-8000-000000000001"
+	)
+
+	type want struct {
+		statusCode int
+		stats      *model.URLStats
+	}
+	tests := []struct {
+		name      string
+		targetURL string
+		userID    string
+		want      want
+	}{
+		{
+			name:      "Positive case: owner gets statistics",
+			targetURL: "abcdefg",
+			userID:    ownerID,
+			want: want{
+				statusCode: http.StatusOK,
+				stats: &model.URLStats{
+					ShortURL:       testConfig.BaseURL + "/abcdefg",
+					TotalClicks:    3,
+					UniqueVisitors: 2,
+					Daily: []model.DailyClicks{
+						{Date: "2025-01-01", Clicks: 2},
+						{Date: "2025-01-02", Clicks: 1},
+					},
+				},
+			},
+		},
+		{
+			name:      "Negative case: URL belongs to another user",
+			targetURL: "abcdefg",
+			userID:    "7d9b1c2e-0000-4000-8000-000000000002",
+			want:      want{statusCode: http.StatusForbidden},
+		},
+		{
+			name:      "Negative case: URL does not exist",
+			targetURL: "gfedcba",
+			userID:    ownerID,
+			want:      want{statusCode: http.StatusNotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			urlRepo := repository.NewURLInMemoryRepository()
+			err := urlRepo.Save(ctx, &model.URLPair{Short: "abcdefg", Long: "https://yandex.ru", UserID: ownerID})
+			require.NoError(t, err)
+
+			clickRepo := repository.NewClickInMemoryRepository()
+			err = clickRepo.SaveClicks(ctx, []*model.Click{
+				{Short: "abcdefg", ClickedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC), VisitorID: "a"},
+				{Short: "abcdefg", ClickedAt: time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC), VisitorID: "b"},
+				{Short: "abcdefg", ClickedAt: time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC), VisitorID: "a"},
+			})
+			require.NoError(t, err)
+
+			statsService := service.NewStatsService(urlRepo, clickRepo, testConfig.BaseURL, service.DefaultTimeouts())
+
+			r := chi.NewRouter()
+			r.Use(authorization.AuthMiddleware([]byte(jwtKey)))
+			r.Get("/api/user/urls/{id}/stats", NewStatsHandler(statsService).GetURLStats)
+
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls/"+tt.targetURL+"/stats", nil)
+			request.AddCookie(authCookie(t, jwtKey, tt.userID))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+
+			if tt.want.stats != nil {
+				var stats model.URLStats
+				err := json.NewDecoder(result.Body).Decode(&stats)
+				require.NoError(t, err)
+				assert.Equal(t, *tt.want.stats, stats)
+			}
+		})
+	}
+}
+
+func TestGetServiceStats(t *testing.T) {
+	// httptest requests come from 192.0.2.1
+	proxies, err := subnet.ParseTrustedProxies("192.0.2.1")
+	require.NoError(t, err)
+
+	type want struct {
+		statusCode int
+		stats      *model.ServiceStats
+	}
+	tests := []struct {
+		name          string
+		trustedSubnet string
+		proxies       subnet.TrustedProxies
+		realIP        string
+		want          want
+	}{
+		{
+			name:          "Positive case: client inside trusted subnet",
+			trustedSubnet: "192.168.1.0/24",
+			proxies:       proxies,
+			realIP:        "192.168.1.15",
+			want: want{
+				statusCode: http.StatusOK,
+				stats:      &model.ServiceStats{URLs: 2, Users: 2},
+			},
+		},
+		{
+			name:          "Negative case: client outside trusted subnet",
+			trustedSubnet: "192.168.1.0/24",
+			proxies:       proxies,
+			realIP:        "10.0.0.1",
+			want:          want{statusCode: http.StatusForbidden},
+		},
+		{
+			name:          "Negative case: missing X-Real-IP header",
+			trustedSubnet: "192.168.1.0/24",
+			proxies:       proxies,
+			want:          want{statusCode: http.StatusForbidden},
+		},
+		{
+			name:          "Negative case: X-Real-IP of a client that is not a trusted proxy",
+			trustedSubnet: "192.168.1.0/24",
+			realIP:        "192.168.1.15",
+			want:          want{statusCode: http.StatusForbidden},
+		},
+		{
+			name:    "Negative case: trusted subnet is not configured",
+			proxies: proxies,
+			realIP:  "192.168.1.15",
+			want:    want{statusCode: http.StatusForbidden},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			urlRepo := repository.NewURLInMemoryRepository()
+			_, err := urlRepo.SaveMany(ctx, []*model.URLPair{
+				{Short: "abcdefg", Long: "https://yandex.ru", UserID: "user-1"},
+				{Short: "bcdefgh", Long: "https://ya.ru", UserID: "user-1"},
+				{Short: "cdefghi", Long: "https://practicum.yandex.ru", UserID: "user-2"},
+			})
+			require.NoError(t, err)
+			_, err = urlRepo.DeleteByShorts(ctx, "user-1", []string{"bcdefgh"})
+			require.NoError(t, err)
+
+			statsService := service.NewStatsService(urlRepo, repository.NewClickInMemoryRepository(), testConfig.BaseURL, service.DefaultTimeouts())
+
+			trusted, err := subnet.ParseTrustedSubnet(tt.trustedSubnet)
+			require.NoError(t, err)
+
+			r := chi.NewRouter()
+			r.Use(subnet.ClientIPMiddleware(tt.proxies))
+			r.With(subnet.TrustedSubnetMiddleware(trusted)).
+				Get("/api/internal/stats", NewStatsHandler(statsService).GetServiceStats)
+
+			request := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			if tt.realIP != "" {
+				request.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+
+			if tt.want.statusCode == http.StatusForbidden {
+				assert.Equal(t, appError.ProblemContentType, result.Header.Get("Content-Type"))
+			}
+
+			if tt.want.stats != nil {
+				var stats model.ServiceStats
+				err := json.NewDecoder(result.Body).Decode(&stats)
+				require.NoError(t, err)
+				assert.Equal(t, *tt.want.stats, stats)
+			}
+		})
+	}
+}
+
+func TestResolveURLVisitorID(t *testing.T) {
+	// httptest requests come from 192.0.2.1
+	proxies, err := subnet.ParseTrustedProxies("192.0.2.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		proxies            subnet.TrustedProxies
+		wantUniqueVisitors int64
+	}{
+		{
+			name:               "Positive case: client address headers of a direct client are ignored",
+			wantUniqueVisitors: 1,
+		},
+		{
+			name:               "Positive case: client address headers of a trusted proxy are believed",
+			proxies:            proxies,
+			wantUniqueVisitors: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			urlRepo := repository.NewURLInMemoryRepository()
+			require.NoError(t, urlRepo.Save(ctx, &model.URLPair{Short: "abcdefg", Long: "https://yandex.ru", UserID: "user-1"}))
+
+			clickRepo := repository.NewClickInMemoryRepository()
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(clickRepo, 500)
+			go clickWorker.Run(ctx)
+
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+
+			r := chi.NewRouter()
+			r.Use(subnet.ClientIPMiddleware(tt.proxies))
+			r.Get("/{id}", NewURLHandler(urlService, database).ResolveURL)
+
+			for _, ip := range []string{"203.0.113.7", "198.51.100.7", "192.0.2.200"} {
+				request := httptest.NewRequest(http.MethodGet, "/abcdefg", nil)
+				request.Header.Set("X-Real-IP", ip)
+				request.Header.Set("X-Forwarded-For", ip)
+				request.Header.Set("User-Agent", "visitor-test/1.0")
+
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, request)
+				require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+			}
+
+			var stats *model.URLStats
+			require.Eventually(t, func() bool {
+				stats, err = clickRepo.GetStats(ctx, "abcdefg")
+				return err == nil && stats.TotalClicks == 3
+			}, 3*time.Second, 10*time.Millisecond)
+			assert.Equal(t, tt.wantUniqueVisitors, stats.UniqueVisitors)
+		})
+	}
+}
+
+func authCookie(t *testing.T, key string, userID string) *http.Cookie {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &authorization.Claims{UserID: userID})
+	signed, err := token.SignedString([]byte(key))
+	require.NoError(t, err)
+
+	return &http.Cookie{Name: "auth_token", Value: signed}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/requestid"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
+)
+
+func TestTracing(t *testing.T) {
+	const parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	type want struct {
+		requestID string
+		traceID   string
+	}
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    want
+	}{
+		{
+			name:    "Positive case: new request ID and trace",
+			headers: map[string]string{},
+		},
+		{
+			name: "Positive case: client request ID and trace are continued",
+			headers: map[string]string{
+				requestid.Header: "client-request-1",
+				"traceparent":    "00-" + parentTraceID + "-00f067aa0ba902b7-01",
+			},
+			want: want{
+				requestID: "client-request-1",
+				traceID:   parentTraceID,
+			},
+		},
+		{
+			name: "Negative case: invalid client request ID is replaced",
+			headers: map[string]string{
+				requestid.Header: "bad request id",
+			},
+		},
+	}
+
+	_, err := tracing.Setup(context.Background(), "test", tracing.ExporterNone, "")
+	require.NoError(t, err)
+
+	previousProvider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previousProvider) })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+			urlRepo := repository.NewInstrumentedURLRepository(repository.NewURLInMemoryRepository(), "memory")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+			go clickWorker.Run(ctx)
+
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+
+			r := chi.NewRouter()
+			r.Use(tracing.HTTPMiddleware())
+			r.Use(requestid.Middleware())
+			r.Post("/api/shorten", NewURLHandler(urlService, database).ShortenURLAsJSON)
+
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url": "https://yandex.ru"}`))
+			request.Header.Set("Content-Type", "application/json")
+			for name, value := range tt.headers {
+				request.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, http.StatusCreated, result.StatusCode)
+
+			responseRequestID := result.Header.Get(requestid.Header)
+			if tt.want.requestID != "" {
+				assert.Equal(t, tt.want.requestID, responseRequestID)
+			} else {
+				assert.NotEmpty(t, responseRequestID)
+				assert.NotEqual(t, tt.headers[requestid.Header], responseRequestID)
+			}
+
+			spans := make(map[string]sdktrace.ReadOnlySpan)
+			for _, span := range recorder.Ended() {
+				spans[span.Name()] = span
+			}
+
+			require.Contains(t, spans, "POST /api/shorten")
+			require.Contains(t, spans, "URLService.ShortenURL")
+			require.Contains(t, spans, "repository.get_or_create")
+
+			serverSpan := spans["POST /api/shorten"]
+			if tt.want.traceID != "" {
+				assert.Equal(t, tt.want.traceID, serverSpan.SpanContext().TraceID().String())
+			}
+
+			assert.Equal(t, serverSpan.SpanContext().SpanID(), spans["URLService.ShortenURL"].Parent().SpanID())
+			assert.Equal(t, spans["URLService.ShortenURL"].SpanContext().SpanID(), spans["repository.get_or_create"].Parent().SpanID())
+			assert.Equal(t, serverSpan.SpanContext().TraceID(), spans["repository.get_or_create"].SpanContext().TraceID())
+		})
+	}
+}
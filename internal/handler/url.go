@@ -8,6 +8,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
@@ -15,7 +16,17 @@ import (
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+// errUnauthorized is returned by endpoints that require an authorized user
+var errUnauthorized = appError.NewHTTPError(
+	http.StatusUnauthorized,
+	appError.CodeUnauthorized,
+	"Need to authorize to access this method",
+	nil,
 )
 
 // URLHandler handles HTTP requests related to URLs
@@ -46,10 +57,11 @@ func (h *URLHandler) Ping(w http.ResponseWriter, r *http.Request) {
 }
 
 // ShortenURLAsText creates a shortened URL from plain text input
+// Errors are returned as plain text
 func (h *URLHandler) ShortenURLAsText(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		appError.WriteText(w, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRequest, "Failed to read request body", err))
 		return
 	}
 
@@ -60,44 +72,45 @@ func (h *URLHandler) ShortenURLAsText(w http.ResponseWriter, r *http.Request) {
 		}
 	}(r.Body)
 
-	url, appError := h.service.ShortenURL(string(body), h.getUserID(r))
-	if appError != nil && url == "" {
-		http.Error(w, appError.GetFullMessage(), appError.Code)
+	url, appErr := h.service.ShortenURL(r.Context(), model.Request{URL: string(body)}, h.getUserID(r))
+	if appErr != nil && url == "" {
+		appError.WriteText(w, appErr)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/plain")
-	if appError != nil {
-		w.WriteHeader(appError.Code)
+	if appErr != nil {
+		w.WriteHeader(appErr.Code)
 	} else {
 		w.WriteHeader(http.StatusCreated)
 	}
 
 	_, err = w.Write([]byte(url))
 	if err != nil {
-		http.Error(w, "failed to write a response", http.StatusBadRequest)
+		logger.FromContext(r.Context()).Error("failed to write a response", zap.Error(err))
 	}
 }
 
 // ShortenURLAsJSON creates a shortened URL from JSON input
+// Errors are returned as RFC 7807 problem details
 func (h *URLHandler) ShortenURLAsJSON(w http.ResponseWriter, r *http.Request) {
 	var req model.Request
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&req); err != nil {
-		logger.Log.Error("cannot decode request JSON body", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error("cannot decode request JSON body", zap.Error(err))
+		appError.WriteProblem(w, r, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRequest, "Invalid request body", err))
 		return
 	}
 
-	url, appError := h.service.ShortenURL(req.URL, h.getUserID(r))
-	if appError != nil && url == "" {
-		http.Error(w, appError.GetFullMessage(), appError.Code)
+	url, appErr := h.service.ShortenURL(r.Context(), req, h.getUserID(r))
+	if appErr != nil && url == "" {
+		appError.WriteProblem(w, r, appErr)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if appError != nil {
-		w.WriteHeader(appError.Code)
+	if appErr != nil {
+		w.WriteHeader(appErr.Code)
 	} else {
 		w.WriteHeader(http.StatusCreated)
 	}
@@ -106,37 +119,46 @@ func (h *URLHandler) ShortenURLAsJSON(w http.ResponseWriter, r *http.Request) {
 
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(resp); err != nil {
-		http.Error(w, "failed to write a response", http.StatusBadRequest)
+		logger.FromContext(r.Context()).Error("failed to write a response", zap.Error(err))
 		return
 	}
 }
 
 // ResolveURL redirects to the original URL
+// Errors are returned as plain text
 func (h *URLHandler) ResolveURL(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	url, appError := h.service.ResolveShortURL(id)
-	if appError != nil {
-		http.Error(w, appError.GetFullMessage(), appError.Code)
+	visit := model.Visit{
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		ClientIP:  subnet.ClientIP(r),
+		Query:     r.URL.RawQuery,
+	}
+
+	redirect, appErr := h.service.ResolveShortURL(r.Context(), id, visit)
+	if appErr != nil {
+		appError.WriteText(w, appErr)
 		return
 	}
 
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	http.Redirect(w, r, redirect.Location, redirect.StatusCode)
 }
 
 // BatchShortenURL creates multiple shortened URLs in one request
+// Errors are returned as RFC 7807 problem details
 func (h *URLHandler) BatchShortenURL(w http.ResponseWriter, r *http.Request) {
 	var req []model.BatchShortenURLRequest
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&req); err != nil {
-		logger.Log.Error("cannot decode request JSON body", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error("cannot decode request JSON body", zap.Error(err))
+		appError.WriteProblem(w, r, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRequest, "Invalid request body", err))
 		return
 	}
 
-	results, err := h.service.BatchShortenURL(req, h.getUserID(r))
-	if err != nil {
-		http.Error(w, "failed to batch shorten", http.StatusInternalServerError)
+	results, appErr := h.service.BatchShortenURL(r.Context(), req, h.getUserID(r))
+	if appErr != nil {
+		appError.WriteProblem(w, r, appErr)
 		return
 	}
 
@@ -144,57 +166,146 @@ func (h *URLHandler) BatchShortenURL(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 
 	if err := json.NewEncoder(w).Encode(results); err != nil {
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Error("failed to encode response", zap.Error(err))
 		return
 	}
 }
 
-// GetUserURLs returns all URLs shortened by the user
+// GetUserURLs returns a page of URLs shortened by the user
+// The page is selected with the limit, cursor, sort, q and include_deleted query parameters,
+// the next page is advertised in the Link header, every URL is returned when neither limit nor cursor is given
+// Errors are returned as RFC 7807 problem details
 func (h *URLHandler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := authorization.UserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "need to authorize to access this method", http.StatusUnauthorized)
+		appError.WriteProblem(w, r, errUnauthorized)
 		return
 	}
 
-	userURLs, err := h.service.GetUserURLs(userID)
-	if err != nil {
-		http.Error(w, "failed to fetch user URLs", http.StatusInternalServerError)
+	query, appErr := parseUserURLsQuery(r)
+	if appErr != nil {
+		appError.WriteProblem(w, r, appErr)
+		return
+	}
+
+	page, appErr := h.service.ListUserURLs(r.Context(), userID, query)
+	if appErr != nil {
+		appError.WriteProblem(w, r, appErr)
 		return
 	}
 
-	if len(userURLs) == 0 {
+	if len(page.Items) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	if page.NextCursor != "" {
+		w.Header().Set("Link", nextPageLink(r, page.NextCursor))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	if err := json.NewEncoder(w).Encode(userURLs); err != nil {
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(page.Items); err != nil {
+		logger.FromContext(r.Context()).Error("failed to encode response", zap.Error(err))
+		return
+	}
+}
+
+// parseUserURLsQuery reads user URL listing parameters from the query string
+func parseUserURLsQuery(r *http.Request) (model.UserURLsQuery, *appError.HTTPError) {
+	values := r.URL.Query()
+
+	query := model.UserURLsQuery{
+		Cursor: values.Get("cursor"),
+		Sort:   values.Get("sort"),
+		Search: values.Get("q"),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			return query, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidPagination, "Invalid limit", err)
+		}
+		if parsed <= 0 {
+			return query, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidPagination, "Limit must be positive", nil)
+		}
+		query.Limit = parsed
+	}
+
+	if includeDeleted := values.Get("include_deleted"); includeDeleted != "" {
+		parsed, err := strconv.ParseBool(includeDeleted)
+		if err != nil {
+			return query, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRequest, "Invalid include_deleted", err)
+		}
+		query.IncludeDeleted = parsed
+	}
+
+	return query, nil
+}
+
+// nextPageLink builds an RFC 8288 Link header value pointing to the next page
+// The other query parameters of the request are kept
+func nextPageLink(r *http.Request, cursor string) string {
+	next := *r.URL
+	values := next.Query()
+	values.Set("cursor", cursor)
+	next.RawQuery = values.Encode()
+
+	return "<" + next.RequestURI() + `>; rel="next"`
+}
+
+// UpdateRedirectSettings replaces the redirect settings of a URL shortened by the user
+// Errors are returned as RFC 7807 problem details
+func (h *URLHandler) UpdateRedirectSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authorization.UserIDFromContext(r.Context())
+	if !ok {
+		appError.WriteProblem(w, r, errUnauthorized)
+		return
+	}
+
+	var settings model.RedirectSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		logger.FromContext(r.Context()).Error("cannot decode request JSON body", zap.Error(err))
+		appError.WriteProblem(w, r, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRequest, "Invalid request body", err))
+		return
+	}
+
+	updated, appErr := h.service.UpdateRedirectSettings(r.Context(), userID, r.PathValue("id"), settings)
+	if appErr != nil {
+		appError.WriteProblem(w, r, appErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		logger.FromContext(r.Context()).Error("failed to encode response", zap.Error(err))
 		return
 	}
 }
 
 // DeleteUserURLs deletes multiple URLs for the user
+// Errors are returned as RFC 7807 problem details
 func (h *URLHandler) DeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := authorization.UserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "need to authorize to access this method", http.StatusUnauthorized)
+		appError.WriteProblem(w, r, errUnauthorized)
+		return
 	}
 
 	var shorts []string
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&shorts); err != nil {
-		logger.Log.Error("cannot decode request JSON body", zap.Error(err))
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		logger.FromContext(r.Context()).Error("cannot decode request JSON body", zap.Error(err))
+		appError.WriteProblem(w, r, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRequest, "Invalid request body", err))
 		return
 	}
 
-	err := h.service.DeleteUserURLs(userID, shorts)
-	if err != nil {
-		http.Error(w, "failed to delete URL pairs", http.StatusInternalServerError)
+	if appErr := h.service.DeleteUserURLs(r.Context(), userID, shorts); appErr != nil {
+		appError.WriteProblem(w, r, appErr)
+		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
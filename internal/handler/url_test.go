@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -23,7 +24,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/alikhanturusbekov/go-url-shortener/internal/config"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/generator"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/policy"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
@@ -125,15 +128,17 @@ func TestShortenURLAsText(t *testing.T) {
 				request.Header.Set(name, value)
 			}
 
-			urlRepo, err := setupURLFileRepository(testConfig.FileStoragePath)
+			urlRepo, err := setupURLFileRepository(t, testConfig.FileStoragePath)
 			require.NoError(t, err)
 
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
-			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, 500)
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
 			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+			go clickWorker.Run(ctx)
 
-			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, deleteURLWorker, audit.NewNoop())
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
 			h := NewURLHandler(urlService, database).ShortenURLAsText
 			w := httptest.NewRecorder()
 			h(w, request)
@@ -188,6 +193,54 @@ func TestShortenURLAsJSON(t *testing.T) {
 				statusCode:  http.StatusCreated,
 			},
 		},
+		{
+			name: "Positive case: URL with TTL",
+			requestData: requestData{
+				headers: map[string]string{"Content-Type": "application/json"},
+				method:  http.MethodPost,
+				body:    []byte(`{"url": "https://yandex.ru", "ttl_seconds": 3600}`),
+			},
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusCreated,
+			},
+		},
+		{
+			name: "Negative case: non-positive TTL",
+			requestData: requestData{
+				headers: map[string]string{"Content-Type": "application/json"},
+				method:  http.MethodPost,
+				body:    []byte(`{"url": "https://yandex.ru", "ttl_seconds": 0}`),
+			},
+			want: want{
+				contentType: "application/problem+json",
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "Negative case: expiry in the past",
+			requestData: requestData{
+				headers: map[string]string{"Content-Type": "application/json"},
+				method:  http.MethodPost,
+				body:    []byte(`{"url": "https://yandex.ru", "expires_at": "2000-01-01T00:00:00Z"}`),
+			},
+			want: want{
+				contentType: "application/problem+json",
+				statusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			name: "Negative case: both expiry and TTL",
+			requestData: requestData{
+				headers: map[string]string{"Content-Type": "application/json"},
+				method:  http.MethodPost,
+				body:    []byte(`{"url": "https://yandex.ru", "expires_at": "2999-01-01T00:00:00Z", "ttl_seconds": 60}`),
+			},
+			want: want{
+				contentType: "application/problem+json",
+				statusCode:  http.StatusBadRequest,
+			},
+		},
 		{
 			name: "Negative case: incorrect URL field",
 			requestData: requestData{
@@ -196,7 +249,7 @@ func TestShortenURLAsJSON(t *testing.T) {
 				body:    []byte(`{"incorrectURL": "https://yandex.ru"}`),
 			},
 			want: want{
-				contentType: "text/plain; charset=utf-8",
+				contentType: "application/problem+json",
 				statusCode:  http.StatusBadRequest,
 			},
 		},
@@ -209,15 +262,17 @@ func TestShortenURLAsJSON(t *testing.T) {
 				request.Header.Set(name, value)
 			}
 
-			urlRepo, err := setupURLFileRepository(testConfig.FileStoragePath)
+			urlRepo, err := setupURLFileRepository(t, testConfig.FileStoragePath)
 			require.NoError(t, err)
 
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
-			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, 500)
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
 			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+			go clickWorker.Run(ctx)
 
-			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, deleteURLWorker, audit.NewNoop())
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
 			h := NewURLHandler(urlService, database).ShortenURLAsJSON
 			w := httptest.NewRecorder()
 			h(w, request)
@@ -244,6 +299,113 @@ func TestShortenURLAsJSON(t *testing.T) {
 	}
 }
 
+func TestShortenURLAsJSONWithAlias(t *testing.T) {
+	type want struct {
+		statusCode int
+		shortPath  string
+	}
+	tests := []struct {
+		name            string
+		body            string
+		mockURLDatabase []*model.URLPair
+		want            want
+	}{
+		{
+			name: "Positive case: free alias",
+			body: `{"url": "https://yandex.ru", "alias": "my-promo_1"}`,
+			want: want{
+				statusCode: http.StatusCreated,
+				shortPath:  "my-promo_1",
+			},
+		},
+		{
+			name: "Positive case: alias already points to the same URL",
+			body: `{"url": "https://yandex.ru", "alias": "promo"}`,
+			mockURLDatabase: []*model.URLPair{
+				{Short: "promo", Long: "https://yandex.ru"},
+			},
+			want: want{
+				statusCode: http.StatusConflict,
+				shortPath:  "promo",
+			},
+		},
+		{
+			name: "Positive case: free alias of a URL shortened under another short URL",
+			body: `{"url": "https://yandex.ru", "alias": "promo"}`,
+			mockURLDatabase: []*model.URLPair{
+				{Short: "abcdefg", Long: "https://yandex.ru"},
+			},
+			want: want{
+				statusCode: http.StatusConflict,
+				shortPath:  "abcdefg",
+			},
+		},
+		{
+			name: "Negative case: alias taken by another URL",
+			body: `{"url": "https://yandex.ru", "alias": "promo"}`,
+			mockURLDatabase: []*model.URLPair{
+				{Short: "promo", Long: "https://practicum.yandex.ru"},
+			},
+			want: want{statusCode: http.StatusConflict},
+		},
+		{
+			name: "Negative case: alias with forbidden characters",
+			body: `{"url": "https://yandex.ru", "alias": "promo/2024"}`,
+			want: want{statusCode: http.StatusBadRequest},
+		},
+		{
+			name: "Negative case: alias too short",
+			body: `{"url": "https://yandex.ru", "alias": "ab"}`,
+			want: want{statusCode: http.StatusBadRequest},
+		},
+		{
+			name: "Negative case: reserved alias",
+			body: `{"url": "https://yandex.ru", "alias": "API"}`,
+			want: want{statusCode: http.StatusBadRequest},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlRepo, err := setupURLFileRepository(t, testConfig.FileStoragePath)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			for _, urlPair := range tt.mockURLDatabase {
+				err := urlRepo.Save(ctx, urlPair)
+				require.NoError(t, err)
+			}
+
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+			go clickWorker.Run(ctx)
+
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+			h := NewURLHandler(urlService, database).ShortenURLAsJSON
+
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.want.statusCode, result.StatusCode)
+
+			if tt.want.shortPath != "" {
+				var response model.Response
+				err := json.NewDecoder(result.Body).Decode(&response)
+				require.NoError(t, err)
+				assert.Equal(t, testConfig.BaseURL+"/"+tt.want.shortPath, response.Result)
+			}
+		})
+	}
+}
+
 func TestResolveURL(t *testing.T) {
 	type want struct {
 		statusCode int
@@ -272,6 +434,38 @@ func TestResolveURL(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "Positive case: URL has not expired yet",
+			targetURL: "abcdefg",
+			mockURLDatabase: []*model.URLPair{
+				{
+					Short:     "abcdefg",
+					Long:      "https://yandex.ru",
+					ExpiresAt: pointerTo(time.Now().Add(time.Hour)),
+				},
+			},
+			want: want{
+				statusCode: http.StatusTemporaryRedirect,
+				headers: map[string]string{
+					"Location": "https://yandex.ru",
+				},
+			},
+		},
+		{
+			name:      "Negative case: URL has expired",
+			targetURL: "abcdefg",
+			mockURLDatabase: []*model.URLPair{
+				{
+					Short:     "abcdefg",
+					Long:      "https://yandex.ru",
+					ExpiresAt: pointerTo(time.Now().Add(-time.Minute)),
+				},
+			},
+			want: want{
+				statusCode: http.StatusGone,
+				headers:    map[string]string{},
+			},
+		},
 		{
 			name:      "Negative case: URL does not exist in Database",
 			targetURL: "abcdefg",
@@ -290,7 +484,7 @@ func TestResolveURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			urlRepo, err := setupURLFileRepository(testConfig.FileStoragePath)
+			urlRepo, err := setupURLFileRepository(t, testConfig.FileStoragePath)
 			require.NoError(t, err)
 
 			ctx, cancel := context.WithTimeout(context.Background(), 2000*time.Millisecond)
@@ -303,11 +497,13 @@ func TestResolveURL(t *testing.T) {
 
 			ctx, cancel = context.WithCancel(context.Background())
 			defer cancel()
-			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, 500)
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
 			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+			go clickWorker.Run(ctx)
 
 			mux := http.NewServeMux()
-			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, deleteURLWorker, audit.NewNoop())
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
 			mux.HandleFunc("/{id}", NewURLHandler(urlService, database).ResolveURL)
 
 			request := httptest.NewRequest(http.MethodGet, "/"+tt.targetURL, nil)
@@ -335,10 +531,12 @@ func TestBatchShortenURL(t *testing.T) {
 	type want struct {
 		contentType string
 		statusCode  int
-		body        []model.BatchShortenURLResponse
+		shortURLs   map[int]string
+		statuses    []string
 	}
 	tests := []struct {
 		name        string
+		stored      []*model.URLPair
 		requestData requestData
 		want        want
 	}{
@@ -361,6 +559,55 @@ func TestBatchShortenURL(t *testing.T) {
 			want: want{
 				contentType: "application/json",
 				statusCode:  http.StatusCreated,
+				statuses:    []string{"", ""},
+			},
+		},
+		{
+			name: "Positive case: already shortened URL returns existing short URL",
+			stored: []*model.URLPair{
+				model.NewURLPair("existing", "https://yandex.ru", nil, "", false),
+			},
+			requestData: requestData{
+				headers: map[string]string{"Content-Type": "application/json"},
+				method:  http.MethodPost,
+				body: []model.BatchShortenURLRequest{
+					{
+						CorrelationID: pointer(uuid.NewString()),
+						OriginalURL:   "https://practicum.yandex.ru",
+					},
+					{
+						CorrelationID: pointer(uuid.NewString()),
+						OriginalURL:   "https://yandex.ru",
+					},
+				},
+			},
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusCreated,
+				shortURLs:   map[int]string{1: testConfig.BaseURL + "/existing"},
+				statuses:    []string{"", model.BatchStatusConflict},
+			},
+		},
+		{
+			name: "Positive case: repeated URL in batch is stored once",
+			requestData: requestData{
+				headers: map[string]string{"Content-Type": "application/json"},
+				method:  http.MethodPost,
+				body: []model.BatchShortenURLRequest{
+					{
+						CorrelationID: pointer(uuid.NewString()),
+						OriginalURL:   "https://yandex.ru",
+					},
+					{
+						CorrelationID: pointer(uuid.NewString()),
+						OriginalURL:   "https://yandex.ru",
+					},
+				},
+			},
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusCreated,
+				statuses:    []string{"", ""},
 			},
 		},
 	}
@@ -374,15 +621,20 @@ func TestBatchShortenURL(t *testing.T) {
 				request.Header.Set(name, value)
 			}
 
-			urlRepo, err := setupURLFileRepository(testConfig.FileStoragePath)
+			urlRepo, err := setupURLFileRepository(t, testConfig.FileStoragePath)
 			require.NoError(t, err)
+			for _, urlPair := range tt.stored {
+				require.NoError(t, urlRepo.Save(context.Background(), urlPair))
+			}
 
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
-			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, 500)
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
 			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+			go clickWorker.Run(ctx)
 
-			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, deleteURLWorker, audit.NewNoop())
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
 			h := NewURLHandler(urlService, database).BatchShortenURL
 			w := httptest.NewRecorder()
 			h(w, request)
@@ -391,7 +643,7 @@ func TestBatchShortenURL(t *testing.T) {
 			defer result.Body.Close()
 
 			var responseData []model.BatchShortenURLResponse
-			err = json.Unmarshal(bodyBytes, &responseData)
+			err = json.NewDecoder(result.Body).Decode(&responseData)
 			require.NoError(t, err)
 
 			assert.Equal(t, tt.want.statusCode, result.StatusCode)
@@ -400,23 +652,398 @@ func TestBatchShortenURL(t *testing.T) {
 
 			for i := range tt.requestData.body {
 				assert.Equal(t, tt.requestData.body[i].CorrelationID, responseData[i].CorrelationID)
+				assert.Equal(t, tt.want.statuses[i], responseData[i].Status)
+				if shortURL, ok := tt.want.shortURLs[i]; ok {
+					assert.Equal(t, shortURL, responseData[i].ShortURL)
+				}
+			}
+		})
+	}
+}
+
+// racingRepository is an in-memory repository storing a URL pair right before the first batch save,
+// as a concurrent request would between generating short URLs and saving them
+type racingRepository struct {
+	repository.URLRepository
+	racing *model.URLPair
+}
+
+func (r *racingRepository) SaveMany(ctx context.Context, urlPairs []*model.URLPair) (map[string]*model.URLPair, error) {
+	if r.racing != nil {
+		if err := r.URLRepository.Save(ctx, r.racing); err != nil {
+			return nil, err
+		}
+		r.racing = nil
+	}
+
+	return r.URLRepository.SaveMany(ctx, urlPairs)
+}
+
+func TestBatchShortenURLShortTaken(t *testing.T) {
+	const originalURL = "https://yandex.ru"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	taken := shortCode(t, originalURL)
+	urlRepo := &racingRepository{
+		URLRepository: repository.NewURLInMemoryRepository(),
+		racing:        model.NewURLPair(taken, "https://practicum.yandex.ru", nil, "", false),
+	}
+
+	deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+	go deleteURLWorker.Run(ctx)
+	clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+	go clickWorker.Run(ctx)
+
+	urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+	h := NewURLHandler(urlService, database).BatchShortenURL
+
+	body, err := json.Marshal([]model.BatchShortenURLRequest{
+		{CorrelationID: pointer("1"), OriginalURL: originalURL},
+		{CorrelationID: pointer("2"), OriginalURL: "https://go.dev"},
+		{CorrelationID: pointer("3"), OriginalURL: originalURL},
+	})
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h(w, request)
+
+	result := w.Result()
+	defer result.Body.Close()
+
+	require.Equal(t, http.StatusCreated, result.StatusCode)
+
+	var responseData []model.BatchShortenURLResponse
+	require.NoError(t, json.NewDecoder(result.Body).Decode(&responseData))
+	require.Len(t, responseData, 3)
+
+	assert.NotEqual(t, testConfig.BaseURL+"/"+taken, responseData[0].ShortURL)
+	assert.Equal(t, responseData[0].ShortURL, responseData[2].ShortURL)
+	assert.Equal(t, testConfig.BaseURL+"/"+shortCode(t, "https://go.dev"), responseData[1].ShortURL)
+
+	for i, originalURL := range []string{originalURL, "https://go.dev"} {
+		stored, isFound := urlRepo.GetByShort(ctx, strings.TrimPrefix(responseData[i].ShortURL, testConfig.BaseURL+"/"))
+		require.True(t, isFound)
+		assert.Equal(t, originalURL, stored.Long)
+	}
+}
+
+func TestShortenURLConcurrently(t *testing.T) {
+	const (
+		originalURL = "https://practicum.yandex.ru"
+		requests    = 50
+	)
+
+	tests := []struct {
+		name     string
+		urlRepo  func(t *testing.T) repository.URLRepository
+		strategy string
+	}{
+		{
+			name: "In-memory repository with hash codes",
+			urlRepo: func(t *testing.T) repository.URLRepository {
+				return repository.NewURLInMemoryRepository()
+			},
+			strategy: generator.StrategyHash,
+		},
+		{
+			name: "In-memory repository with random codes",
+			urlRepo: func(t *testing.T) repository.URLRepository {
+				return repository.NewURLInMemoryRepository()
+			},
+			strategy: generator.StrategyRandom,
+		},
+		{
+			name: "File repository with random codes",
+			urlRepo: func(t *testing.T) repository.URLRepository {
+				urlRepo, err := setupURLFileRepository(t, testConfig.FileStoragePath)
+				require.NoError(t, err)
+				return urlRepo
+			},
+			strategy: generator.StrategyRandom,
+		},
+		{
+			name: "Database repository with random codes",
+			urlRepo: func(t *testing.T) repository.URLRepository {
+				return repository.NewURLDatabaseRepository(openTestDatabase(t))
+			},
+			strategy: generator.StrategyRandom,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlRepo := tt.urlRepo(t)
+
+			var shortCodeGenerator generator.ShortCodeGenerator = newHashGenerator(t)
+			if tt.strategy == generator.StrategyRandom {
+				randomGenerator, err := generator.NewRandomGenerator(generator.DefaultLength, "")
+				require.NoError(t, err)
+				shortCodeGenerator = randomGenerator
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+			go clickWorker.Run(ctx)
+
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, shortCodeGenerator, newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+			h := NewURLHandler(urlService, database).ShortenURLAsJSON
+
+			statusCodes := make([]int, requests)
+			shortURLs := make([]string, requests)
+
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					<-start
+
+					request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"url": "`+originalURL+`"}`))
+					request.Header.Set("Content-Type", "application/json")
+					w := httptest.NewRecorder()
+					h(w, request)
+
+					result := w.Result()
+					defer result.Body.Close()
+
+					var response model.Response
+					_ = json.NewDecoder(result.Body).Decode(&response)
+
+					statusCodes[i] = result.StatusCode
+					shortURLs[i] = response.Result
+				}(i)
 			}
+			close(start)
+			wg.Wait()
+
+			created := 0
+			for i := 0; i < requests; i++ {
+				if statusCodes[i] == http.StatusCreated {
+					created++
+				} else {
+					assert.Equal(t, http.StatusConflict, statusCodes[i])
+				}
+				assert.Equal(t, shortURLs[0], shortURLs[i])
+			}
+			assert.Equal(t, 1, created)
+
+			count, err := urlRepo.CountURLs(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), count)
 		})
 	}
 }
 
-func setupURLFileRepository(filePath string) (*repository.URLFileRepository, error) {
+func TestShortenURLAfterExpiry(t *testing.T) {
+	const originalURL = "https://practicum.yandex.ru"
+
+	type want struct {
+		statusCode int
+		reused     bool
+		expires    bool
+	}
+	tests := []struct {
+		name    string
+		stored  func(t *testing.T, ctx context.Context, urlRepo repository.URLRepository) string
+		request string
+		want    want
+	}{
+		{
+			name: "Positive case: expired link does not block the URL",
+			stored: func(t *testing.T, ctx context.Context, urlRepo repository.URLRepository) string {
+				urlPair := model.NewURLPair("abcdefg", originalURL, nil, "user-1", false)
+				urlPair.ExpiresAt = pointerTo(time.Now().Add(-time.Minute))
+				require.NoError(t, urlRepo.Save(ctx, urlPair))
+				return urlPair.Short
+			},
+			request: `{"url": "` + originalURL + `"}`,
+			want:    want{statusCode: http.StatusCreated},
+		},
+		{
+			name: "Positive case: reaped link does not block the URL",
+			stored: func(t *testing.T, ctx context.Context, urlRepo repository.URLRepository) string {
+				urlPair := model.NewURLPair("abcdefg", originalURL, nil, "user-1", false)
+				urlPair.ExpiresAt = pointerTo(time.Now().Add(-time.Minute))
+				require.NoError(t, urlRepo.Save(ctx, urlPair))
+				_, err := urlRepo.DeleteExpired(ctx, time.Now())
+				require.NoError(t, err)
+				return urlPair.Short
+			},
+			request: `{"url": "` + originalURL + `"}`,
+			want:    want{statusCode: http.StatusCreated},
+		},
+		{
+			name: "Positive case: deleted link does not block the URL",
+			stored: func(t *testing.T, ctx context.Context, urlRepo repository.URLRepository) string {
+				urlPair, created, err := urlRepo.GetOrCreate(ctx, model.NewURLPair(shortCode(t, originalURL), originalURL, nil, "user-1", false))
+				require.NoError(t, err)
+				require.True(t, created)
+				_, err = urlRepo.DeleteByShorts(ctx, "user-1", []string{urlPair.Short})
+				require.NoError(t, err)
+				return urlPair.Short
+			},
+			request: `{"url": "` + originalURL + `"}`,
+			want:    want{statusCode: http.StatusCreated},
+		},
+		{
+			name: "Positive case: TTL of another user is honoured next to a permanent link",
+			stored: func(t *testing.T, ctx context.Context, urlRepo repository.URLRepository) string {
+				urlPair := model.NewURLPair(shortCode(t, originalURL), originalURL, nil, "user-1", false)
+				require.NoError(t, urlRepo.Save(ctx, urlPair))
+				return urlPair.Short
+			},
+			request: `{"url": "` + originalURL + `", "ttl_seconds": 3600}`,
+			want:    want{statusCode: http.StatusCreated, expires: true},
+		},
+		{
+			name: "Positive case: TTL link does not block a permanent one",
+			stored: func(t *testing.T, ctx context.Context, urlRepo repository.URLRepository) string {
+				urlPair := model.NewURLPair(shortCode(t, originalURL), originalURL, nil, "user-1", false)
+				urlPair.ExpiresAt = pointerTo(time.Now().Add(time.Hour))
+				require.NoError(t, urlRepo.Save(ctx, urlPair))
+				return urlPair.Short
+			},
+			request: `{"url": "` + originalURL + `"}`,
+			want:    want{statusCode: http.StatusCreated},
+		},
+		{
+			name: "Negative case: live permanent link conflicts",
+			stored: func(t *testing.T, ctx context.Context, urlRepo repository.URLRepository) string {
+				urlPair := model.NewURLPair(shortCode(t, originalURL), originalURL, nil, "user-1", false)
+				require.NoError(t, urlRepo.Save(ctx, urlPair))
+				return urlPair.Short
+			},
+			request: `{"url": "` + originalURL + `"}`,
+			want:    want{statusCode: http.StatusConflict, reused: true},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, repoName := range []string{"memory", "file"} {
+			t.Run(tt.name+" in "+repoName+" repository", func(t *testing.T) {
+				var urlRepo repository.URLRepository = repository.NewURLInMemoryRepository()
+				if repoName == "file" {
+					fileRepo, err := setupURLFileRepository(t, testConfig.FileStoragePath)
+					require.NoError(t, err)
+					urlRepo = fileRepo
+				}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+				go deleteURLWorker.Run(ctx)
+				clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+				go clickWorker.Run(ctx)
+
+				storedShort := tt.stored(t, ctx, urlRepo)
+
+				urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+				h := NewURLHandler(urlService, database).ShortenURLAsJSON
+
+				request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.request))
+				request.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				h(w, request)
+
+				result := w.Result()
+				defer result.Body.Close()
+
+				var response model.Response
+				require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+
+				assert.Equal(t, tt.want.statusCode, result.StatusCode)
+
+				short := strings.TrimPrefix(response.Result, testConfig.BaseURL+"/")
+				if tt.want.reused {
+					assert.Equal(t, storedShort, short)
+					return
+				}
+				assert.NotEqual(t, storedShort, short)
+
+				urlPair, isFound := urlRepo.GetByShort(ctx, short)
+				require.True(t, isFound)
+				assert.Equal(t, originalURL, urlPair.Long)
+				assert.Equal(t, tt.want.expires, urlPair.ExpiresAt != nil)
+			})
+		}
+	}
+}
+
+func setupURLFileRepository(t *testing.T, filePath string) (*repository.URLFileRepository, error) {
+	t.Helper()
+
 	err := os.WriteFile(filePath, []byte(""), 0644)
 	if err != nil {
 		return nil, err
 	}
 
-	urlRepo, err := repository.NewURLFileRepository(filePath)
+	err = os.Remove(filePath + ".snapshot")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	urlRepo, err := repository.NewURLFileRepository(filePath, repository.FileRepositoryOptions{})
 	if err != nil {
 		return nil, err
 	}
+	t.Cleanup(func() { _ = urlRepo.Close() })
 
 	return urlRepo, nil
 }
 
+// openTestDatabase opens the database of TEST_DATABASE_DSN with the migrations applied and url_pairs emptied
+// The test is skipped when the variable is not set
+func openTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	require.NoError(t, repository.ApplyMigrations(db, "../../migrations"))
+
+	_, err = db.Exec("TRUNCATE url_pairs CASCADE")
+	require.NoError(t, err)
+
+	return db
+}
+
+func newHashGenerator(t testing.TB) *generator.HashGenerator {
+	t.Helper()
+
+	hashGenerator, err := generator.NewHashGenerator(generator.DefaultLength, "")
+	require.NoError(t, err)
+
+	return hashGenerator
+}
+
+// shortCode returns the short code the hash generator derives for the URL first
+func shortCode(t *testing.T, originalURL string) string {
+	t.Helper()
+
+	short, err := newHashGenerator(t).Generate(context.Background(), originalURL, 0)
+	require.NoError(t, err)
+
+	return short
+}
+
+func newDestinationPolicy() *policy.Policy {
+	return policy.NewPolicy(policy.DefaultOptions())
+}
+
 func pointer(s string) *string { return &s }
+
+func pointerTo[T any](v T) *T { return &v }
@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/service"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+)
+
+// nextLinkPattern extracts the target of a rel="next" Link header
+var nextLinkPattern = regexp.MustCompile(`^<([^>]+)>; rel="next"$`)
+
+func TestGetUserURLs(t *testing.T) {
+	const (
+		jwtKey = "test_key"
+		userID = "user-1"
+	)
+
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := []*model.URLPair{
+		{Short: "c", Long: "https://yandex.ru/news", UserID: userID, CreatedAt: createdAt.Add(2 * time.Hour)},
+		{Short: "a", Long: "https://yandex.ru/maps", UserID: userID, CreatedAt: createdAt},
+		{Short: "b", Long: "https://google.com", UserID: userID, CreatedAt: createdAt.Add(time.Hour)},
+		{Short: "d", Long: "https://yandex.ru/deleted", UserID: userID, CreatedAt: createdAt.Add(time.Hour), IsDeleted: true},
+		{Short: "e", Long: "https://yandex.ru/foreign", UserID: "user-2", CreatedAt: createdAt},
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		// pages lists the short codes of every page reached by following Link headers
+		pages [][]string
+	}{
+		{
+			name:   "Positive case: all URLs in creation order",
+			target: "/api/user/urls",
+			pages:  [][]string{{"a", "b", "c"}},
+		},
+		{
+			name:   "Positive case: pages follow the Link header",
+			target: "/api/user/urls?limit=2",
+			pages:  [][]string{{"a", "b"}, {"c"}},
+		},
+		{
+			name:   "Positive case: newest first",
+			target: "/api/user/urls?limit=2&sort=-created_at",
+			pages:  [][]string{{"c", "b"}, {"a"}},
+		},
+		{
+			name:   "Positive case: search on original URL",
+			target: "/api/user/urls?q=yandex.ru&limit=1",
+			pages:  [][]string{{"a"}, {"c"}},
+		},
+		{
+			name:   "Positive case: deleted URLs included",
+			target: "/api/user/urls?include_deleted=true&limit=3",
+			pages:  [][]string{{"a", "b", "d"}, {"c"}},
+		},
+		{
+			name:   "Positive case: no matching URLs",
+			target: "/api/user/urls?q=example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			urlRepo := repository.NewURLInMemoryRepository()
+			_, err := urlRepo.SaveMany(ctx, stored)
+			require.NoError(t, err)
+
+			deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+			go deleteURLWorker.Run(ctx)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+			go clickWorker.Run(ctx)
+
+			urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+
+			r := chi.NewRouter()
+			r.Use(authorization.AuthMiddleware([]byte(jwtKey)))
+			r.Get("/api/user/urls", NewURLHandler(urlService, database).GetUserURLs)
+
+			var pages [][]string
+			target := tt.target
+
+			for target != "" {
+				request := httptest.NewRequest(http.MethodGet, target, nil)
+				request.AddCookie(authCookie(t, jwtKey, userID))
+
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, request)
+
+				result := w.Result()
+				defer result.Body.Close()
+
+				if result.StatusCode == http.StatusNoContent {
+					break
+				}
+				require.Equal(t, http.StatusOK, result.StatusCode)
+				assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+
+				var items []model.URLPairsResponse
+				require.NoError(t, json.NewDecoder(result.Body).Decode(&items))
+
+				page := make([]string, 0, len(items))
+				for _, item := range items {
+					page = append(page, item.ShortURL[len(testConfig.BaseURL)+1:])
+					assert.False(t, item.CreatedAt.IsZero())
+				}
+				pages = append(pages, page)
+
+				target = ""
+				if link := result.Header.Get("Link"); link != "" {
+					match := nextLinkPattern.FindStringSubmatch(link)
+					require.NotNil(t, match, link)
+					target = match[1]
+
+					next, err := url.Parse(target)
+					require.NoError(t, err)
+					assert.NotEmpty(t, next.Query().Get("cursor"))
+				}
+			}
+
+			assert.Equal(t, tt.pages, pages)
+		})
+	}
+}
+
+func TestGetUserURLsWithoutPagination(t *testing.T) {
+	const (
+		jwtKey = "test_key"
+		userID = "user-1"
+		// count spans several storage pages of the largest size
+		count = 2*service.MaxPageLimit + 1
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := make([]*model.URLPair, 0, count)
+	for i := range count {
+		stored = append(stored, &model.URLPair{
+			Short:     fmt.Sprintf("s%05d", i),
+			Long:      fmt.Sprintf("https://yandex.ru/%d", i),
+			UserID:    userID,
+			CreatedAt: createdAt.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	urlRepo := repository.NewURLInMemoryRepository()
+	_, err := urlRepo.SaveMany(ctx, stored)
+	require.NoError(t, err)
+
+	deleteURLWorker := worker.NewDeleteURLWorker(urlRepo, audit.NewNoop(), 500)
+	go deleteURLWorker.Run(ctx)
+	clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+	go clickWorker.Run(ctx)
+
+	urlService := service.NewURLService(urlRepo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+
+	r := chi.NewRouter()
+	r.Use(authorization.AuthMiddleware([]byte(jwtKey)))
+	r.Get("/api/user/urls", NewURLHandler(urlService, database).GetUserURLs)
+
+	tests := []struct {
+		name     string
+		target   string
+		want     int
+		wantNext bool
+	}{
+		{
+			name:   "Positive case: every URL without limit and cursor",
+			target: "/api/user/urls",
+			want:   count,
+		},
+		{
+			name:   "Positive case: every URL newest first",
+			target: "/api/user/urls?sort=-created_at",
+			want:   count,
+		},
+		{
+			name:     "Positive case: explicit limit is paginated",
+			target:   "/api/user/urls?limit=150",
+			want:     150,
+			wantNext: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			request.AddCookie(authCookie(t, jwtKey, userID))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			require.Equal(t, http.StatusOK, result.StatusCode)
+			assert.Equal(t, tt.wantNext, result.Header.Get("Link") != "")
+
+			var items []model.URLPairsResponse
+			require.NoError(t, json.NewDecoder(result.Body).Decode(&items))
+			require.Len(t, items, tt.want)
+
+			seen := make(map[string]struct{}, len(items))
+			for _, item := range items {
+				seen[item.ShortURL] = struct{}{}
+			}
+			assert.Len(t, seen, tt.want)
+		})
+	}
+}
+
+// contextRecordingRepository is an in-memory repository recording the context error of the last page read
+type contextRecordingRepository struct {
+	repository.URLRepository
+	called bool
+	err    error
+}
+
+func (r *contextRecordingRepository) GetPageByUserID(ctx context.Context, query repository.UserURLsQuery) ([]*model.URLPair, error) {
+	r.called = true
+	r.err = ctx.Err()
+
+	return r.URLRepository.GetPageByUserID(ctx, query)
+}
+
+func TestGetUserURLsCancelledRequest(t *testing.T) {
+	const (
+		jwtKey = "test_key"
+		userID = "user-1"
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &contextRecordingRepository{URLRepository: repository.NewURLInMemoryRepository()}
+	require.NoError(t, repo.Save(ctx, &model.URLPair{Short: "abcdefg", Long: "https://yandex.ru", UserID: userID}))
+
+	deleteURLWorker := worker.NewDeleteURLWorker(repo, audit.NewNoop(), 500)
+	go deleteURLWorker.Run(ctx)
+	clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 500)
+	go clickWorker.Run(ctx)
+
+	urlService := service.NewURLService(repo, testConfig.BaseURL, newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), service.DefaultTimeouts())
+
+	r := chi.NewRouter()
+	r.Use(authorization.AuthMiddleware([]byte(jwtKey)))
+	r.Get("/api/user/urls", NewURLHandler(urlService, database).GetUserURLs)
+
+	// The client went away before the handler ran
+	requestCtx, cancelRequest := context.WithCancel(context.Background())
+	cancelRequest()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil).WithContext(requestCtx)
+	request.AddCookie(authCookie(t, jwtKey, userID))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, request)
+
+	require.True(t, repo.called)
+	assert.ErrorIs(t, repo.err, context.Canceled)
+}
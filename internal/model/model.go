@@ -2,12 +2,20 @@
 package model
 
 import (
+	"net/http"
+	"net/url"
+	"time"
+
 	"github.com/google/uuid"
 )
 
 // Request represents a shorten URL request
 type Request struct {
-	URL string `json:"url"`
+	URL        string            `json:"url"`
+	Alias      string            `json:"alias,omitempty"`
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"`
+	TTLSeconds *int64            `json:"ttl_seconds,omitempty"`
+	Redirect   *RedirectSettings `json:"redirect,omitempty"`
 }
 
 // Response represents a shorten URL response
@@ -17,39 +25,87 @@ type Response struct {
 
 // URLPair represents a stored URL entity
 type URLPair struct {
-	ID        string `json:"uid"`
-	Short     string `json:"short"`
-	Long      string `json:"long"`
-	UserID    string `json:"user_id"`
-	IsDeleted bool   `json:"is_deleted"`
+	ID        string           `json:"uid"`
+	Short     string           `json:"short"`
+	Long      string           `json:"long"`
+	UserID    string           `json:"user_id"`
+	IsDeleted bool             `json:"is_deleted"`
+	ExpiresAt *time.Time       `json:"expires_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at,omitzero"`
+	Redirect  RedirectSettings `json:"redirect,omitzero"`
+}
+
+// RedirectSettings defines how a short URL redirects to its destination
+type RedirectSettings struct {
+	// Type is the HTTP status code of the redirect, 307 when zero
+	Type int `json:"type,omitempty"`
+	// PassQuery forwards the query string of the short URL request to the destination
+	PassQuery bool `json:"pass_query,omitempty"`
+	// UTM lists the UTM parameters appended to the destination
+	UTM *UTMParams `json:"utm,omitempty"`
+}
+
+// UTMParams represents UTM tracking parameters
+type UTMParams struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
 }
 
 // BatchShortenURLRequest represents a single batch shorten request item
 type BatchShortenURLRequest struct {
-	CorrelationID *string `json:"correlation_id"`
-	OriginalURL   string  `json:"original_url"`
+	CorrelationID *string           `json:"correlation_id"`
+	OriginalURL   string            `json:"original_url"`
+	ExpiresAt     *time.Time        `json:"expires_at,omitempty"`
+	TTLSeconds    *int64            `json:"ttl_seconds,omitempty"`
+	Redirect      *RedirectSettings `json:"redirect,omitempty"`
 }
 
 // BatchShortenURLResponse represents a single batch shorten response item
 type BatchShortenURLResponse struct {
 	CorrelationID *string `json:"correlation_id"`
 	ShortURL      string  `json:"short_url"`
+	Status        string  `json:"status,omitempty"`
 }
 
+// BatchStatusConflict marks a batch item whose long URL has already been shortened
+const BatchStatusConflict = "conflict"
+
 // URLPairsResponse represents a user URL listing response
 type URLPairsResponse struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	IsDeleted   bool      `json:"is_deleted,omitempty"`
+}
+
+// UserURLsQuery represents user URL listing parameters
+type UserURLsQuery struct {
+	Limit          int
+	Cursor         string
+	Sort           string
+	Search         string
+	IncludeDeleted bool
+}
+
+// UserURLsPage represents a single page of a user URL listing
+type UserURLsPage struct {
+	Items      []*URLPairsResponse
+	NextCursor string
 }
 
 // DeleteURLTask represents a background deletion task
 type DeleteURLTask struct {
-	UserID string `json:"user_id"`
-	Short  string `json:"short"`
+	UserID    string `json:"user_id"`
+	Short     string `json:"short"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // NewURLPair creates a new URLPair instance
 // If id is not provided, it is generated UUID
+// The creation time is truncated to microseconds, the precision of the database
 func NewURLPair(short, long string, id *string, userID string, isDeleted bool) *URLPair {
 	urlPair := &URLPair{
 		ID:        uuid.NewString(),
@@ -57,6 +113,7 @@ func NewURLPair(short, long string, id *string, userID string, isDeleted bool) *
 		Long:      long,
 		UserID:    userID,
 		IsDeleted: isDeleted,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
 	}
 
 	if id != nil {
@@ -65,3 +122,104 @@ func NewURLPair(short, long string, id *string, userID string, isDeleted bool) *
 
 	return urlPair
 }
+
+// StatusCode returns the HTTP status code of the redirect
+func (s RedirectSettings) StatusCode() int {
+	if s.Type == 0 {
+		return http.StatusTemporaryRedirect
+	}
+
+	return s.Type
+}
+
+// Values returns the non-empty UTM parameters as query values
+func (p *UTMParams) Values() url.Values {
+	values := url.Values{}
+	if p == nil {
+		return values
+	}
+
+	for name, value := range map[string]string{
+		"utm_source":   p.Source,
+		"utm_medium":   p.Medium,
+		"utm_campaign": p.Campaign,
+		"utm_term":     p.Term,
+		"utm_content":  p.Content,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+
+	return values
+}
+
+// IsExpired reports whether the URL pair has passed its expiry time
+func (p *URLPair) IsExpired(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
+
+// OwnsLong reports whether the URL pair is live and has no expiry
+// Such URL pairs are unique per long URL and returned when the long URL is shortened again,
+// links with an expiry are always created anew and deleted or expired ones no longer block the long URL
+func (p *URLPair) OwnsLong() bool {
+	return !p.IsDeleted && p.ExpiresAt == nil
+}
+
+// Visit describes the client that follows a short URL
+type Visit struct {
+	Referrer  string
+	UserAgent string
+	ClientIP  string
+	// Query is the raw query string of the short URL request
+	Query string
+}
+
+// Redirect represents the response to a followed short URL
+type Redirect struct {
+	Location   string
+	StatusCode int
+}
+
+// Click represents a single recorded follow of a short URL
+type Click struct {
+	Short     string    `json:"short"`
+	ClickedAt time.Time `json:"clicked_at"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPBucket  string    `json:"ip_bucket,omitempty"`
+	VisitorID string    `json:"visitor_id"`
+}
+
+// DailyClicks represents the number of clicks on a single day
+type DailyClicks struct {
+	Date   string `json:"date"`
+	Clicks int64  `json:"clicks"`
+}
+
+// URLStats represents aggregated click statistics of a short URL
+type URLStats struct {
+	ShortURL       string        `json:"short_url,omitempty"`
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	Daily          []DailyClicks `json:"daily"`
+}
+
+// ServiceStats represents aggregated statistics of the service
+type ServiceStats struct {
+	URLs  int64 `json:"urls"`
+	Users int64 `json:"users"`
+}
+
+// QRCodeRequest represents QR code rendering parameters
+type QRCodeRequest struct {
+	Size            int
+	Format          string
+	ErrorCorrection string
+}
+
+// QRCode represents a rendered QR code image
+type QRCode struct {
+	Image       []byte
+	ContentType string
+}
@@ -0,0 +1,134 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+)
+
+// domainSet holds the patterns of a loaded blocklist
+type domainSet struct {
+	exact    map[string]struct{}
+	wildcard map[string]struct{}
+}
+
+// Blocklist matches hosts against domain patterns loaded from a file
+//
+// The file lists one pattern per line, empty lines and lines starting with # are ignored.
+// A plain domain blocks only that host, a *.domain pattern blocks all its subdomains.
+// The file is reloaded by Run when it changes, so the list can be updated without a restart.
+type Blocklist struct {
+	path    string
+	domains atomic.Pointer[domainSet]
+	modTime time.Time
+	size    int64
+}
+
+// NewBlocklist creates a new Blocklist instance loaded from the file
+func NewBlocklist(path string) (*Blocklist, error) {
+	blocklist := &Blocklist{path: path}
+	if err := blocklist.load(); err != nil {
+		return nil, err
+	}
+
+	return blocklist, nil
+}
+
+// Match reports whether the host is blocked and returns the matching pattern
+func (b *Blocklist) Match(host string) (string, bool) {
+	domains := b.domains.Load()
+	host = normalizeHost(host)
+
+	if _, isFound := domains.exact[host]; isFound {
+		return host, true
+	}
+
+	for parent := host; ; {
+		_, rest, ok := strings.Cut(parent, ".")
+		if !ok {
+			return "", false
+		}
+
+		if _, isFound := domains.wildcard[rest]; isFound {
+			return "*." + rest, true
+		}
+		parent = rest
+	}
+}
+
+// load reads the blocklist file and replaces the patterns in use
+func (b *Blocklist) load() error {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	domains := &domainSet{exact: make(map[string]struct{}), wildcard: make(map[string]struct{})}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern := normalizeHost(line)
+		if domain, isWildcard := strings.CutPrefix(pattern, "*."); isWildcard {
+			domains.wildcard[domain] = struct{}{}
+		} else {
+			domains.exact[pattern] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	b.domains.Store(domains)
+	b.modTime, b.size = info.ModTime(), info.Size()
+
+	return nil
+}
+
+// Run reloads the blocklist whenever its file changes until the context is cancelled
+// A failed reload keeps the previous patterns in use
+func (b *Blocklist) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(b.path)
+			if err != nil {
+				logger.Log.Warn("failed to stat domain blocklist", zap.String("path", b.path), zap.Error(err))
+				continue
+			}
+
+			if info.ModTime().Equal(b.modTime) && info.Size() == b.size {
+				continue
+			}
+
+			if err := b.load(); err != nil {
+				logger.Log.Warn("failed to reload domain blocklist", zap.String("path", b.path), zap.Error(err))
+				continue
+			}
+
+			logger.Log.Info("domain blocklist reloaded", zap.String("path", b.path))
+		}
+	}
+}
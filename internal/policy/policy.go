@@ -0,0 +1,203 @@
+// Package policy decides which destination URLs may be shortened
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Rules checked by the destination policy
+const (
+	RuleLength        = "max_length"
+	RuleScheme        = "scheme"
+	RulePrivateHost   = "private_host"
+	RuleBlockedDomain = "blocked_domain"
+)
+
+// Violation describes why a destination URL is not allowed
+type Violation struct {
+	Rule   string
+	Reason string
+}
+
+// Error returns the reason of the violation
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+// Options configures the destination policy
+type Options struct {
+	// AllowedSchemes lists the URL schemes that may be shortened, any scheme is allowed when empty
+	AllowedSchemes []string
+	// BlockPrivateHosts rejects loopback, private, link-local and unspecified hosts
+	BlockPrivateHosts bool
+	// MaxURLLength limits the URL length in bytes, unlimited when zero
+	MaxURLLength int
+	// Blocklist rejects hosts of listed domains, disabled when nil
+	Blocklist *Blocklist
+}
+
+// DefaultOptions returns the policy options used when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		AllowedSchemes:    []string{"http", "https"},
+		BlockPrivateHosts: true,
+		MaxURLLength:      2048,
+	}
+}
+
+// Policy checks destination URLs against the configured rules
+type Policy struct {
+	allowedSchemes    map[string]struct{}
+	blockPrivateHosts bool
+	maxURLLength      int
+	blocklist         *Blocklist
+}
+
+// NewPolicy creates a new Policy instance
+func NewPolicy(options Options) *Policy {
+	allowedSchemes := make(map[string]struct{}, len(options.AllowedSchemes))
+	for _, scheme := range options.AllowedSchemes {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			allowedSchemes[scheme] = struct{}{}
+		}
+	}
+
+	return &Policy{
+		allowedSchemes:    allowedSchemes,
+		blockPrivateHosts: options.BlockPrivateHosts,
+		maxURLLength:      options.MaxURLLength,
+		blocklist:         options.Blocklist,
+	}
+}
+
+// Check applies the policy to an absolute URL
+// Returns nil if the URL is allowed
+func (p *Policy) Check(rawURL string) *Violation {
+	if p.maxURLLength > 0 && len(rawURL) > p.maxURLLength {
+		return &Violation{Rule: RuleLength, Reason: fmt.Sprintf("URL is longer than %d bytes", p.maxURLLength)}
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return &Violation{Rule: RuleScheme, Reason: "URL cannot be parsed"}
+	}
+
+	if len(p.allowedSchemes) > 0 {
+		if _, isAllowed := p.allowedSchemes[strings.ToLower(parsedURL.Scheme)]; !isAllowed {
+			return &Violation{Rule: RuleScheme, Reason: fmt.Sprintf("scheme %q is not allowed", parsedURL.Scheme)}
+		}
+	}
+
+	host := normalizeHost(parsedURL.Hostname())
+
+	if p.blockPrivateHosts && isPrivateHost(host) {
+		return &Violation{Rule: RulePrivateHost, Reason: fmt.Sprintf("host %q is not publicly routable", host)}
+	}
+
+	if p.blocklist != nil {
+		if pattern, isBlocked := p.blocklist.Match(host); isBlocked {
+			return &Violation{Rule: RuleBlockedDomain, Reason: fmt.Sprintf("host %q is blocked by %q", host, pattern)}
+		}
+	}
+
+	return nil
+}
+
+// normalizeHost lowercases the host and removes the trailing dot of fully qualified names
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// isPrivateHost reports whether the host is a local name or an IP address that is not publicly routable
+// Host names are not resolved, so only IP literals and localhost names are detected
+func isPrivateHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	addr, ok := parseIP(host)
+	if !ok {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	return addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsUnspecified()
+}
+
+// parseIP parses IP literals including the shorthand IPv4 forms browsers accept, e.g. 127.1 or 0x7f.0.0.1
+func parseIP(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, true
+	}
+
+	return parseIPv4(host)
+}
+
+// parseIPv4 parses an IPv4 address with the rules of the WHATWG URL standard
+// The address has one to four parts, each decimal, octal with a leading zero or hex with a 0x prefix,
+// the last part fills the remaining bytes, e.g. 127.1 is 127.0.0.1 and 2130706433 is 127.0.0.1
+func parseIPv4(host string) (netip.Addr, bool) {
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+
+	var address uint64
+
+	for i, part := range parts {
+		number, ok := parseIPv4Part(part)
+		if !ok {
+			return netip.Addr{}, false
+		}
+
+		if i < len(parts)-1 {
+			if number > 255 {
+				return netip.Addr{}, false
+			}
+			address |= number << (8 * (3 - i))
+			continue
+		}
+
+		if number >= 1<<(8*(5-len(parts))) {
+			return netip.Addr{}, false
+		}
+		address |= number
+	}
+
+	return netip.AddrFrom4([4]byte{byte(address >> 24), byte(address >> 16), byte(address >> 8), byte(address)}), true
+}
+
+// parseIPv4Part parses a part of an IPv4 address as a decimal, octal or hex number
+func parseIPv4Part(part string) (uint64, bool) {
+	if part == "" {
+		return 0, false
+	}
+
+	base := 10
+	switch {
+	case len(part) >= 2 && (part[:2] == "0x" || part[:2] == "0X"):
+		part, base = part[2:], 16
+		if part == "" {
+			return 0, true
+		}
+	case len(part) >= 2 && part[0] == '0':
+		part, base = part[1:], 8
+	}
+
+	number, err := strconv.ParseUint(part, base, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return number, true
+}
@@ -0,0 +1,102 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(blocklistPath, []byte("# phishing\nevil.com\n*.phish.example\n\n"), 0o600))
+
+	blocklist, err := NewBlocklist(blocklistPath)
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.Blocklist = blocklist
+	p := NewPolicy(options)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantRule string
+	}{
+		{name: "Positive case: public https URL", url: "https://yandex.ru/search?q=go"},
+		{name: "Positive case: public IP", url: "http://8.8.8.8/"},
+		{name: "Positive case: public shorthand IP", url: "http://8.8.2056/"},
+		{name: "Positive case: subdomain of exact blocked domain", url: "https://www.evil.com/"},
+		{name: "Positive case: apex of wildcard blocked domain", url: "https://phish.example/"},
+		{name: "Negative case: scheme not allowed", url: "ftp://yandex.ru/file", wantRule: RuleScheme},
+		{name: "Negative case: too long", url: "https://yandex.ru/" + strings.Repeat("a", 2048), wantRule: RuleLength},
+		{name: "Negative case: loopback IPv4", url: "http://127.0.0.1:8080/admin", wantRule: RulePrivateHost},
+		{name: "Negative case: loopback IPv6", url: "http://[::1]/", wantRule: RulePrivateHost},
+		{name: "Negative case: IPv4-mapped loopback", url: "http://[::ffff:127.0.0.1]/", wantRule: RulePrivateHost},
+		{name: "Negative case: decimal loopback", url: "http://2130706433/", wantRule: RulePrivateHost},
+		{name: "Negative case: shorthand loopback", url: "http://127.1/", wantRule: RulePrivateHost},
+		{name: "Negative case: octal loopback", url: "http://0177.0.0.1/", wantRule: RulePrivateHost},
+		{name: "Negative case: hex shorthand loopback", url: "http://0x7f.1/", wantRule: RulePrivateHost},
+		{name: "Negative case: hex private network", url: "http://0xa000001/", wantRule: RulePrivateHost},
+		{name: "Negative case: private network", url: "http://192.168.1.10/", wantRule: RulePrivateHost},
+		{name: "Negative case: link-local metadata address", url: "http://169.254.169.254/latest/meta-data", wantRule: RulePrivateHost},
+		{name: "Negative case: unspecified address", url: "http://0.0.0.0/", wantRule: RulePrivateHost},
+		{name: "Negative case: localhost", url: "http://LocalHost./", wantRule: RulePrivateHost},
+		{name: "Negative case: localhost subdomain", url: "http://app.localhost/", wantRule: RulePrivateHost},
+		{name: "Negative case: exact blocked domain", url: "https://EVIL.com/login", wantRule: RuleBlockedDomain},
+		{name: "Negative case: wildcard blocked domain", url: "https://login.bank.phish.example/", wantRule: RuleBlockedDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violation := p.Check(tt.url)
+			if tt.wantRule == "" {
+				assert.Nil(t, violation)
+				return
+			}
+
+			require.NotNil(t, violation)
+			assert.Equal(t, tt.wantRule, violation.Rule)
+			assert.NotEmpty(t, violation.Error())
+		})
+	}
+}
+
+func TestBlocklistReload(t *testing.T) {
+	blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(blocklistPath, []byte("evil.com\n"), 0o600))
+
+	blocklist, err := NewBlocklist(blocklistPath)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go blocklist.Run(ctx, 10*time.Millisecond)
+
+	_, isBlocked := blocklist.Match("bad.org")
+	assert.False(t, isBlocked)
+
+	require.NoError(t, os.WriteFile(blocklistPath, []byte("evil.com\n*.bad.org\nbad.org\n"), 0o600))
+
+	assert.Eventually(t, func() bool {
+		_, isBlocked := blocklist.Match("bad.org")
+		return isBlocked
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.Remove(blocklistPath))
+	time.Sleep(30 * time.Millisecond)
+
+	pattern, isBlocked := blocklist.Match("www.bad.org")
+	assert.True(t, isBlocked, "patterns are kept when the file cannot be read")
+	assert.Equal(t, "*.bad.org", pattern)
+}
+
+func TestNewBlocklistMissingFile(t *testing.T) {
+	_, err := NewBlocklist(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
@@ -4,6 +4,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
 )
@@ -11,20 +13,107 @@ import (
 // ErrorOnConflict is returned when a save operation conflicts with existing data
 var ErrorOnConflict = errors.New("conflict")
 
+// ErrorShortTaken is returned when the short URL is already used by another URL pair
+var ErrorShortTaken = errors.New("short url is already taken")
+
 // URLRepository defines persistence methods for URL pairs
+//
+// A long URL counts as already shortened only while a live URL pair without expiry
+// owns it, see model.URLPair.OwnsLong. URL pairs with an expiry are always stored.
 type URLRepository interface {
 	// Save stores a single URL pair
+	// Returns ErrorShortTaken if the short URL is already in use
+	// and ErrorOnConflict if the long URL is already shortened
 	Save(ctx context.Context, urlPair *model.URLPair) error
 
+	// GetOrCreate atomically stores the URL pair unless its long URL is already shortened
+	// Returns the stored pair and whether it was created by this call
+	// Returns ErrorShortTaken if the short URL is used by another long URL
+	GetOrCreate(ctx context.Context, urlPair *model.URLPair) (*model.URLPair, bool, error)
+
 	// GetByShort retrieves a URL pair by its short URL
 	GetByShort(ctx context.Context, short string) (*model.URLPair, bool)
 
 	// SaveMany stores multiple URL pairs
-	SaveMany(ctx context.Context, urlPairs []*model.URLPair) error
+	// URL pairs whose long URL is already shortened are skipped, the stored pairs
+	// of those long URLs are returned keyed by long URL
+	// Returns ErrorShortTaken if a short URL is used by another long URL
+	SaveMany(ctx context.Context, urlPairs []*model.URLPair) (map[string]*model.URLPair, error)
 
 	// GetAllByUserID returns all URL pairs for a user
 	GetAllByUserID(ctx context.Context, userID string) ([]*model.URLPair, error)
 
-	// DeleteByShorts marks URL pairs as deleted for a user
-	DeleteByShorts(ctx context.Context, userID string, shorts []string) error
+	// GetPageByUserID returns a page of a user's URL pairs ordered by creation time and short URL
+	GetPageByUserID(ctx context.Context, query UserURLsQuery) ([]*model.URLPair, error)
+
+	// DeleteByShorts marks URL pairs as deleted for a user and returns the pairs it deleted
+	DeleteByShorts(ctx context.Context, userID string, shorts []string) ([]*model.URLPair, error)
+
+	// UpdateRedirect replaces the redirect settings of a URL pair
+	UpdateRedirect(ctx context.Context, short string, settings model.RedirectSettings) error
+
+	// DeleteExpired marks URL pairs expired at the given time as deleted
+	// Returns the number of affected URL pairs
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+
+	// CountURLs returns the number of URL pairs that are not deleted
+	CountURLs(ctx context.Context) (int64, error)
+
+	// CountUsers returns the number of distinct users that shortened URLs
+	CountUsers(ctx context.Context) (int64, error)
+
+	// ForEach calls fn for every stored URL pair, including deleted ones, until fn returns an error
+	// URL pairs are streamed from the storage where possible instead of being loaded at once
+	ForEach(ctx context.Context, fn func(urlPair *model.URLPair) error) error
+}
+
+// UserURLsQuery selects a page of a user's URL pairs
+type UserURLsQuery struct {
+	UserID string
+	// After is the position the page starts after, the first page is returned when nil
+	After *PageCursor
+	// Limit is the maximum number of URL pairs in the page
+	Limit int
+	// Descending orders URL pairs from the newest to the oldest
+	Descending bool
+	// Search keeps only URL pairs whose long URL contains the substring
+	Search string
+	// IncludeDeleted keeps deleted URL pairs in the page
+	IncludeDeleted bool
+}
+
+// PageCursor is a position in URL pairs ordered by creation time and short URL
+type PageCursor struct {
+	CreatedAt time.Time
+	Short     string
+}
+
+// comparePosition compares the position of the URL pair with the cursor
+// Returns a negative number when the URL pair goes first in ascending order
+func comparePosition(urlPair *model.URLPair, cursor PageCursor) int {
+	if c := urlPair.CreatedAt.Compare(cursor.CreatedAt); c != 0 {
+		return c
+	}
+
+	return strings.Compare(urlPair.Short, cursor.Short)
+}
+
+// findPairByShort searches URL pairs for the given short URL
+func findPairByShort(urlPairs []*model.URLPair, short string) (*model.URLPair, bool) {
+	for _, urlPair := range urlPairs {
+		if urlPair.Short == short {
+			return urlPair, true
+		}
+	}
+
+	return nil, false
+}
+
+// ClickRepository defines persistence methods for short URL clicks
+type ClickRepository interface {
+	// SaveClicks stores multiple clicks and updates their aggregates
+	SaveClicks(ctx context.Context, clicks []*model.Click) error
+
+	// GetStats returns aggregated click statistics for a short URL
+	GetStats(ctx context.Context, short string) (*model.URLStats, error)
 }
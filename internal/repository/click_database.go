@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+)
+
+// ClickDatabaseRepository implements ClickRepository using PostgreSQL
+type ClickDatabaseRepository struct {
+	db *sql.DB
+}
+
+// NewClickDatabaseRepository creates a new ClickDatabaseRepository instance
+func NewClickDatabaseRepository(db *sql.DB) *ClickDatabaseRepository {
+	return &ClickDatabaseRepository{db: db}
+}
+
+// SaveClicks stores multiple clicks and updates the daily aggregates
+func (r *ClickDatabaseRepository) SaveClicks(ctx context.Context, clicks []*model.Click) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	clickStmt, err := tx.PrepareContext(ctx, `
+        INSERT INTO url_clicks (short, clicked_at, referrer, user_agent, ip_bucket, visitor_id)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, clickStmt.Close())
+	}()
+
+	dailyStmt, err := tx.PrepareContext(ctx, `
+        INSERT INTO url_clicks_daily (short, day, clicks)
+        VALUES ($1, ($2::timestamptz AT TIME ZONE 'UTC')::date, 1)
+        ON CONFLICT (short, day) DO UPDATE SET clicks = url_clicks_daily.clicks + 1
+    `)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, dailyStmt.Close())
+	}()
+
+	for _, click := range clicks {
+		if _, err := clickStmt.ExecContext(
+			ctx,
+			click.Short,
+			click.ClickedAt,
+			click.Referrer,
+			click.UserAgent,
+			click.IPBucket,
+			click.VisitorID,
+		); err != nil {
+			return err
+		}
+
+		if _, err := dailyStmt.ExecContext(ctx, click.Short, click.ClickedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetStats returns aggregated click statistics for a short URL
+func (r *ClickDatabaseRepository) GetStats(ctx context.Context, short string) (_ *model.URLStats, err error) {
+	stats := &model.URLStats{Daily: make([]model.DailyClicks, 0)}
+
+	err = r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*), COUNT(DISTINCT visitor_id)
+        FROM url_clicks
+        WHERE short = $1
+    `, short).Scan(&stats.TotalClicks, &stats.UniqueVisitors)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT to_char(day, 'YYYY-MM-DD'), clicks
+        FROM url_clicks_daily
+        WHERE short = $1
+        ORDER BY day
+    `, short)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = errors.Join(err, rows.Close())
+	}()
+
+	for rows.Next() {
+		var daily model.DailyClicks
+		if err := rows.Scan(&daily.Date, &daily.Clicks); err != nil {
+			return nil, err
+		}
+		stats.Daily = append(stats.Daily, daily)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+)
+
+// clickCompactionThreshold is the number of appended clicks after which the file is rolled up
+const clickCompactionThreshold = 100_000
+
+// clickRecord is a line of the click file rolling up the clicks of a short URL
+// The other lines are single clicks, which have no daily histogram
+type clickRecord struct {
+	Short    string           `json:"short"`
+	Total    int64            `json:"total,omitempty"`
+	Visitors []string         `json:"visitors,omitempty"`
+	Daily    map[string]int64 `json:"daily,omitempty"`
+}
+
+// ClickFileRepository implements ClickRepository using a JSON lines file
+// Aggregates are kept in memory and rebuilt from the file on start
+// Clicks are appended one per line and rolled up into one line per short URL
+// once clickCompactionThreshold clicks were appended and when the repository is closed,
+// so the file grows with the number of short URLs and unique visitors rather than clicks
+type ClickFileRepository struct {
+	*ClickInMemoryRepository
+	filePath string
+	// appended is the number of single clicks in the file
+	appended            int
+	compactionThreshold int
+}
+
+// NewClickFileRepository creates a new ClickFileRepository instance
+func NewClickFileRepository(filePath string) (*ClickFileRepository, error) {
+	repo := &ClickFileRepository{
+		ClickInMemoryRepository: NewClickInMemoryRepository(),
+		filePath:                filePath,
+		compactionThreshold:     clickCompactionThreshold,
+	}
+
+	if _, err := os.Stat(filePath); err == nil {
+		if err := repo.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return repo, nil
+}
+
+// SaveClicks appends clicks to the file and updates their aggregates
+// Aggregates are updated only once the clicks are written and the file is closed
+func (r *ClickFileRepository) SaveClicks(_ context.Context, clicks []*model.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.append(clicks); err != nil {
+		return err
+	}
+
+	r.apply(clicks)
+	r.appended += len(clicks)
+
+	if r.appended < r.compactionThreshold {
+		return nil
+	}
+
+	return r.compact()
+}
+
+// Close rolls the appended clicks up
+func (r *ClickFileRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.appended == 0 {
+		return nil
+	}
+
+	return r.compact()
+}
+
+// compact replaces the file with one rolled up record per short URL
+// The records are sorted by short URL so that files of the same clicks are equal
+// The caller must hold the lock
+func (r *ClickFileRepository) compact() error {
+	tmpPath := r.filePath + ".tmp"
+
+	if err := r.writeAggregates(tmpPath); err != nil {
+		return errors.Join(err, os.Remove(tmpPath))
+	}
+
+	if err := os.Rename(tmpPath, r.filePath); err != nil {
+		return err
+	}
+
+	r.appended = 0
+
+	return syncDir(filepath.Dir(r.filePath))
+}
+
+// writeAggregates writes the rolled up clicks into the file and flushes it to disk
+func (r *ClickFileRepository) writeAggregates(path string) (err error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	for _, short := range slices.Sorted(maps.Keys(r.data)) {
+		aggregate := r.data[short]
+
+		record := clickRecord{
+			Short:    short,
+			Total:    aggregate.total,
+			Visitors: slices.Sorted(maps.Keys(aggregate.visitors)),
+			Daily:    aggregate.daily,
+		}
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// merge adds the rolled up clicks of the record to the aggregates
+// The caller must hold the lock
+func (r *ClickFileRepository) merge(record *clickRecord) {
+	aggregate := r.aggregateFor(record.Short)
+
+	aggregate.total += record.Total
+	for _, visitor := range record.Visitors {
+		aggregate.visitors[visitor] = struct{}{}
+	}
+	for date, clicks := range record.Daily {
+		aggregate.daily[date] += clicks
+	}
+}
+
+// append writes clicks to the end of the file
+func (r *ClickFileRepository) append(clicks []*model.Click) (err error) {
+	file, err := os.OpenFile(r.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	for _, click := range clicks {
+		if err := encoder.Encode(click); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// load rebuilds the aggregates from the file
+// A torn last line left by a crash is truncated so that later appends start on a new line, corruption elsewhere is an error
+func (r *ClickFileRepository) load() (err error) {
+	file, err := os.Open(r.filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	reader := bufio.NewReader(file)
+
+	var offset int64
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return readErr
+		}
+
+		isLast := errors.Is(readErr, io.EOF)
+
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var record clickRecord
+			err := json.Unmarshal(trimmed, &record)
+
+			if err != nil && !isLast {
+				return fmt.Errorf("corrupted click at offset %d: %w", offset, err)
+			}
+
+			if err != nil || line[len(line)-1] != '\n' {
+				logger.Log.Warn("truncating torn last line of click storage file",
+					zap.String("path", r.filePath),
+					zap.Int64("offset", offset),
+				)
+
+				return os.Truncate(r.filePath, offset)
+			}
+
+			if record.Daily != nil {
+				r.merge(&record)
+			} else {
+				var click model.Click
+				if err := json.Unmarshal(trimmed, &click); err != nil {
+					return fmt.Errorf("corrupted click at offset %d: %w", offset, err)
+				}
+
+				r.apply([]*model.Click{&click})
+				r.appended++
+			}
+		}
+
+		offset += int64(len(line))
+
+		if isLast {
+			return nil
+		}
+	}
+}
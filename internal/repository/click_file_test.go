@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+)
+
+func TestClickFileRepositoryTornLastLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		tail    string
+		wantErr bool
+	}{
+		{
+			name: "Positive case: partially written click",
+			tail: `{"short":"abc","clicked_at":"2024-01-02T`,
+		},
+		{
+			name: "Positive case: click without line break",
+			tail: `{"short":"abc","clicked_at":"2024-01-02T10:00:00Z","visitor_id":"v3"}`,
+		},
+		{
+			name:    "Negative case: corrupted line before the last one",
+			tail:    "{not json}\n" + `{"short":"abc","clicked_at":"2024-01-02T10:00:00Z","visitor_id":"v3"}` + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "clicks.jsonl")
+			clickedAt := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+
+			repo, err := NewClickFileRepository(path)
+			require.NoError(t, err)
+			require.NoError(t, repo.SaveClicks(ctx, []*model.Click{
+				{Short: "abc", ClickedAt: clickedAt, VisitorID: "v1"},
+				{Short: "abc", ClickedAt: clickedAt, VisitorID: "v2"},
+			}))
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			appendToFile(t, path, tt.tail)
+
+			repo, err = NewClickFileRepository(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			stats, err := repo.GetStats(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), stats.TotalClicks)
+
+			truncated, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, info.Size(), truncated.Size())
+
+			require.NoError(t, repo.SaveClicks(ctx, []*model.Click{{Short: "abc", ClickedAt: clickedAt, VisitorID: "v3"}}))
+
+			repo, err = NewClickFileRepository(path)
+			require.NoError(t, err)
+
+			stats, err = repo.GetStats(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), stats.TotalClicks)
+			assert.Equal(t, int64(3), stats.UniqueVisitors)
+		})
+	}
+}
+
+func TestClickFileRepositoryCompaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		close     bool
+	}{
+		{
+			name:      "Positive case: clicks are rolled up on close",
+			threshold: clickCompactionThreshold,
+			close:     true,
+		},
+		{
+			name:      "Positive case: clicks are rolled up once the threshold is reached",
+			threshold: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "clicks.jsonl")
+			firstDay := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+			secondDay := firstDay.AddDate(0, 0, 1)
+
+			repo, err := NewClickFileRepository(path)
+			require.NoError(t, err)
+			repo.compactionThreshold = tt.threshold
+
+			require.NoError(t, repo.SaveClicks(ctx, []*model.Click{
+				{Short: "abc", ClickedAt: firstDay, VisitorID: "v1"},
+				{Short: "xyz", ClickedAt: firstDay, VisitorID: "v1"},
+			}))
+			require.NoError(t, repo.SaveClicks(ctx, []*model.Click{
+				{Short: "abc", ClickedAt: secondDay, VisitorID: "v2"},
+			}))
+			if tt.close {
+				require.NoError(t, repo.Close())
+			}
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t,
+				`{"short":"abc","total":2,"visitors":["v1","v2"],"daily":{"2024-01-02":1,"2024-01-03":1}}`+"\n"+
+					`{"short":"xyz","total":1,"visitors":["v1"],"daily":{"2024-01-02":1}}`+"\n",
+				string(content),
+			)
+
+			repo, err = NewClickFileRepository(path)
+			require.NoError(t, err)
+			require.NoError(t, repo.SaveClicks(ctx, []*model.Click{
+				{Short: "abc", ClickedAt: secondDay, VisitorID: "v1"},
+			}))
+
+			repo, err = NewClickFileRepository(path)
+			require.NoError(t, err)
+
+			stats, err := repo.GetStats(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), stats.TotalClicks)
+			assert.Equal(t, int64(2), stats.UniqueVisitors)
+			assert.Equal(t, []model.DailyClicks{
+				{Date: "2024-01-02", Clicks: 1},
+				{Date: "2024-01-03", Clicks: 2},
+			}, stats.Daily)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+)
+
+// clickDateLayout is the layout of the per-day histogram keys
+const clickDateLayout = "2006-01-02"
+
+// clickAggregate holds aggregated clicks of a single short URL
+type clickAggregate struct {
+	total    int64
+	visitors map[string]struct{}
+	daily    map[string]int64
+}
+
+// ClickInMemoryRepository implements ClickRepository using in-memory aggregates
+type ClickInMemoryRepository struct {
+	data map[string]*clickAggregate
+	mu   sync.RWMutex
+}
+
+// NewClickInMemoryRepository creates a new ClickInMemoryRepository instance
+func NewClickInMemoryRepository() *ClickInMemoryRepository {
+	return &ClickInMemoryRepository{data: make(map[string]*clickAggregate)}
+}
+
+// SaveClicks stores multiple clicks and updates their aggregates
+func (r *ClickInMemoryRepository) SaveClicks(_ context.Context, clicks []*model.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apply(clicks)
+
+	return nil
+}
+
+// GetStats returns aggregated click statistics for a short URL
+func (r *ClickInMemoryRepository) GetStats(_ context.Context, short string) (*model.URLStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := &model.URLStats{Daily: make([]model.DailyClicks, 0)}
+
+	aggregate, ok := r.data[short]
+	if !ok {
+		return stats, nil
+	}
+
+	stats.TotalClicks = aggregate.total
+	stats.UniqueVisitors = int64(len(aggregate.visitors))
+
+	for date, clicks := range aggregate.daily {
+		stats.Daily = append(stats.Daily, model.DailyClicks{Date: date, Clicks: clicks})
+	}
+
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Date < stats.Daily[j].Date
+	})
+
+	return stats, nil
+}
+
+// apply adds clicks to the aggregates
+// The caller must hold the lock
+func (r *ClickInMemoryRepository) apply(clicks []*model.Click) {
+	for _, click := range clicks {
+		aggregate := r.aggregateFor(click.Short)
+
+		aggregate.total++
+		aggregate.visitors[click.VisitorID] = struct{}{}
+		aggregate.daily[click.ClickedAt.UTC().Format(clickDateLayout)]++
+	}
+}
+
+// aggregateFor returns the aggregate of the short URL, creating it if there is none
+// The caller must hold the lock
+func (r *ClickInMemoryRepository) aggregateFor(short string) *clickAggregate {
+	aggregate, ok := r.data[short]
+	if !ok {
+		aggregate = &clickAggregate{
+			visitors: make(map[string]struct{}),
+			daily:    make(map[string]int64),
+		}
+		r.data[short] = aggregate
+	}
+
+	return aggregate
+}
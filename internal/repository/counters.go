@@ -0,0 +1,34 @@
+package repository
+
+import "github.com/alikhanturusbekov/go-url-shortener/internal/model"
+
+// urlCounters tracks aggregate counts of stored URL pairs
+// so that they can be reported without scanning the storage
+type urlCounters struct {
+	total      int64
+	activeURLs int64
+	users      map[string]struct{}
+}
+
+// newURLCounters creates empty urlCounters
+func newURLCounters() urlCounters {
+	return urlCounters{users: make(map[string]struct{})}
+}
+
+// add accounts for a newly stored URL pair
+func (c *urlCounters) add(urlPair *model.URLPair) {
+	c.total++
+
+	if !urlPair.IsDeleted {
+		c.activeURLs++
+	}
+
+	if urlPair.UserID != "" {
+		c.users[urlPair.UserID] = struct{}{}
+	}
+}
+
+// markDeleted accounts for a URL pair that has been marked as deleted
+func (c *urlCounters) markDeleted() {
+	c.activeURLs--
+}
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
 
@@ -12,6 +14,27 @@ import (
 	"github.com/lib/pq"
 )
 
+// urlPairColumns lists the url_pairs columns mapped to model.URLPair
+const urlPairColumns = "uid, short, long, user_id, is_deleted, expires_at, created_at, redirect_type, pass_query, utm_params"
+
+const (
+	// shortPrimaryKeyConstraint is the name of the primary key constraint on url_pairs.short
+	shortPrimaryKeyConstraint = "url_pairs_pkey"
+	// longUniqueTarget is the conflict target of the partial unique index url_pairs_unique_long
+	// Only live URL pairs without expiry hold their long URL, so expired and deleted ones do not block it
+	longUniqueTarget = "(long) WHERE is_deleted = FALSE AND expires_at IS NULL"
+)
+
+// selectLongOwnerQuery selects the URL pair that owns the long URL, see model.URLPair.OwnsLong
+const selectLongOwnerQuery = `
+        SELECT ` + urlPairColumns + `
+        FROM url_pairs
+        WHERE long = $1 AND is_deleted = FALSE AND expires_at IS NULL;
+    `
+
+// getOrCreateAttempts limits the inserts of GetOrCreate racing with deletions of the long URL owner
+const getOrCreateAttempts = 3
+
 // URLDatabaseRepository implements URLRepository using PostgreSQL
 type URLDatabaseRepository struct {
 	db *sql.DB
@@ -25,10 +48,16 @@ func NewURLDatabaseRepository(db *sql.DB) *URLDatabaseRepository {
 // Save stores a single URL pair
 func (r *URLDatabaseRepository) Save(ctx context.Context, urlPair *model.URLPair) error {
 	query := `
-        INSERT INTO url_pairs (uid, short, long, user_id, is_deleted)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO url_pairs (` + urlPairColumns + `)
+        VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, now()), $8, $9, $10)
     `
-	_, err := r.db.ExecContext(ctx, query, urlPair.ID, urlPair.Short, urlPair.Long, urlPair.UserID, urlPair.IsDeleted)
+
+	args, err := urlPairArgs(urlPair)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query, args...)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -38,6 +67,10 @@ func (r *URLDatabaseRepository) Save(ctx context.Context, urlPair *model.URLPair
 		}
 
 		if pgErr.Code == pgerrcode.UniqueViolation {
+			if pgErr.ConstraintName == shortPrimaryKeyConstraint {
+				return ErrorShortTaken
+			}
+
 			return ErrorOnConflict
 		}
 	}
@@ -45,63 +78,158 @@ func (r *URLDatabaseRepository) Save(ctx context.Context, urlPair *model.URLPair
 	return err
 }
 
+// GetOrCreate stores the URL pair unless its long URL is already shortened
+// Returns the stored pair and whether it was created by this call
+//
+// A conflict on the long URL turns the insert into a no-op returning no row, the owner
+// of the long URL is then read in the same transaction. A read committed statement sees
+// the row of a concurrent transaction as soon as the insert has waited for its commit.
+func (r *URLDatabaseRepository) GetOrCreate(ctx context.Context, urlPair *model.URLPair) (_ *model.URLPair, _ bool, err error) {
+	args, err := urlPairArgs(urlPair)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	query := `
+        INSERT INTO url_pairs (` + urlPairColumns + `)
+        VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, now()), $8, $9, $10)
+        ON CONFLICT ` + longUniqueTarget + ` DO NOTHING
+        RETURNING ` + urlPairColumns + `;
+    `
+
+	for range getOrCreateAttempts {
+		created, insertErr := scanURLPair(tx.QueryRowContext(ctx, query, args...))
+		if insertErr == nil {
+			return created, true, tx.Commit()
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(insertErr, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == shortPrimaryKeyConstraint {
+			return nil, false, ErrorShortTaken
+		}
+		if !errors.Is(insertErr, sql.ErrNoRows) {
+			return nil, false, insertErr
+		}
+
+		stored, selectErr := scanURLPair(tx.QueryRowContext(ctx, selectLongOwnerQuery, urlPair.Long))
+		if selectErr == nil {
+			return stored, false, tx.Commit()
+		}
+		if !errors.Is(selectErr, sql.ErrNoRows) {
+			return nil, false, selectErr
+		}
+
+		// The owner of the long URL was deleted between the statements, insert again
+	}
+
+	return nil, false, errors.New("long URL ownership kept changing")
+}
+
 // GetByShort retrieves a URL pair by its short URL
 func (r *URLDatabaseRepository) GetByShort(ctx context.Context, short string) (*model.URLPair, bool) {
-	var result model.URLPair
-
 	query := `
-        SELECT uid, short, long, user_id, is_deleted
+        SELECT ` + urlPairColumns + `
         FROM url_pairs
         WHERE short = $1;
     `
 
-	err := r.db.QueryRowContext(ctx, query, short).Scan(
-		&result.ID,
-		&result.Short,
-		&result.Long,
-		&result.UserID,
-		&result.IsDeleted,
-	)
-
-	if errors.Is(err, sql.ErrNoRows) {
+	result, err := scanURLPair(r.db.QueryRowContext(ctx, query, short))
+	if err != nil {
 		return nil, false
 	}
 
-	return &result, err == nil
+	return result, true
 }
 
 // SaveMany stores multiple URL pairs
-func (r *URLDatabaseRepository) SaveMany(ctx context.Context, urlPairs []*model.URLPair) error {
-	tx, err := r.db.Begin()
+// URL pairs whose long URL is already stored are skipped and the stored pairs are returned keyed by long URL
+func (r *URLDatabaseRepository) SaveMany(ctx context.Context, urlPairs []*model.URLPair) (existing map[string]*model.URLPair, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
-		err = errors.Join(err, tx.Rollback())
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
 	}()
 
-	stmt, err := tx.PrepareContext(ctx, "INSERT INTO url_pairs (uid, short, long, user_id, is_deleted) VALUES ($1, $2, $3, $4, $5)")
+	insertStmt, err := tx.PrepareContext(ctx, `
+        INSERT INTO url_pairs (`+urlPairColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, now()), $8, $9, $10)
+        ON CONFLICT `+longUniqueTarget+` DO NOTHING
+    `)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer func() {
+		err = errors.Join(err, insertStmt.Close())
+	}()
+
+	selectStmt, err := tx.PrepareContext(ctx, selectLongOwnerQuery)
+	if err != nil {
+		return nil, err
 	}
 	defer func() {
-		err = errors.Join(err, stmt.Close())
+		err = errors.Join(err, selectStmt.Close())
 	}()
 
+	existing = make(map[string]*model.URLPair)
+	inserted := make(map[string]struct{}, len(urlPairs))
+
 	for _, urlPair := range urlPairs {
-		_, fail := stmt.Exec(urlPair.ID, urlPair.Short, urlPair.Long, urlPair.UserID, urlPair.IsDeleted)
-		if fail != nil {
-			return fail
+		if _, isFound := inserted[urlPair.Long]; isFound {
+			continue
+		}
+
+		args, argsErr := urlPairArgs(urlPair)
+		if argsErr != nil {
+			return nil, argsErr
+		}
+
+		result, execErr := insertStmt.ExecContext(ctx, args...)
+		if execErr != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(execErr, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == shortPrimaryKeyConstraint {
+				return nil, ErrorShortTaken
+			}
+
+			return nil, execErr
+		}
+
+		affected, affectedErr := result.RowsAffected()
+		if affectedErr != nil {
+			return nil, affectedErr
+		}
+
+		if affected > 0 {
+			inserted[urlPair.Long] = struct{}{}
+			continue
 		}
+
+		stored, scanErr := scanURLPair(selectStmt.QueryRowContext(ctx, urlPair.Long))
+		if scanErr != nil {
+			return nil, scanErr
+		}
+
+		existing[urlPair.Long] = stored
 	}
 
-	return tx.Commit()
+	return existing, tx.Commit()
 }
 
 // GetAllByUserID returns all URL pairs for a user
-func (r *URLDatabaseRepository) GetAllByUserID(ctx context.Context, userID string) ([]*model.URLPair, error) {
-	var result []*model.URLPair
-
+func (r *URLDatabaseRepository) GetAllByUserID(ctx context.Context, userID string) (result []*model.URLPair, err error) {
 	query := `
         SELECT uid, short, long, user_id
         FROM url_pairs
@@ -125,30 +253,278 @@ func (r *URLDatabaseRepository) GetAllByUserID(ctx context.Context, userID strin
 	}
 
 	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+
+	return result, nil
+}
+
+// GetPageByUserID returns a page of a user's URL pairs ordered by creation time and short URL
+// Pages are read with keyset pagination over the user_id, created_at, short index
+func (r *URLDatabaseRepository) GetPageByUserID(ctx context.Context, query UserURLsQuery) (result []*model.URLPair, err error) {
+	comparison, direction := ">", "ASC"
+	if query.Descending {
+		comparison, direction = "<", "DESC"
+	}
+
+	statement := `
+        SELECT ` + urlPairColumns + `
+        FROM url_pairs
+        WHERE user_id = $1
+            AND ($2 OR is_deleted = FALSE)
+            AND ($3::text = '' OR strpos(long, $3) > 0)
+            AND ($4::timestamptz IS NULL OR (created_at, short) ` + comparison + ` ($4, $5))
+        ORDER BY created_at ` + direction + `, short ` + direction + `
+        LIMIT $6;
+    `
+
+	var (
+		afterCreatedAt *time.Time
+		afterShort     string
+	)
+	if query.After != nil {
+		afterCreatedAt, afterShort = &query.After.CreatedAt, query.After.Short
+	}
+
+	rows, err := r.db.QueryContext(ctx, statement, query.UserID, query.IncludeDeleted, query.Search, afterCreatedAt, afterShort, query.Limit)
+	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		err = errors.Join(err, rows.Close())
+	}()
+
+	for rows.Next() {
+		pair, scanErr := scanURLPair(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		result = append(result, pair)
+	}
+
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
 
 	return result, nil
 }
 
-// DeleteByShorts marks URL pairs as deleted for a user
-func (r *URLDatabaseRepository) DeleteByShorts(ctx context.Context, userID string, shorts []string) error {
+// DeleteByShorts marks URL pairs as deleted for a user and returns the pairs it deleted
+func (r *URLDatabaseRepository) DeleteByShorts(ctx context.Context, userID string, shorts []string) (result []*model.URLPair, err error) {
 	query := `
 		UPDATE url_pairs
 		SET is_deleted = TRUE
-		WHERE user_id = $1 AND short = ANY($2)
+		WHERE user_id = $1 AND short = ANY($2) AND is_deleted = FALSE
+		RETURNING ` + urlPairColumns + `
 	`
 
-	_, err := r.db.ExecContext(
+	rows, err := r.db.QueryContext(
 		ctx,
 		query,
 		userID,
 		pq.Array(shorts),
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = errors.Join(err, rows.Close())
+	}()
+
+	for rows.Next() {
+		pair, scanErr := scanURLPair(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		result = append(result, pair)
+	}
+
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+
+	return result, nil
+}
+
+// UpdateRedirect replaces the redirect settings of a URL pair
+func (r *URLDatabaseRepository) UpdateRedirect(ctx context.Context, short string, settings model.RedirectSettings) error {
+	query := `
+		UPDATE url_pairs
+		SET redirect_type = $2, pass_query = $3, utm_params = $4
+		WHERE short = $1
+	`
+
+	utm, err := encodeUTM(settings.UTM)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query, short, settings.Type, settings.PassQuery, utm)
 
 	return err
 }
 
+// DeleteExpired marks URL pairs expired at the given time as deleted
+func (r *URLDatabaseRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	query := `
+		UPDATE url_pairs
+		SET is_deleted = TRUE
+		WHERE is_deleted = FALSE AND expires_at IS NOT NULL AND expires_at <= $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// CountURLs returns the number of URL pairs that are not deleted
+func (r *URLDatabaseRepository) CountURLs(ctx context.Context) (int64, error) {
+	var count int64
+
+	query := `
+        SELECT COUNT(*)
+        FROM url_pairs
+        WHERE is_deleted = false;
+    `
+
+	err := r.db.QueryRowContext(ctx, query).Scan(&count)
+
+	return count, err
+}
+
+// CountUsers returns the number of distinct users that shortened URLs
+func (r *URLDatabaseRepository) CountUsers(ctx context.Context) (int64, error) {
+	var count int64
+
+	query := `
+        SELECT COUNT(DISTINCT user_id)
+        FROM url_pairs
+        WHERE user_id IS NOT NULL;
+    `
+
+	err := r.db.QueryRowContext(ctx, query).Scan(&count)
+
+	return count, err
+}
+
+// ForEach calls fn for every stored URL pair, including deleted ones, until fn returns an error
+// Rows are read one by one in short URL order
+func (r *URLDatabaseRepository) ForEach(ctx context.Context, fn func(urlPair *model.URLPair) error) (err error) {
+	query := `
+        SELECT ` + urlPairColumns + `
+        FROM url_pairs
+        ORDER BY short;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, rows.Close())
+	}()
+
+	for rows.Next() {
+		pair, scanErr := scanURLPair(rows)
+		if scanErr != nil {
+			return scanErr
+		}
+
+		if fnErr := fn(pair); fnErr != nil {
+			return fnErr
+		}
+	}
+
+	return rows.Err()
+}
+
+// rowScanner is implemented by sql.Row and sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanURLPair reads a URL pair selected as urlPairColumns followed by the extra destinations
+func scanURLPair(row rowScanner, extra ...any) (*model.URLPair, error) {
+	var (
+		pair model.URLPair
+		utm  []byte
+	)
+
+	dest := append([]any{
+		&pair.ID,
+		&pair.Short,
+		&pair.Long,
+		&pair.UserID,
+		&pair.IsDeleted,
+		&pair.ExpiresAt,
+		&pair.CreatedAt,
+		&pair.Redirect.Type,
+		&pair.Redirect.PassQuery,
+		&utm,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if utm != nil {
+		pair.Redirect.UTM = &model.UTMParams{}
+		if err := json.Unmarshal(utm, pair.Redirect.UTM); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pair, nil
+}
+
+// urlPairArgs returns the values of urlPairColumns for an insert of the URL pair
+func urlPairArgs(urlPair *model.URLPair) ([]any, error) {
+	utm, err := encodeUTM(urlPair.Redirect.UTM)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		urlPair.ID,
+		urlPair.Short,
+		urlPair.Long,
+		urlPair.UserID,
+		urlPair.IsDeleted,
+		urlPair.ExpiresAt,
+		createdAtOrNil(urlPair),
+		urlPair.Redirect.Type,
+		urlPair.Redirect.PassQuery,
+		utm,
+	}, nil
+}
+
+// encodeUTM encodes UTM parameters for the utm_params column, nil is stored as NULL
+func encodeUTM(utm *model.UTMParams) (any, error) {
+	if utm == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(utm)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// createdAtOrNil returns the creation time of the URL pair
+// Returns nil for an unknown creation time so that the current time is stored
+func createdAtOrNil(urlPair *model.URLPair) *time.Time {
+	if urlPair.CreatedAt.IsZero() {
+		return nil
+	}
+
+	return &urlPair.CreatedAt
+}
+
 // Close closes the database connection
 func (r *URLDatabaseRepository) Close() error {
 	return r.db.Close()
@@ -1,176 +1,360 @@
 package repository
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"sync"
+	"time"
+
+	"go.uber.org/zap"
 
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+)
+
+// SyncPolicy defines when the file repository flushes the log to disk
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs the log after every write
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs the log periodically in the background
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system
+	SyncNever SyncPolicy = "never"
 )
 
-// URLFileRepository implements URLRepository using a JSON file
+// Operations of the log records
+const (
+	opCreate   = "create"
+	opDelete   = "delete"
+	opExpire   = "expire"
+	opRedirect = "redirect"
+)
+
+// snapshotSuffix is appended to the log path to get the snapshot path
+const snapshotSuffix = ".snapshot"
+
+// legacyBackupSuffix is appended to the log path to get the path the legacy file is kept at after migration
+const legacyBackupSuffix = ".legacy.bak"
+
+// maxFileSize is the read limit used to scan the whole log
+const maxFileSize = 1 << 62
+
+// FileRepositoryOptions configures URLFileRepository
+type FileRepositoryOptions struct {
+	// SyncPolicy defines when the log is flushed to disk, SyncAlways by default
+	SyncPolicy SyncPolicy
+	// SyncInterval is the flush period of the SyncInterval policy
+	SyncInterval time.Duration
+	// CompactionInterval is the period of log compaction into the snapshot, disabled when zero
+	CompactionInterval time.Duration
+}
+
+// fileRecord is a single entry of the append-only log
+type fileRecord struct {
+	Op       string                  `json:"op"`
+	Pairs    []*model.URLPair        `json:"pairs,omitempty"`
+	UserID   string                  `json:"user_id,omitempty"`
+	Shorts   []string                `json:"shorts,omitempty"`
+	At       *time.Time              `json:"at,omitempty"`
+	Redirect *model.RedirectSettings `json:"redirect,omitempty"`
+}
+
+// URLFileRepository implements URLRepository using an append-only JSON lines log
+//
+// Every change is appended to the log and applied to an in-memory index that serves
+// all reads. On start the state is rebuilt from the snapshot and the log, compaction
+// writes the current state into the snapshot and truncates the log.
+// Files in the legacy JSON array format are migrated on start.
 type URLFileRepository struct {
 	filePath string
-	data     []*model.URLPair
-	mu       sync.RWMutex
+	options  FileRepositoryOptions
+	memory   *URLInMemoryRepository
+	log      *os.File
+	dirty    bool
+	mu       sync.Mutex
+	stop     chan struct{}
+	wg       sync.WaitGroup
 }
 
 // NewURLFileRepository creates a new URLFileRepository instance
-func NewURLFileRepository(filePath string) (*URLFileRepository, error) {
+func NewURLFileRepository(filePath string, options FileRepositoryOptions) (*URLFileRepository, error) {
+	if options.SyncPolicy == "" {
+		options.SyncPolicy = SyncAlways
+	}
+
+	switch options.SyncPolicy {
+	case SyncAlways, SyncNever:
+	case SyncInterval:
+		if options.SyncInterval <= 0 {
+			return nil, errors.New("sync interval must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown sync policy %q", options.SyncPolicy)
+	}
+
 	repo := &URLFileRepository{
 		filePath: filePath,
-		data:     make([]*model.URLPair, 0),
+		options:  options,
+		memory:   NewURLInMemoryRepository(),
+		stop:     make(chan struct{}),
 	}
 
-	if _, err := os.Stat(filePath); err == nil {
-		if err := repo.load(); err != nil {
-			return nil, err
-		}
+	if err := repo.load(); err != nil {
+		return nil, err
 	}
 
+	repo.startBackground()
+
 	return repo, nil
 }
 
 // Save stores a single URL pair
-func (r *URLFileRepository) Save(_ context.Context, urlPair *model.URLPair) error {
+func (r *URLFileRepository) Save(ctx context.Context, urlPair *model.URLPair) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	file, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_RDWR, 0644)
-	if err != nil {
-		return err
+	if _, isFound := r.memory.GetByShort(ctx, urlPair.Short); isFound {
+		return ErrorShortTaken
 	}
-	defer func() {
-		err = errors.Join(err, file.Close())
-	}()
 
-	stat, err := file.Stat()
+	_, existing, err := r.memory.filterNew([]*model.URLPair{urlPair})
 	if err != nil {
 		return err
 	}
 
-	itemJSON, err := json.Marshal(urlPair)
-	if err != nil {
+	if len(existing) > 0 {
+		return ErrorOnConflict
+	}
+
+	if err := r.appendRecord(fileRecord{Op: opCreate, Pairs: []*model.URLPair{urlPair}}); err != nil {
 		return err
 	}
 
-	err = r.addRecord(file, itemJSON, stat.Size() == 0)
+	return r.memory.Save(ctx, urlPair)
+}
+
+// GetOrCreate stores the URL pair unless its long URL is already shortened
+// Returns the stored pair and whether it was created by this call
+func (r *URLFileRepository) GetOrCreate(ctx context.Context, urlPair *model.URLPair) (*model.URLPair, bool, error) {
+	existing, err := r.SaveMany(ctx, []*model.URLPair{urlPair})
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
-	r.data = append(r.data, urlPair)
+	if stored, isFound := existing[urlPair.Long]; isFound {
+		return stored, false, nil
+	}
 
-	return nil
+	return urlPair, true, nil
 }
 
 // GetByShort retrieves a URL pair by its short URL
-func (r *URLFileRepository) GetByShort(_ context.Context, short string) (*model.URLPair, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+func (r *URLFileRepository) GetByShort(ctx context.Context, short string) (*model.URLPair, bool) {
+	return r.memory.GetByShort(ctx, short)
+}
 
-	for _, urlPair := range r.data {
-		if urlPair.Short == short {
-			return urlPair, true
-		}
+// SaveMany stores multiple URL pairs
+// URL pairs whose long URL is already stored are skipped and the stored pairs are returned keyed by long URL
+func (r *URLFileRepository) SaveMany(ctx context.Context, urlPairs []*model.URLPair) (map[string]*model.URLPair, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	newPairs, existing, err := r.memory.filterNew(urlPairs)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, false
+	if len(newPairs) == 0 {
+		return existing, nil
+	}
+
+	if err := r.appendRecord(fileRecord{Op: opCreate, Pairs: newPairs}); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.memory.SaveMany(ctx, newPairs); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
 }
 
-// SaveMany stores multiple URL pairs
-func (r *URLFileRepository) SaveMany(_ context.Context, urlPairs []*model.URLPair) error {
+// GetAllByUserID returns all URL pairs for a user
+func (r *URLFileRepository) GetAllByUserID(ctx context.Context, userID string) ([]*model.URLPair, error) {
+	return r.memory.GetAllByUserID(ctx, userID)
+}
+
+// GetPageByUserID returns a page of a user's URL pairs ordered by creation time and short URL
+func (r *URLFileRepository) GetPageByUserID(ctx context.Context, query UserURLsQuery) ([]*model.URLPair, error) {
+	return r.memory.GetPageByUserID(ctx, query)
+}
+
+// DeleteByShorts marks URL pairs as deleted for a user and returns the pairs it deleted
+func (r *URLFileRepository) DeleteByShorts(ctx context.Context, userID string, shorts []string) ([]*model.URLPair, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	file, err := os.OpenFile(r.filePath, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		return err
+	if err := r.appendRecord(fileRecord{Op: opDelete, UserID: userID, Shorts: shorts}); err != nil {
+		return nil, err
 	}
-	defer func() {
-		err = errors.Join(err, file.Close())
-	}()
 
-	stat, err := file.Stat()
-	if err != nil {
+	return r.memory.DeleteByShorts(ctx, userID, shorts)
+}
+
+// UpdateRedirect replaces the redirect settings of a URL pair
+func (r *URLFileRepository) UpdateRedirect(ctx context.Context, short string, settings model.RedirectSettings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.appendRecord(fileRecord{Op: opRedirect, Shorts: []string{short}, Redirect: &settings}); err != nil {
 		return err
 	}
 
-	isFirstRecord := stat.Size() == 0
+	return r.memory.UpdateRedirect(ctx, short, settings)
+}
 
-	for _, urlPair := range urlPairs {
-		urlJSON, err := json.Marshal(urlPair)
-		if err != nil {
-			return err
-		}
+// DeleteExpired marks URL pairs expired at the given time as deleted
+func (r *URLFileRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-		if err := r.addRecord(file, urlJSON, isFirstRecord); err != nil {
-			return err
-		}
+	// The record is appended only when something expired to keep idle reaper ticks out of the log,
+	// a failed append is harmless as expired URL pairs are not served and are reaped again after a restart
+	affected, err := r.memory.DeleteExpired(ctx, now)
+	if err != nil || affected == 0 {
+		return affected, err
+	}
 
-		isFirstRecord = false
+	if err := r.appendRecord(fileRecord{Op: opExpire, At: &now}); err != nil {
+		return 0, err
 	}
 
-	return nil
+	return affected, nil
 }
 
-// GetAllByUserID returns all URL pairs for a user
-func (r *URLFileRepository) GetAllByUserID(_ context.Context, userID string) ([]*model.URLPair, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// CountURLs returns the number of URL pairs that are not deleted
+func (r *URLFileRepository) CountURLs(ctx context.Context) (int64, error) {
+	return r.memory.CountURLs(ctx)
+}
 
-	var result []*model.URLPair
+// CountUsers returns the number of distinct users that shortened URLs
+func (r *URLFileRepository) CountUsers(ctx context.Context) (int64, error) {
+	return r.memory.CountUsers(ctx)
+}
 
-	for _, urlPair := range r.data {
-		if urlPair.UserID == userID && !urlPair.IsDeleted {
-			result = append(result, urlPair)
-		}
-	}
+// ForEach calls fn for every stored URL pair, including deleted ones, until fn returns an error
+func (r *URLFileRepository) ForEach(ctx context.Context, fn func(urlPair *model.URLPair) error) error {
+	return r.memory.ForEach(ctx, fn)
+}
 
-	return result, nil
+// Compact writes the current state into the snapshot and truncates the log
+func (r *URLFileRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.compact()
 }
 
-// DeleteByShorts marks URL pairs as deleted for a user
-func (r *URLFileRepository) DeleteByShorts(ctx context.Context, userID string, shorts []string) error {
+// Close stops background tasks, flushes and closes the log
+func (r *URLFileRepository) Close() error {
+	close(r.stop)
+	r.wg.Wait()
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	for _, urlPair := range r.data {
-		if urlPair.UserID == userID && !urlPair.IsDeleted && slices.Contains(shorts, urlPair.Short) {
-			urlPair.IsDeleted = true
-		}
-	}
+	return errors.Join(r.log.Sync(), r.log.Close())
+}
 
-	err := r.syncFile(ctx)
+// appendRecord writes the record to the log according to the sync policy
+// The caller must hold the lock
+func (r *URLFileRepository) appendRecord(record fileRecord) error {
+	line, err := json.Marshal(record)
 	if err != nil {
 		return err
 	}
 
+	if _, err := r.log.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if r.options.SyncPolicy == SyncAlways {
+		return r.log.Sync()
+	}
+
+	r.dirty = true
+
 	return nil
 }
 
-// syncFile rewrites the file with current in-memory data
-func (r *URLFileRepository) syncFile(ctx context.Context) error {
-	err := os.Truncate(r.filePath, 0)
-	if err != nil {
+// apply replays the record on the in-memory index
+func (r *URLFileRepository) apply(record fileRecord) error {
+	ctx := context.Background()
+
+	switch record.Op {
+	case opCreate:
+		// Records were checked when written, a short URL stored twice is left by
+		// a crash between writing the snapshot and truncating the log
+		r.memory.restore(record.Pairs)
+		return nil
+
+	case opDelete:
+		_, err := r.memory.DeleteByShorts(ctx, record.UserID, record.Shorts)
 		return err
+
+	case opRedirect:
+		if len(record.Shorts) != 1 || record.Redirect == nil {
+			return errors.New("redirect record without short URL or settings")
+		}
+		return r.memory.UpdateRedirect(ctx, record.Shorts[0], *record.Redirect)
+
+	case opExpire:
+		if record.At == nil {
+			return errors.New("expire record without time")
+		}
+		_, err := r.memory.DeleteExpired(ctx, *record.At)
+		return err
+	}
+
+	return fmt.Errorf("unknown record operation %q", record.Op)
+}
+
+// load rebuilds the state from the snapshot and the log, migrating the legacy format
+func (r *URLFileRepository) load() error {
+	if err := r.loadSnapshot(); err != nil {
+		return fmt.Errorf("load snapshot: %w", err)
 	}
 
-	err = r.SaveMany(ctx, r.data)
+	log, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return err
 	}
+	r.log = log
+
+	if err := r.loadLog(); err != nil {
+		return errors.Join(err, log.Close())
+	}
 
 	return nil
 }
 
-// load reads existing URL pairs from the file
-func (r *URLFileRepository) load() error {
-	file, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_RDWR, 0644)
+// loadSnapshot applies the snapshot to the in-memory index if it exists
+func (r *URLFileRepository) loadSnapshot() (err error) {
+	file, err := os.Open(r.filePath + snapshotSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -178,45 +362,270 @@ func (r *URLFileRepository) load() error {
 		err = errors.Join(err, file.Close())
 	}()
 
-	var urlPairs []*model.URLPair
 	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&urlPairs); err != nil {
-		return nil
-	}
 
-	r.data = append(r.data, urlPairs...)
+	for decoder.More() {
+		var record fileRecord
+		if err := decoder.Decode(&record); err != nil {
+			return err
+		}
+
+		if err := r.apply(record); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-// addRecord appends a JSON record to the file
-func (r *URLFileRepository) addRecord(file *os.File, itemJSON []byte, isFirst bool) error {
-	var err error
-	var firstAppendItem string
+// loadLog replays the log or migrates it from the legacy format and seeks to its end
+func (r *URLFileRepository) loadLog() error {
+	isLegacy, err := r.isLegacyFormat()
+	if err != nil {
+		return err
+	}
+
+	if isLegacy {
+		if err := r.migrateLegacy(); err != nil {
+			return fmt.Errorf("migrate legacy file: %w", err)
+		}
+	} else if err := r.replayLog(); err != nil {
+		return fmt.Errorf("replay log: %w", err)
+	}
 
-	if isFirst {
-		firstAppendItem = "[\n"
-	} else {
-		_, err = file.Seek(-1, io.SeekEnd)
+	_, err = r.log.Seek(0, io.SeekEnd)
+	return err
+}
+
+// isLegacyFormat reports whether the log holds a JSON array of URL pairs
+func (r *URLFileRepository) isLegacyFormat() (bool, error) {
+	reader := bufio.NewReader(io.NewSectionReader(r.log, 0, maxFileSize))
+
+	for {
+		b, err := reader.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
 		if err != nil {
-			return err
+			return false, err
 		}
 
-		firstAppendItem = ",\n"
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+}
+
+// migrateLegacy imports URL pairs from the legacy JSON array and compacts them into the snapshot
+// The legacy file is renamed to the backup path before the compaction
+// The legacy format did not enforce uniqueness: every row is kept so that its short URL
+// keeps resolving, only repeated short URLs are dropped as the legacy lookup returned the first one
+func (r *URLFileRepository) migrateLegacy() error {
+	var urlPairs []*model.URLPair
+
+	if err := json.NewDecoder(io.NewSectionReader(r.log, 0, maxFileSize)).Decode(&urlPairs); err != nil {
+		return err
+	}
+
+	skipped := r.memory.restore(urlPairs)
+
+	for _, urlPair := range skipped {
+		logger.Log.Warn("skipped legacy URL pair with a repeated short URL",
+			zap.String("path", r.filePath),
+			zap.String("short", urlPair.Short),
+			zap.String("long", urlPair.Long),
+		)
+	}
+
+	// The legacy file is kept as a backup, the log starts anew next to it
+	backupPath := r.filePath + legacyBackupSuffix
+
+	if err := os.Rename(r.filePath, backupPath); err != nil {
+		return err
 	}
 
-	_, err = file.Write([]byte(firstAppendItem))
+	log, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return err
 	}
-	_, err = file.Write(itemJSON)
+
+	if err := r.log.Close(); err != nil {
+		return errors.Join(err, log.Close())
+	}
+	r.log = log
+
+	logger.Log.Info("migrated legacy URL storage file",
+		zap.String("path", r.filePath),
+		zap.String("backup", backupPath),
+		zap.Int("count", len(urlPairs)-len(skipped)),
+		zap.Int("skipped", len(skipped)),
+	)
+
+	return r.compact()
+}
+
+// replayLog applies the log records to the in-memory index
+// A torn last record left by a crash is truncated, corruption elsewhere is an error
+func (r *URLFileRepository) replayLog() error {
+	reader := bufio.NewReader(io.NewSectionReader(r.log, 0, maxFileSize))
+
+	var offset int64
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return readErr
+		}
+
+		isLast := errors.Is(readErr, io.EOF)
+
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var record fileRecord
+			err := json.Unmarshal(trimmed, &record)
+
+			if err != nil && !isLast {
+				return fmt.Errorf("corrupted record at offset %d: %w", offset, err)
+			}
+
+			if err != nil || line[len(line)-1] != '\n' {
+				logger.Log.Warn("truncating torn last record of URL storage log",
+					zap.String("path", r.filePath),
+					zap.Int64("offset", offset),
+				)
+
+				return r.log.Truncate(offset)
+			}
+
+			if err := r.apply(record); err != nil {
+				return fmt.Errorf("apply record at offset %d: %w", offset, err)
+			}
+		}
+
+		offset += int64(len(line))
+
+		if isLast {
+			return nil
+		}
+	}
+}
+
+// compact writes the current state into the snapshot and truncates the log
+// The caller must hold the lock
+func (r *URLFileRepository) compact() error {
+	snapshotPath := r.filePath + snapshotSuffix
+	tmpPath := snapshotPath + ".tmp"
+
+	if err := r.writeSnapshot(tmpPath); err != nil {
+		return errors.Join(err, os.Remove(tmpPath))
+	}
+
+	if err := os.Rename(tmpPath, snapshotPath); err != nil {
+		return err
+	}
+
+	if err := syncDir(filepath.Dir(snapshotPath)); err != nil {
+		return err
+	}
+
+	if err := r.log.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := r.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r.dirty = false
+
+	return r.log.Sync()
+}
+
+// writeSnapshot writes all URL pairs into the file and flushes it to disk
+// URL pairs are sorted by short URL so that snapshots of the same state are equal
+func (r *URLFileRepository) writeSnapshot(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
-	_, err = file.Write([]byte("\n]"))
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	urlPairs := r.memory.all()
+	slices.SortFunc(urlPairs, func(a, b *model.URLPair) int {
+		return strings.Compare(a.Short, b.Short)
+	})
+
+	for _, urlPair := range urlPairs {
+		if err := encoder.Encode(fileRecord{Op: opCreate, Pairs: []*model.URLPair{urlPair}}); err != nil {
+			return errors.Join(err, file.Close())
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return errors.Join(err, file.Close())
+	}
+
+	return errors.Join(file.Sync(), file.Close())
+}
+
+// startBackground starts periodic flushing and compaction according to the options
+func (r *URLFileRepository) startBackground() {
+	if r.options.SyncPolicy == SyncInterval {
+		r.runPeriodically(r.options.SyncInterval, func() error {
+			if !r.dirty {
+				return nil
+			}
+
+			r.dirty = false
+			return r.log.Sync()
+		})
+	}
+
+	if r.options.CompactionInterval > 0 {
+		r.runPeriodically(r.options.CompactionInterval, r.compact)
+	}
+}
+
+// runPeriodically runs the task under the lock until the repository is closed
+func (r *URLFileRepository) runPeriodically(interval time.Duration, task func() error) {
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+
+			case <-ticker.C:
+				r.mu.Lock()
+				err := task()
+				r.mu.Unlock()
+
+				if err != nil {
+					logger.Log.Error("URL storage background task failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// syncDir flushes the directory entries so that a rename survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return errors.Join(d.Sync(), d.Close())
 }
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+)
+
+func TestFileRepositoryTornLastRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		tail string
+	}{
+		{
+			name: "Positive case: partially written record",
+			tail: `{"op":"create","pairs":[{"uid":"3","short":"torn"`,
+		},
+		{
+			name: "Positive case: record without line break",
+			tail: `{"op":"delete","user_id":"user-1","shorts":["first"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "url_pairs.jsonl")
+
+			repo := openFileRepository(t, path)
+			require.NoError(t, repo.Save(ctx, model.NewURLPair("first", "https://first.example", nil, "user-1", false)))
+			require.NoError(t, repo.Save(ctx, model.NewURLPair("second", "https://second.example", nil, "user-1", false)))
+			require.NoError(t, repo.Close())
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			appendToFile(t, path, tt.tail)
+
+			repo = openFileRepository(t, path)
+			want := collectURLPairs(t, repo)
+			assert.Len(t, want, 2)
+			assert.False(t, want["first"].IsDeleted)
+
+			truncated, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, info.Size(), truncated.Size())
+
+			require.NoError(t, repo.Save(ctx, model.NewURLPair("third", "https://third.example", nil, "user-1", false)))
+			assert.Equal(t, collectURLPairs(t, repo), reopenFileRepository(t, repo, path))
+		})
+	}
+}
+
+func TestFileRepositoryCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "url_pairs.jsonl")
+
+	repo := openFileRepository(t, path)
+
+	_, err := repo.SaveMany(ctx, []*model.URLPair{
+		model.NewURLPair("first", "https://first.example", nil, "user-1", false),
+		model.NewURLPair("second", "https://second.example", nil, "user-2", false),
+		model.NewURLPair("third", "https://third.example", nil, "user-1", false),
+	})
+	require.NoError(t, err)
+	_, err = repo.DeleteByShorts(ctx, "user-1", []string{"first"})
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateRedirect(ctx, "second", model.RedirectSettings{Type: 301, PassQuery: true}))
+
+	require.NoError(t, repo.Compact())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	_, err = repo.DeleteByShorts(ctx, "user-1", []string{"third"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(ctx, model.NewURLPair("fourth", "https://first.example", nil, "user-2", false)))
+
+	want := collectURLPairs(t, repo)
+	require.NoError(t, repo.Close())
+
+	repo = openFileRepository(t, path)
+	defer repo.Close()
+	assert.Equal(t, want, collectURLPairs(t, repo))
+
+	stored, created, err := repo.GetOrCreate(ctx, model.NewURLPair("fifth", "https://first.example", nil, "user-1", false))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "fourth", stored.Short)
+}
+
+func TestFileRepositoryMigrateLegacy(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "url_pairs.json")
+
+	// Rows written by the legacy repository that did not check for repeated URLs
+	legacy := `[
+		{"uid":"1","short":"first","long":"https://yandex.ru","user_id":"user-1","is_deleted":false},
+		{"uid":"2","short":"second","long":"https://yandex.ru","user_id":"user-2","is_deleted":false},
+		{"uid":"3","short":"first","long":"https://google.com","user_id":"user-2","is_deleted":false},
+		{"uid":"4","short":"third","long":"https://google.com","user_id":"user-1","is_deleted":true}
+	]`
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0644))
+
+	repo := openFileRepository(t, path)
+	want := collectURLPairs(t, repo)
+
+	backup, err := os.ReadFile(path + legacyBackupSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, legacy, string(backup))
+
+	snapshot, err := os.ReadFile(path + snapshotSuffix)
+	require.NoError(t, err)
+	var shorts []string
+	for _, line := range strings.Split(strings.TrimSpace(string(snapshot)), "\n") {
+		var record fileRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		require.Len(t, record.Pairs, 1)
+		shorts = append(shorts, record.Pairs[0].Short)
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, shorts)
+
+	require.Len(t, want, 3)
+	assert.Equal(t, "https://yandex.ru", want["first"].Long)
+	assert.Equal(t, "https://yandex.ru", want["second"].Long)
+	assert.True(t, want["third"].IsDeleted)
+
+	count, err := repo.CountURLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	stored, created, err := repo.GetOrCreate(ctx, model.NewURLPair("fourth", "https://yandex.ru", nil, "user-3", false))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "first", stored.Short)
+
+	_, created, err = repo.GetOrCreate(ctx, model.NewURLPair("fifth", "https://google.com", nil, "user-3", false))
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	assert.Equal(t, collectURLPairs(t, repo), reopenFileRepository(t, repo, path))
+}
+
+func TestFileRepositoryDeleteExpiredWithoutExpiredPairs(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "url_pairs.jsonl")
+
+	repo := openFileRepository(t, path)
+	defer repo.Close()
+
+	urlPair := model.NewURLPair("first", "https://first.example", nil, "user-1", false)
+	urlPair.ExpiresAt = pointerTo(time.Now().Add(time.Hour))
+	require.NoError(t, repo.Save(ctx, urlPair))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	affected, err := repo.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, affected)
+
+	unchanged, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), unchanged.Size())
+
+	affected, err = repo.DeleteExpired(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	grown, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Greater(t, grown.Size(), info.Size())
+}
+
+// openFileRepository opens the file repository at the path
+func openFileRepository(t *testing.T, path string) *URLFileRepository {
+	t.Helper()
+
+	repo, err := NewURLFileRepository(path, FileRepositoryOptions{})
+	require.NoError(t, err)
+
+	return repo
+}
+
+// reopenFileRepository closes the file repository and returns the state read back from its files
+func reopenFileRepository(t *testing.T, repo *URLFileRepository, path string) map[string]model.URLPair {
+	t.Helper()
+
+	require.NoError(t, repo.Close())
+
+	reopened := openFileRepository(t, path)
+	defer reopened.Close()
+
+	return collectURLPairs(t, reopened)
+}
+
+// appendToFile appends the data to the file
+func appendToFile(t *testing.T, path string, data string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.WriteString(data)
+	require.NoError(t, err)
+}
+
+// collectURLPairs returns copies of all stored URL pairs keyed by short URL
+func collectURLPairs(t *testing.T, repo URLRepository) map[string]model.URLPair {
+	t.Helper()
+
+	result := make(map[string]model.URLPair)
+	err := repo.ForEach(context.Background(), func(urlPair *model.URLPair) error {
+		result[urlPair.Short] = *urlPair
+		return nil
+	})
+	require.NoError(t, err)
+
+	return result
+}
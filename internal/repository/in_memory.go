@@ -3,78 +3,448 @@ package repository
 import (
 	"context"
 	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
 )
 
+// inMemoryShardCount is the number of shards of the short URL index
+const inMemoryShardCount = 64
+
+// urlShard holds a part of the short URL index guarded by its own lock
+type urlShard struct {
+	byShort map[string]*model.URLPair
+	mu      sync.RWMutex
+}
+
 // URLInMemoryRepository implements URLRepository using in-memory storage
+//
+// URL pairs are indexed by short URL in shards with separate locks so that
+// resolves of different short URLs do not contend with each other.
+// Stored URL pairs are never modified, updates replace them with changed copies,
+// so the pairs returned to callers may be read without holding any lock.
+// The long URL and user indexes are guarded by indexMu, the long URL index holds
+// only the URL pairs that own their long URL, see model.URLPair.OwnsLong.
+// Lock order is shard first, then indexMu.
 type URLInMemoryRepository struct {
-	data []*model.URLPair
-	mu   sync.RWMutex
+	shards   [inMemoryShardCount]*urlShard
+	byLong   map[string]*model.URLPair
+	byUserID map[string][]*model.URLPair
+	counters urlCounters
+	indexMu  sync.RWMutex
 }
 
 // NewURLInMemoryRepository creates a new URLInMemoryRepository instance
 func NewURLInMemoryRepository() *URLInMemoryRepository {
-	return &URLInMemoryRepository{data: make([]*model.URLPair, 0)}
+	repo := &URLInMemoryRepository{
+		byLong:   make(map[string]*model.URLPair),
+		byUserID: make(map[string][]*model.URLPair),
+		counters: newURLCounters(),
+	}
+
+	for i := range repo.shards {
+		repo.shards[i] = &urlShard{byShort: make(map[string]*model.URLPair)}
+	}
+
+	return repo
 }
 
 // Save stores a single URL pair
 func (r *URLInMemoryRepository) Save(_ context.Context, urlPair *model.URLPair) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.data = append(r.data, urlPair)
+	shard := r.shardFor(urlPair.Short)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, isFound := shard.byShort[urlPair.Short]; isFound {
+		return ErrorShortTaken
+	}
+
+	r.indexMu.Lock()
+	defer r.indexMu.Unlock()
+
+	if _, isFound := r.byLong[urlPair.Long]; isFound && urlPair.OwnsLong() {
+		return ErrorOnConflict
+	}
+
+	shard.byShort[urlPair.Short] = urlPair
+	r.index(urlPair)
+
 	return nil
 }
 
+// GetOrCreate stores the URL pair unless its long URL is already shortened
+// Returns the stored pair and whether it was created by this call
+func (r *URLInMemoryRepository) GetOrCreate(ctx context.Context, urlPair *model.URLPair) (*model.URLPair, bool, error) {
+	existing, err := r.SaveMany(ctx, []*model.URLPair{urlPair})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if stored, isFound := existing[urlPair.Long]; isFound {
+		return stored, false, nil
+	}
+
+	return urlPair, true, nil
+}
+
 // GetByShort retrieves a URL pair by its short URL
 func (r *URLInMemoryRepository) GetByShort(_ context.Context, short string) (*model.URLPair, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	shard := r.shardFor(short)
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	urlPair, isFound := shard.byShort[short]
+	return urlPair, isFound
+}
+
+// SaveMany stores multiple URL pairs
+// URL pairs whose long URL is already stored are skipped and the stored pairs are returned keyed by long URL
+func (r *URLInMemoryRepository) SaveMany(_ context.Context, urlPairs []*model.URLPair) (map[string]*model.URLPair, error) {
+	unlock := r.lockShards(urlPairs)
+	defer unlock()
+
+	r.indexMu.Lock()
+	defer r.indexMu.Unlock()
+
+	newPairs, existing, err := r.filterNewLocked(urlPairs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, urlPair := range newPairs {
+		r.shardFor(urlPair.Short).byShort[urlPair.Short] = urlPair
+		r.index(urlPair)
+	}
+
+	return existing, nil
+}
+
+// filterNew splits the URL pairs the same way SaveMany does without storing them
+func (r *URLInMemoryRepository) filterNew(urlPairs []*model.URLPair) ([]*model.URLPair, map[string]*model.URLPair, error) {
+	unlock := r.lockShards(urlPairs)
+	defer unlock()
+
+	r.indexMu.RLock()
+	defer r.indexMu.RUnlock()
 
-	for _, urlPair := range r.data {
-		if urlPair.Short == short {
-			return urlPair, true
+	return r.filterNewLocked(urlPairs)
+}
+
+// filterNewLocked returns the URL pairs that are not stored yet and the stored pairs of already known long URLs
+// Only URL pairs that own their long URL are matched against the stored ones, see model.URLPair.OwnsLong
+// Repeated long URLs within the batch are stored once
+// Returns ErrorShortTaken if any short URL is used by another long URL
+// The caller must hold indexMu and the locks of the shards of all URL pairs
+func (r *URLInMemoryRepository) filterNewLocked(urlPairs []*model.URLPair) ([]*model.URLPair, map[string]*model.URLPair, error) {
+	newPairs := make([]*model.URLPair, 0, len(urlPairs))
+	existing := make(map[string]*model.URLPair)
+	batchLongs := make(map[string]struct{}, len(urlPairs))
+
+	for _, urlPair := range urlPairs {
+		if stored, isFound := r.byLong[urlPair.Long]; isFound && urlPair.OwnsLong() {
+			existing[urlPair.Long] = stored
+			continue
+		}
+
+		if _, isFound := batchLongs[urlPair.Long]; isFound {
+			continue
+		}
+
+		if _, isFound := r.shardFor(urlPair.Short).byShort[urlPair.Short]; isFound {
+			return nil, nil, ErrorShortTaken
+		}
+
+		if _, isFound := findPairByShort(newPairs, urlPair.Short); isFound {
+			return nil, nil, ErrorShortTaken
 		}
+
+		batchLongs[urlPair.Long] = struct{}{}
+		newPairs = append(newPairs, urlPair)
 	}
 
-	return nil, false
+	return newPairs, existing, nil
 }
 
-// SaveMany stores multiple URL pairs
-func (r *URLInMemoryRepository) SaveMany(_ context.Context, urlPairs []*model.URLPair) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.data = append(r.data, urlPairs...)
-	return nil
+// restore stores URL pairs read back from storage without matching their long URLs
+// Every URL pair is kept as long as its short URL is free, the first one to own a long URL keeps owning it
+// Returns the URL pairs skipped because their short URL is already stored
+func (r *URLInMemoryRepository) restore(urlPairs []*model.URLPair) []*model.URLPair {
+	unlock := r.lockShards(urlPairs)
+	defer unlock()
+
+	r.indexMu.Lock()
+	defer r.indexMu.Unlock()
+
+	var skipped []*model.URLPair
+
+	for _, urlPair := range urlPairs {
+		shard := r.shardFor(urlPair.Short)
+		if _, isFound := shard.byShort[urlPair.Short]; isFound {
+			skipped = append(skipped, urlPair)
+			continue
+		}
+
+		shard.byShort[urlPair.Short] = urlPair
+		r.index(urlPair)
+	}
+
+	return skipped
 }
 
-// DeleteByShorts marks URL pairs as deleted for a user
-func (r *URLInMemoryRepository) DeleteByShorts(_ context.Context, userID string, shorts []string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// DeleteByShorts marks URL pairs as deleted for a user and returns the pairs it deleted
+func (r *URLInMemoryRepository) DeleteByShorts(_ context.Context, userID string, shorts []string) ([]*model.URLPair, error) {
+	var deleted []*model.URLPair
+
+	for _, short := range shorts {
+		shard := r.shardFor(short)
+
+		shard.mu.Lock()
+		if urlPair, isFound := shard.byShort[short]; isFound && !urlPair.IsDeleted && urlPair.UserID == userID {
+			updated := *urlPair
+			updated.IsDeleted = true
+
+			r.indexMu.Lock()
+			r.replace(shard, urlPair, &updated)
+			r.counters.markDeleted()
+			r.indexMu.Unlock()
 
-	for _, urlPair := range r.data {
-		if !urlPair.IsDeleted && urlPair.UserID == userID && slices.Contains(shorts, urlPair.Short) {
-			urlPair.IsDeleted = true
+			deleted = append(deleted, &updated)
 		}
+		shard.mu.Unlock()
 	}
 
+	return deleted, nil
+}
+
+// UpdateRedirect replaces the redirect settings of a URL pair
+func (r *URLInMemoryRepository) UpdateRedirect(_ context.Context, short string, settings model.RedirectSettings) error {
+	shard := r.shardFor(short)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	urlPair, isFound := shard.byShort[short]
+	if !isFound {
+		return nil
+	}
+
+	updated := *urlPair
+	updated.Redirect = settings
+
+	r.indexMu.Lock()
+	r.replace(shard, urlPair, &updated)
+	r.indexMu.Unlock()
+
 	return nil
 }
 
+// DeleteExpired marks URL pairs expired at the given time as deleted
+func (r *URLInMemoryRepository) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
+	var affected int64
+
+	for _, shard := range r.shards {
+		shard.mu.Lock()
+		r.indexMu.Lock()
+
+		for _, urlPair := range shard.byShort {
+			if !urlPair.IsDeleted && urlPair.IsExpired(now) {
+				updated := *urlPair
+				updated.IsDeleted = true
+
+				r.replace(shard, urlPair, &updated)
+				r.counters.markDeleted()
+				affected++
+			}
+		}
+
+		r.indexMu.Unlock()
+		shard.mu.Unlock()
+	}
+
+	return affected, nil
+}
+
 // GetAllByUserID returns all URL pairs for a user
 func (r *URLInMemoryRepository) GetAllByUserID(_ context.Context, userID string) ([]*model.URLPair, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.indexMu.RLock()
+	defer r.indexMu.RUnlock()
 
 	var result []*model.URLPair
 
-	for _, urlPair := range r.data {
-		if urlPair.UserID == userID {
+	for _, urlPair := range r.byUserID[userID] {
+		if !urlPair.IsDeleted {
 			result = append(result, urlPair)
 		}
 	}
 
 	return result, nil
 }
+
+// GetPageByUserID returns a page of a user's URL pairs ordered by creation time and short URL
+func (r *URLInMemoryRepository) GetPageByUserID(_ context.Context, query UserURLsQuery) ([]*model.URLPair, error) {
+	r.indexMu.RLock()
+	defer r.indexMu.RUnlock()
+
+	var matched []*model.URLPair
+
+	for _, urlPair := range r.byUserID[query.UserID] {
+		if urlPair.IsDeleted && !query.IncludeDeleted {
+			continue
+		}
+		if query.Search != "" && !strings.Contains(urlPair.Long, query.Search) {
+			continue
+		}
+		if query.After != nil && !isAfter(urlPair, *query.After, query.Descending) {
+			continue
+		}
+
+		matched = append(matched, urlPair)
+	}
+
+	slices.SortFunc(matched, func(a, b *model.URLPair) int {
+		c := comparePosition(a, PageCursor{CreatedAt: b.CreatedAt, Short: b.Short})
+		if query.Descending {
+			return -c
+		}
+		return c
+	})
+
+	if len(matched) > query.Limit {
+		matched = matched[:query.Limit]
+	}
+
+	return matched, nil
+}
+
+// isAfter reports whether the URL pair follows the cursor in the given order
+func isAfter(urlPair *model.URLPair, cursor PageCursor, descending bool) bool {
+	c := comparePosition(urlPair, cursor)
+	if descending {
+		return c < 0
+	}
+
+	return c > 0
+}
+
+// CountURLs returns the number of URL pairs that are not deleted
+func (r *URLInMemoryRepository) CountURLs(_ context.Context) (int64, error) {
+	r.indexMu.RLock()
+	defer r.indexMu.RUnlock()
+
+	return r.counters.activeURLs, nil
+}
+
+// CountUsers returns the number of distinct users that shortened URLs
+func (r *URLInMemoryRepository) CountUsers(_ context.Context) (int64, error) {
+	r.indexMu.RLock()
+	defer r.indexMu.RUnlock()
+
+	return int64(len(r.counters.users)), nil
+}
+
+// ForEach calls fn for every stored URL pair, including deleted ones, until fn returns an error
+func (r *URLInMemoryRepository) ForEach(ctx context.Context, fn func(urlPair *model.URLPair) error) error {
+	for _, urlPair := range r.all() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(urlPair); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// all returns every stored URL pair, keeping the per-user insertion order
+func (r *URLInMemoryRepository) all() []*model.URLPair {
+	r.indexMu.RLock()
+	defer r.indexMu.RUnlock()
+
+	result := make([]*model.URLPair, 0, r.counters.total)
+	for _, urlPairs := range r.byUserID {
+		result = append(result, urlPairs...)
+	}
+
+	return result
+}
+
+// index adds the URL pair to the long URL and user indexes
+// The caller must hold indexMu
+func (r *URLInMemoryRepository) index(urlPair *model.URLPair) {
+	if _, isFound := r.byLong[urlPair.Long]; !isFound && urlPair.OwnsLong() {
+		r.byLong[urlPair.Long] = urlPair
+	}
+
+	r.byUserID[urlPair.UserID] = append(r.byUserID[urlPair.UserID], urlPair)
+	r.counters.add(urlPair)
+}
+
+// replace swaps the stored URL pair for its updated copy in every index
+// A URL pair that no longer owns its long URL releases it, see model.URLPair.OwnsLong
+// The caller must hold indexMu and the lock of the shard of the URL pair
+func (r *URLInMemoryRepository) replace(shard *urlShard, stored *model.URLPair, updated *model.URLPair) {
+	shard.byShort[updated.Short] = updated
+
+	if r.byLong[stored.Long] == stored {
+		if updated.OwnsLong() {
+			r.byLong[updated.Long] = updated
+		} else {
+			delete(r.byLong, stored.Long)
+		}
+	}
+
+	userPairs := r.byUserID[stored.UserID]
+	if i := slices.Index(userPairs, stored); i >= 0 {
+		userPairs[i] = updated
+	}
+}
+
+// shardFor returns the shard responsible for the short URL
+func (r *URLInMemoryRepository) shardFor(short string) *urlShard {
+	return r.shards[shardIndex(short)]
+}
+
+// lockShards locks the shards responsible for the URL pairs in index order to avoid deadlocks
+// Returns a function releasing the locks
+func (r *URLInMemoryRepository) lockShards(urlPairs []*model.URLPair) func() {
+	indexes := make([]int, 0, len(urlPairs))
+	for _, urlPair := range urlPairs {
+		indexes = append(indexes, shardIndex(urlPair.Short))
+	}
+
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	for _, i := range indexes {
+		r.shards[i].mu.Lock()
+	}
+
+	return func() {
+		for _, i := range indexes {
+			r.shards[i].mu.Unlock()
+		}
+	}
+}
+
+// shardIndex returns the index of the shard responsible for the short URL
+// The short URL is hashed with inlined FNV-1a to keep resolves allocation free
+func shardIndex(short string) int {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	hash := uint32(offset32)
+	for i := 0; i < len(short); i++ {
+		hash ^= uint32(short[i])
+		hash *= prime32
+	}
+
+	return int(hash % inMemoryShardCount)
+}
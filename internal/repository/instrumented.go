@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/metrics"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
+)
+
+// InstrumentedURLRepository wraps URLRepository, records operation latencies and traces operations
+type InstrumentedURLRepository struct {
+	repo    URLRepository
+	backend string
+}
+
+// NewInstrumentedURLRepository creates a new InstrumentedURLRepository instance
+func NewInstrumentedURLRepository(repo URLRepository, backend string) *InstrumentedURLRepository {
+	return &InstrumentedURLRepository{
+		repo:    repo,
+		backend: backend,
+	}
+}
+
+// Save stores a single URL pair
+func (r *InstrumentedURLRepository) Save(ctx context.Context, urlPair *model.URLPair) (err error) {
+	ctx, done := observe(ctx, r.backend, "save")
+	defer func() { done(err) }()
+
+	return r.repo.Save(ctx, urlPair)
+}
+
+// GetOrCreate stores the URL pair unless its long URL is already shortened
+func (r *InstrumentedURLRepository) GetOrCreate(ctx context.Context, urlPair *model.URLPair) (_ *model.URLPair, _ bool, err error) {
+	ctx, done := observe(ctx, r.backend, "get_or_create")
+	defer func() { done(err) }()
+
+	return r.repo.GetOrCreate(ctx, urlPair)
+}
+
+// GetByShort retrieves a URL pair by its short URL
+func (r *InstrumentedURLRepository) GetByShort(ctx context.Context, short string) (*model.URLPair, bool) {
+	ctx, done := observe(ctx, r.backend, "get_by_short")
+	defer done(nil)
+
+	return r.repo.GetByShort(ctx, short)
+}
+
+// SaveMany stores multiple URL pairs
+func (r *InstrumentedURLRepository) SaveMany(ctx context.Context, urlPairs []*model.URLPair) (_ map[string]*model.URLPair, err error) {
+	ctx, done := observe(ctx, r.backend, "save_many")
+	defer func() { done(err) }()
+
+	return r.repo.SaveMany(ctx, urlPairs)
+}
+
+// GetAllByUserID returns all URL pairs for a user
+func (r *InstrumentedURLRepository) GetAllByUserID(ctx context.Context, userID string) (_ []*model.URLPair, err error) {
+	ctx, done := observe(ctx, r.backend, "get_all_by_user_id")
+	defer func() { done(err) }()
+
+	return r.repo.GetAllByUserID(ctx, userID)
+}
+
+// GetPageByUserID returns a page of a user's URL pairs ordered by creation time and short URL
+func (r *InstrumentedURLRepository) GetPageByUserID(ctx context.Context, query UserURLsQuery) (_ []*model.URLPair, err error) {
+	ctx, done := observe(ctx, r.backend, "get_page_by_user_id")
+	defer func() { done(err) }()
+
+	return r.repo.GetPageByUserID(ctx, query)
+}
+
+// DeleteByShorts marks URL pairs as deleted for a user and returns the pairs it deleted
+func (r *InstrumentedURLRepository) DeleteByShorts(ctx context.Context, userID string, shorts []string) (_ []*model.URLPair, err error) {
+	ctx, done := observe(ctx, r.backend, "delete_by_shorts")
+	defer func() { done(err) }()
+
+	return r.repo.DeleteByShorts(ctx, userID, shorts)
+}
+
+// UpdateRedirect replaces the redirect settings of a URL pair
+func (r *InstrumentedURLRepository) UpdateRedirect(ctx context.Context, short string, settings model.RedirectSettings) (err error) {
+	ctx, done := observe(ctx, r.backend, "update_redirect")
+	defer func() { done(err) }()
+
+	return r.repo.UpdateRedirect(ctx, short, settings)
+}
+
+// DeleteExpired marks URL pairs expired at the given time as deleted
+func (r *InstrumentedURLRepository) DeleteExpired(ctx context.Context, now time.Time) (_ int64, err error) {
+	ctx, done := observe(ctx, r.backend, "delete_expired")
+	defer func() { done(err) }()
+
+	return r.repo.DeleteExpired(ctx, now)
+}
+
+// CountURLs returns the number of URL pairs that are not deleted
+func (r *InstrumentedURLRepository) CountURLs(ctx context.Context) (_ int64, err error) {
+	ctx, done := observe(ctx, r.backend, "count_urls")
+	defer func() { done(err) }()
+
+	return r.repo.CountURLs(ctx)
+}
+
+// CountUsers returns the number of distinct users that shortened URLs
+func (r *InstrumentedURLRepository) CountUsers(ctx context.Context) (_ int64, err error) {
+	ctx, done := observe(ctx, r.backend, "count_users")
+	defer func() { done(err) }()
+
+	return r.repo.CountUsers(ctx)
+}
+
+// ForEach calls fn for every stored URL pair, including deleted ones, until fn returns an error
+func (r *InstrumentedURLRepository) ForEach(ctx context.Context, fn func(urlPair *model.URLPair) error) (err error) {
+	ctx, done := observe(ctx, r.backend, "for_each")
+	defer func() { done(err) }()
+
+	return r.repo.ForEach(ctx, fn)
+}
+
+// InstrumentedClickRepository wraps ClickRepository, records operation latencies and traces operations
+type InstrumentedClickRepository struct {
+	repo    ClickRepository
+	backend string
+}
+
+// NewInstrumentedClickRepository creates a new InstrumentedClickRepository instance
+func NewInstrumentedClickRepository(repo ClickRepository, backend string) *InstrumentedClickRepository {
+	return &InstrumentedClickRepository{
+		repo:    repo,
+		backend: backend,
+	}
+}
+
+// SaveClicks stores multiple clicks and updates their aggregates
+func (r *InstrumentedClickRepository) SaveClicks(ctx context.Context, clicks []*model.Click) (err error) {
+	ctx, done := observe(ctx, r.backend, "save_clicks")
+	defer func() { done(err) }()
+
+	return r.repo.SaveClicks(ctx, clicks)
+}
+
+// GetStats returns aggregated click statistics for a short URL
+func (r *InstrumentedClickRepository) GetStats(ctx context.Context, short string) (_ *model.URLStats, err error) {
+	ctx, done := observe(ctx, r.backend, "get_stats")
+	defer func() { done(err) }()
+
+	return r.repo.GetStats(ctx, short)
+}
+
+// observe starts a span and a latency measurement of the repository operation
+// The returned function records the outcome and finishes both, expected conflicts are not treated as failures
+func observe(ctx context.Context, backend string, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "repository."+operation, attribute.String("repository.backend", backend))
+
+	return ctx, func(err error) {
+		metrics.ObserveRepositoryOperation(backend, operation, start)
+
+		if errors.Is(err, ErrorShortTaken) || errors.Is(err, ErrorOnConflict) {
+			err = nil
+		}
+
+		tracing.End(span, err)
+	}
+}
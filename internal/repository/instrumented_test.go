@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/metrics"
+)
+
+// errStorageUnavailable is returned by the saves of failingSaveRepository
+var errStorageUnavailable = errors.New("storage is unavailable")
+
+// failingSaveRepository is an in-memory repository whose saves fail with the given error
+type failingSaveRepository struct {
+	URLRepository
+	err error
+}
+
+func (r failingSaveRepository) Save(context.Context, *model.URLPair) error {
+	return r.err
+}
+
+func TestInstrumentedURLRepositorySave(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	tests := []struct {
+		name       string
+		backend    string
+		err        error
+		wantStatus codes.Code
+	}{
+		{
+			name:       "Positive case: failing call is observed and its span is failed",
+			backend:    "failing",
+			err:        errStorageUnavailable,
+			wantStatus: codes.Error,
+		},
+		{
+			name:       "Positive case: taken short URL is not a failure",
+			backend:    "taken",
+			err:        ErrorShortTaken,
+			wantStatus: codes.Unset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewInstrumentedURLRepository(failingSaveRepository{URLRepository: NewURLInMemoryRepository(), err: tt.err}, tt.backend)
+
+			err := repo.Save(context.Background(), model.NewURLPair("abc", "https://yandex.ru", nil, "user-1", false))
+			require.ErrorIs(t, err, tt.err)
+
+			assert.Equal(t, uint64(1), operationCount(t, tt.backend, "save"))
+
+			spans := recorder.Ended()
+			require.NotEmpty(t, spans)
+			span := spans[len(spans)-1]
+			assert.Equal(t, "repository.save", span.Name())
+			assert.Equal(t, tt.wantStatus, span.Status().Code)
+		})
+	}
+}
+
+// operationCount returns the number of observed repository operations of the backend
+func operationCount(t *testing.T, backend string, operation string) uint64 {
+	t.Helper()
+
+	histogram, err := metrics.RepositoryOperationDuration.GetMetricWithLabelValues(backend, operation)
+	require.NoError(t, err)
+
+	var metric dto.Metric
+	require.NoError(t, histogram.(prometheus.Metric).Write(&metric))
+
+	return metric.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+)
+
+// testDatabaseDSNEnv names the environment variable with the DSN of a disposable PostgreSQL database
+// Database repository tests are skipped when it is not set
+const testDatabaseDSNEnv = "TEST_DATABASE_DSN"
+
+// testRepositories returns constructors of every URL repository implementation keyed by name
+func testRepositories() map[string]func(t *testing.T) URLRepository {
+	return map[string]func(t *testing.T) URLRepository{
+		"memory": func(t *testing.T) URLRepository {
+			return NewURLInMemoryRepository()
+		},
+		"file": func(t *testing.T) URLRepository {
+			repo, err := NewURLFileRepository(t.TempDir()+"/url_pairs.jsonl", FileRepositoryOptions{})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = repo.Close() })
+			return repo
+		},
+		"database": func(t *testing.T) URLRepository {
+			return NewURLDatabaseRepository(openTestDatabase(t))
+		},
+	}
+}
+
+// openTestDatabase opens the test database with the migrations applied and url_pairs emptied
+func openTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	require.NoError(t, ApplyMigrations(db, "../../migrations"))
+
+	_, err = db.Exec("TRUNCATE url_pairs CASCADE")
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestDeleteExpired(t *testing.T) {
+	now := time.Now().Truncate(time.Microsecond)
+
+	expiresAt := map[string]*time.Time{
+		"expired": pointerTo(now.Add(-time.Minute)),
+		"expnow":  pointerTo(now),
+		"future":  pointerTo(now.Add(time.Minute)),
+		"forever": nil,
+		"deleted": pointerTo(now.Add(-time.Hour)),
+	}
+
+	for name, newRepo := range testRepositories() {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+
+			for short, at := range expiresAt {
+				urlPair := model.NewURLPair(short, "https://"+short+".example", nil, "user-1", short == "deleted")
+				urlPair.ExpiresAt = at
+				require.NoError(t, repo.Save(ctx, urlPair))
+			}
+
+			affected, err := repo.DeleteExpired(ctx, now)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), affected)
+
+			for short, isDeleted := range map[string]bool{
+				"expired": true,
+				"expnow":  true,
+				"future":  false,
+				"forever": false,
+				"deleted": true,
+			} {
+				urlPair, isFound := repo.GetByShort(ctx, short)
+				require.True(t, isFound, short)
+				assert.Equal(t, isDeleted, urlPair.IsDeleted, short)
+			}
+
+			count, err := repo.CountURLs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), count)
+
+			affected, err = repo.DeleteExpired(ctx, now)
+			require.NoError(t, err)
+			assert.Zero(t, affected)
+		})
+	}
+}
+
+func TestDefaultRedirectType(t *testing.T) {
+	for name, newRepo := range testRepositories() {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+
+			require.NoError(t, repo.Save(ctx, model.NewURLPair("abcdefg", "https://yandex.ru", nil, "user-1", false)))
+			require.NoError(t, repo.Save(ctx, model.NewURLPair("bcdefgh", "https://ya.ru", nil, "user-1", false)))
+			require.NoError(t, repo.UpdateRedirect(ctx, "bcdefgh", model.RedirectSettings{PassQuery: true}))
+
+			urlPair, isFound := repo.GetByShort(ctx, "abcdefg")
+			require.True(t, isFound)
+			assert.Zero(t, urlPair.Redirect.Type)
+
+			urlPair, isFound = repo.GetByShort(ctx, "bcdefgh")
+			require.True(t, isFound)
+			assert.Equal(t, model.RedirectSettings{PassQuery: true}, urlPair.Redirect)
+			assert.Equal(t, http.StatusTemporaryRedirect, urlPair.Redirect.StatusCode())
+		})
+	}
+}
+
+func pointerTo[T any](v T) *T { return &v }
+
+func TestUpdateRedirectConcurrently(t *testing.T) {
+	const updates = 200
+
+	for name, newRepo := range testRepositories() {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+
+			require.NoError(t, repo.Save(ctx, model.NewURLPair("abcdefg", "https://yandex.ru", nil, "user-1", false)))
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+
+			go func() {
+				defer wg.Done()
+				for i := 0; i < updates; i++ {
+					// Permanent redirects pass the query, so a torn read shows up as a mismatch
+					settings := model.RedirectSettings{Type: http.StatusFound}
+					if i%2 == 0 {
+						settings = model.RedirectSettings{Type: http.StatusMovedPermanently, PassQuery: true}
+					}
+					assert.NoError(t, repo.UpdateRedirect(ctx, "abcdefg", settings))
+				}
+			}()
+
+			go func() {
+				defer wg.Done()
+				for i := 0; i < updates; i++ {
+					urlPair, isFound := repo.GetByShort(ctx, "abcdefg")
+					if assert.True(t, isFound) {
+						redirect := urlPair.Redirect
+						if redirect.Type != 0 {
+							assert.Equal(t, redirect.Type == http.StatusMovedPermanently, redirect.PassQuery)
+						}
+					}
+				}
+			}()
+
+			wg.Wait()
+
+			urlPair, isFound := repo.GetByShort(ctx, "abcdefg")
+			require.True(t, isFound)
+			assert.Equal(t, model.RedirectSettings{Type: http.StatusFound}, urlPair.Redirect)
+
+			urlPairs, err := repo.GetAllByUserID(ctx, "user-1")
+			require.NoError(t, err)
+			require.Len(t, urlPairs, 1)
+			assert.Equal(t, urlPair.Redirect, urlPairs[0].Redirect)
+		})
+	}
+}
+
+func TestGetOrCreateConcurrently(t *testing.T) {
+	const callers = 20
+
+	for name, newRepo := range testRepositories() {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+
+			results := make([]*model.URLPair, callers)
+			created := make([]bool, callers)
+
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					<-start
+
+					urlPair := model.NewURLPair(fmt.Sprintf("short%d", i), "https://yandex.ru", nil, "user-1", false)
+
+					var err error
+					results[i], created[i], err = repo.GetOrCreate(ctx, urlPair)
+					assert.NoError(t, err)
+				}(i)
+			}
+			close(start)
+			wg.Wait()
+
+			createdCount := 0
+			for i := 0; i < callers; i++ {
+				if created[i] {
+					createdCount++
+				}
+				if assert.NotNil(t, results[i]) {
+					assert.Equal(t, results[0].Short, results[i].Short)
+				}
+			}
+			assert.Equal(t, 1, createdCount)
+
+			count, err := repo.CountURLs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), count)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ShortCodeSequenceName is the PostgreSQL sequence used by counter-based short code generators
+const ShortCodeSequenceName = "short_code_seq"
+
+// SequenceDatabaseRepository provides numbers of a PostgreSQL sequence
+type SequenceDatabaseRepository struct {
+	db   *sql.DB
+	name string
+}
+
+// NewSequenceDatabaseRepository creates a new SequenceDatabaseRepository instance
+func NewSequenceDatabaseRepository(db *sql.DB, name string) *SequenceDatabaseRepository {
+	return &SequenceDatabaseRepository{
+		db:   db,
+		name: name,
+	}
+}
+
+// Next returns the next number of the sequence
+func (r *SequenceDatabaseRepository) Next(ctx context.Context) (uint64, error) {
+	var next int64
+
+	err := r.db.QueryRowContext(ctx, "SELECT nextval($1::regclass)", r.name).Scan(&next)
+
+	return uint64(next), err
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+)
+
+// errInvalidCursor is returned when a page cursor cannot be decoded
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor encodes the position of the URL pair as an opaque page cursor
+func encodeCursor(urlPair *model.URLPair) string {
+	data, _ := json.Marshal(repository.PageCursor{CreatedAt: urlPair.CreatedAt, Short: urlPair.Short})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a page cursor produced by encodeCursor
+func decodeCursor(cursor string) (*repository.PageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var position repository.PageCursor
+	if err := json.Unmarshal(data, &position); err != nil || position.Short == "" {
+		return nil, errInvalidCursor
+	}
+
+	return &position, nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/qrcode"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
+)
+
+const (
+	// defaultQRCodeSize is the image size in pixels used when the size is not requested
+	defaultQRCodeSize = 256
+	minQRCodeSize     = 64
+	maxQRCodeSize     = 1024
+
+	defaultQRCodeFormat          = qrcode.FormatPNG
+	defaultQRCodeErrorCorrection = "M"
+)
+
+// QRService renders QR codes of short URLs
+type QRService struct {
+	repo     repository.URLRepository
+	baseURL  string
+	timeouts Timeouts
+}
+
+// NewQRService creates a new QRService instance
+func NewQRService(repo repository.URLRepository, baseURL string, timeouts Timeouts) *QRService {
+	return &QRService{
+		repo:     repo,
+		baseURL:  baseURL,
+		timeouts: timeouts,
+	}
+}
+
+// GetQRCode renders a QR code pointing at the short URL
+// Returns 404 and 410 the same way as URL resolving does
+func (s *QRService) GetQRCode(ctx context.Context, short string, req model.QRCodeRequest) (_ *model.QRCode, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "QRService.GetQRCode")
+	defer func() { endSpan(span, appErr) }()
+
+	options, err := s.qrCodeOptions(req)
+	if err != nil {
+		return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidQRCodeOptions, "Invalid QR code parameters were provided", err)
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.Resolve)
+	defer cancel()
+
+	urlPair, appErr := findActiveURLPair(ctx, s.repo, short, time.Now())
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	image, err := qrcode.Render(fmt.Sprintf("%s/%s", s.baseURL, urlPair.Short), options)
+	if errors.Is(err, qrcode.ErrSizeTooSmall) {
+		return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidQRCodeOptions, "QR code size is too small", err)
+	}
+	if err != nil {
+		return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to render QR code", err)
+	}
+
+	return &model.QRCode{
+		Image:       image,
+		ContentType: options.Format.ContentType(),
+	}, nil
+}
+
+// qrCodeOptions validates the request and fills in the defaults
+func (s *QRService) qrCodeOptions(req model.QRCodeRequest) (qrcode.Options, error) {
+	options := qrcode.Options{
+		Format: defaultQRCodeFormat,
+		Size:   defaultQRCodeSize,
+	}
+
+	if req.Size != 0 {
+		if req.Size < minQRCodeSize || req.Size > maxQRCodeSize {
+			return options, fmt.Errorf("size must be between %d and %d", minQRCodeSize, maxQRCodeSize)
+		}
+		options.Size = req.Size
+	}
+
+	if req.Format != "" {
+		format, err := qrcode.ParseFormat(req.Format)
+		if err != nil {
+			return options, err
+		}
+		options.Format = format
+	}
+
+	errorCorrection := req.ErrorCorrection
+	if errorCorrection == "" {
+		errorCorrection = defaultQRCodeErrorCorrection
+	}
+
+	level, err := qrcode.ParseLevel(errorCorrection)
+	if err != nil {
+		return options, err
+	}
+	options.Level = level
+
+	return options, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+)
+
+// maxUTMValueLength limits the length of a single UTM parameter value
+const maxUTMValueLength = 256
+
+// validateRedirectSettings checks the requested redirect settings and fills in the defaults
+func validateRedirectSettings(settings *model.RedirectSettings) (model.RedirectSettings, error) {
+	if settings == nil {
+		return model.RedirectSettings{Type: http.StatusTemporaryRedirect}, nil
+	}
+
+	result := *settings
+
+	switch result.Type {
+	case 0:
+		result.Type = http.StatusTemporaryRedirect
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return model.RedirectSettings{}, fmt.Errorf("redirect type %d is not one of 301, 302, 307 or 308", result.Type)
+	}
+
+	if result.UTM != nil {
+		values := result.UTM.Values()
+		if len(values) == 0 {
+			result.UTM = nil
+		}
+
+		for name := range values {
+			if len(values.Get(name)) > maxUTMValueLength {
+				return model.RedirectSettings{}, fmt.Errorf("%s is longer than %d bytes", name, maxUTMValueLength)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// buildRedirect applies the redirect settings of the URL pair to the request of its short URL
+// The request query is appended to the destination query when passthrough is enabled,
+// configured UTM parameters replace the ones of the destination and the request
+func buildRedirect(urlPair *model.URLPair, rawQuery string) (*model.Redirect, error) {
+	redirect := &model.Redirect{Location: urlPair.Long, StatusCode: urlPair.Redirect.StatusCode()}
+
+	passQuery := urlPair.Redirect.PassQuery && rawQuery != ""
+	if !passQuery && urlPair.Redirect.UTM == nil {
+		return redirect, nil
+	}
+
+	destination, err := url.Parse(urlPair.Long)
+	if err != nil {
+		return nil, err
+	}
+
+	query := destination.Query()
+
+	if passQuery {
+		// Malformed pairs of the request query are dropped
+		requestQuery, _ := url.ParseQuery(rawQuery)
+
+		for name, values := range requestQuery {
+			for _, value := range values {
+				query.Add(name, value)
+			}
+		}
+	}
+
+	for name, values := range urlPair.Redirect.UTM.Values() {
+		query[name] = values
+	}
+
+	destination.RawQuery = query.Encode()
+	redirect.Location = destination.String()
+
+	return redirect, nil
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
+)
+
+// endSpan ends the span of a service operation and marks it failed on server errors
+// Client errors are expected outcomes and leave the span status unset
+func endSpan(span trace.Span, appErr *appError.HTTPError) {
+	if appErr == nil || appErr.Code < http.StatusInternalServerError {
+		span.End()
+		return
+	}
+
+	err := appErr.Error
+	if err == nil {
+		err = errors.New(appErr.Message)
+	}
+
+	tracing.End(span, err)
+}
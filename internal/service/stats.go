@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
+)
+
+const (
+	// ipv4BucketBits is the prefix length used to anonymize IPv4 addresses
+	ipv4BucketBits = 24
+	// ipv6BucketBits is the prefix length used to anonymize IPv6 addresses
+	ipv6BucketBits = 48
+)
+
+// StatsService provides click statistics of short URLs
+type StatsService struct {
+	urlRepo   repository.URLRepository
+	clickRepo repository.ClickRepository
+	baseURL   string
+	timeouts  Timeouts
+}
+
+// NewStatsService creates a new StatsService instance
+func NewStatsService(
+	urlRepo repository.URLRepository,
+	clickRepo repository.ClickRepository,
+	baseURL string,
+	timeouts Timeouts,
+) *StatsService {
+	return &StatsService{
+		urlRepo:   urlRepo,
+		clickRepo: clickRepo,
+		baseURL:   baseURL,
+		timeouts:  timeouts,
+	}
+}
+
+// GetURLStats returns click statistics of a short URL owned by the user
+func (s *StatsService) GetURLStats(ctx context.Context, short string, userID string) (_ *model.URLStats, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "StatsService.GetURLStats")
+	defer func() { endSpan(span, appErr) }()
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.Stats)
+	defer cancel()
+
+	urlPair, isFound := s.urlRepo.GetByShort(ctx, short)
+	if !isFound {
+		return nil, appError.NewHTTPError(
+			http.StatusNotFound,
+			appError.CodeURLNotFound,
+			"Could not find provided URL",
+			errors.New("url not found"),
+		)
+	}
+
+	if urlPair.UserID != userID {
+		return nil, appError.NewHTTPError(
+			http.StatusForbidden,
+			appError.CodeForbidden,
+			"Access to URL statistics is denied",
+			errors.New("url belongs to another user"),
+		)
+	}
+
+	stats, err := s.clickRepo.GetStats(ctx, short)
+	if err != nil {
+		return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to get URL statistics", err)
+	}
+
+	stats.ShortURL = fmt.Sprintf("%s/%s", s.baseURL, short)
+
+	return stats, nil
+}
+
+// GetServiceStats returns the number of shortened URLs and users of the service
+func (s *StatsService) GetServiceStats(ctx context.Context) (_ *model.ServiceStats, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "StatsService.GetServiceStats")
+	defer func() { endSpan(span, appErr) }()
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.Stats)
+	defer cancel()
+
+	urls, err := s.urlRepo.CountURLs(ctx)
+	if err != nil {
+		return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to count URLs", err)
+	}
+
+	users, err := s.urlRepo.CountUsers(ctx)
+	if err != nil {
+		return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to count users", err)
+	}
+
+	return &model.ServiceStats{URLs: urls, Users: users}, nil
+}
+
+// newClick builds an anonymized click record from the visit
+func newClick(short string, visit model.Visit, now time.Time) *model.Click {
+	bucket := ipBucket(visit.ClientIP)
+
+	visitorHash := sha256.Sum256([]byte(bucket + "|" + visit.UserAgent))
+
+	return &model.Click{
+		Short:     short,
+		ClickedAt: now.UTC(),
+		Referrer:  visit.Referrer,
+		UserAgent: visit.UserAgent,
+		IPBucket:  bucket,
+		VisitorID: hex.EncodeToString(visitorHash[:16]),
+	}
+}
+
+// ipBucket reduces a client IP to its network prefix
+func ipBucket(clientIP string) string {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return ""
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		mask := net.CIDRMask(ipv4BucketBits, 32)
+		return fmt.Sprintf("%s/%d", ipv4.Mask(mask), ipv4BucketBits)
+	}
+
+	mask := net.CIDRMask(ipv6BucketBits, 128)
+	return fmt.Sprintf("%s/%d", ip.Mask(mask), ipv6BucketBits)
+}
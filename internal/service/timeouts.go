@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Default timeouts of service operations, the configuration uses the same values
+const (
+	DefaultShortenTimeout = 2 * time.Second
+	DefaultResolveTimeout = 500 * time.Millisecond
+	DefaultListTimeout    = time.Second
+	DefaultStatsTimeout   = time.Second
+)
+
+// Timeouts limits the duration of service operations
+// A non-positive timeout leaves the operation bound only by the caller context
+type Timeouts struct {
+	// Shorten limits shortening of single URLs and batches
+	Shorten time.Duration
+	// Resolve limits resolving of short URLs and rendering of QR codes
+	Resolve time.Duration
+	// List limits listing of user URLs
+	List time.Duration
+	// Stats limits reading of statistics
+	Stats time.Duration
+}
+
+// DefaultTimeouts returns the timeouts used when none are configured
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Shorten: DefaultShortenTimeout,
+		Resolve: DefaultResolveTimeout,
+		List:    DefaultListTimeout,
+		Stats:   DefaultStatsTimeout,
+	}
+}
+
+// withTimeout derives a context bounded by the timeout from the caller context
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
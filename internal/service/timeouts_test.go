@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
+)
+
+// contextRecordingRepository is an in-memory repository recording the context state of the last page read
+type contextRecordingRepository struct {
+	repository.URLRepository
+	called      bool
+	err         error
+	deadline    time.Time
+	hasDeadline bool
+}
+
+func (r *contextRecordingRepository) GetPageByUserID(ctx context.Context, query repository.UserURLsQuery) ([]*model.URLPair, error) {
+	r.called = true
+	r.err = ctx.Err()
+	r.deadline, r.hasDeadline = ctx.Deadline()
+
+	return r.URLRepository.GetPageByUserID(ctx, query)
+}
+
+func TestListUserURLsContext(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeouts     Timeouts
+		cancel       bool
+		wantErr      error
+		wantDeadline bool
+	}{
+		{
+			name:         "Positive case: listing is bounded by the list timeout",
+			timeouts:     DefaultTimeouts(),
+			wantDeadline: true,
+		},
+		{
+			name:     "Positive case: zero timeout leaves the listing bound only by the caller",
+			timeouts: Timeouts{},
+		},
+		{
+			name:         "Negative case: cancellation of the caller reaches the repository",
+			timeouts:     DefaultTimeouts(),
+			cancel:       true,
+			wantErr:      context.Canceled,
+			wantDeadline: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &contextRecordingRepository{URLRepository: repository.NewURLInMemoryRepository()}
+			deleteURLWorker := worker.NewDeleteURLWorker(repo, audit.NewNoop(), 10)
+			clickWorker := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 10)
+			svc := NewURLService(repo, "http://localhost:8080", newHashGenerator(t), newDestinationPolicy(), deleteURLWorker, clickWorker, audit.NewNoop(), tt.timeouts)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			start := time.Now()
+			_, appErr := svc.ListUserURLs(ctx, "user-1", model.UserURLsQuery{})
+			require.Nil(t, appErr)
+			require.True(t, repo.called)
+
+			assert.Equal(t, tt.wantErr, repo.err)
+			assert.Equal(t, tt.wantDeadline, repo.hasDeadline)
+			if repo.hasDeadline {
+				assert.WithinDuration(t, start.Add(tt.timeouts.List), repo.deadline, time.Second)
+			}
+		})
+	}
+}
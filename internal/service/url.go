@@ -2,159 +2,336 @@ package service
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/sha1"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/generator"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/policy"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
 	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
+)
+
+const (
+	minAliasLength = 3
+	maxAliasLength = 32
+
+	// maxTTLSeconds limits link lifetime to ten years
+	maxTTLSeconds = 10 * 365 * 24 * 60 * 60
+
+	// maxGenerateAttempts limits short code generation retries on collisions
+	maxGenerateAttempts = 10
+
+	// DefaultPageLimit is the page size of user URL listings continued with a cursor without an explicit limit
+	DefaultPageLimit = 100
+	// MaxPageLimit is the largest page size of user URL listings
+	MaxPageLimit = 1000
 )
 
+// Sort orders of user URL listings
+const (
+	SortCreatedAtAsc  = "created_at"
+	SortCreatedAtDesc = "-created_at"
+)
+
+// reservedAliases lists short codes that clash with application routes
+var reservedAliases = []string{"api", "ping", "debug", "metrics"}
+
 // URLService provides URL shortening business logic
 type URLService struct {
 	repo            repository.URLRepository
 	baseURL         string
+	generator       generator.ShortCodeGenerator
+	policy          *policy.Policy
 	deleteURLWorker *worker.DeleteURLWorker
+	clickWorker     *worker.ClickWorker
 	audit           audit.Publisher
+	timeouts        Timeouts
 }
 
 // NewURLService creates a new URLService instance
 func NewURLService(
 	repo repository.URLRepository,
 	baseURL string,
+	shortCodeGenerator generator.ShortCodeGenerator,
+	destinationPolicy *policy.Policy,
 	deleteURLWorker *worker.DeleteURLWorker,
+	clickWorker *worker.ClickWorker,
 	auditPublisher audit.Publisher,
+	timeouts Timeouts,
 ) *URLService {
 	return &URLService{
 		repo:            repo,
 		baseURL:         baseURL,
+		generator:       shortCodeGenerator,
+		policy:          destinationPolicy,
 		deleteURLWorker: deleteURLWorker,
+		clickWorker:     clickWorker,
 		audit:           auditPublisher,
+		timeouts:        timeouts,
 	}
 }
 
 // ShortenURL validates and shortens a URL
-func (s *URLService) ShortenURL(url string, userID string) (string, *appError.HTTPError) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2000*time.Millisecond)
+// If the request carries an alias, it is used as the short code instead of a generated one
+func (s *URLService) ShortenURL(ctx context.Context, req model.Request, userID string) (_ string, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "URLService.ShortenURL")
+	defer func() { endSpan(span, appErr) }()
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.Shorten)
 	defer cancel()
 
-	validatedURL, err := s.validateURL(url)
+	validatedURL, err := s.validateURL(req.URL)
+	if err != nil {
+		return "", appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidURL, "Invalid URL was provided", err)
+	}
+
+	if appErr := s.checkDestination(ctx, validatedURL, userID); appErr != nil {
+		return "", appErr
+	}
+
+	expiresAt, err := s.resolveExpiresAt(req.ExpiresAt, req.TTLSeconds)
 	if err != nil {
-		return "", appError.NewHTTPError(http.StatusBadRequest, "Invalid URL was provided", err)
+		return "", appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidExpiration, "Invalid expiration was provided", err)
 	}
 
-	urlPath, err := s.generateShortURLPath(ctx, validatedURL)
+	redirect, err := validateRedirectSettings(req.Redirect)
 	if err != nil {
-		return "", appError.NewHTTPError(http.StatusInternalServerError, "Failed to generate short URL", err)
+		return "", appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRedirect, "Invalid redirect settings were provided", err)
 	}
 
-	_, isFound := s.repo.GetByShort(ctx, urlPath)
-	if !isFound {
-		err = s.repo.Save(ctx, model.NewURLPair(urlPath, validatedURL, nil, userID, false))
-		if err != nil && !errors.Is(err, repository.ErrorOnConflict) {
-			return "", appError.NewHTTPError(http.StatusInternalServerError, "Failed to save URL", err)
+	if req.Alias != "" {
+		return s.shortenURLWithAlias(ctx, validatedURL, req.Alias, expiresAt, redirect, userID)
+	}
+
+	urlPair, created, err := s.getOrCreateURLPair(ctx, validatedURL, expiresAt, redirect, userID)
+	if err != nil {
+		return "", appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to save URL", err)
+	}
+
+	shortURL := fmt.Sprintf("%s/%s", s.baseURL, urlPair.Short)
+
+	if !created {
+		s.notifyAudit(ctx, audit.ActionShorten, audit.OutcomeConflict, userID, urlPair)
+		return shortURL, appError.NewHTTPError(http.StatusConflict, appError.CodeURLConflict, "URL has already been shortened", nil)
+	}
+
+	s.notifyAudit(ctx, audit.ActionShorten, audit.OutcomeSuccess, userID, urlPair)
+
+	return shortURL, nil
+}
+
+// shortenURLWithAlias stores the URL under the caller-provided alias
+func (s *URLService) shortenURLWithAlias(
+	ctx context.Context,
+	validatedURL string,
+	alias string,
+	expiresAt *time.Time,
+	redirect model.RedirectSettings,
+	userID string,
+) (string, *appError.HTTPError) {
+	if err := s.validateAlias(alias); err != nil {
+		return "", appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidAlias, "Invalid alias was provided", err)
+	}
+
+	shortURL := fmt.Sprintf("%s/%s", s.baseURL, alias)
+
+	if urlPair, isFound := s.repo.GetByShort(ctx, alias); isFound {
+		if urlPair.Long == validatedURL && urlPair.OwnsLong() && expiresAt == nil {
+			s.notifyAudit(ctx, audit.ActionShorten, audit.OutcomeConflict, userID, urlPair)
+			return shortURL, appError.NewHTTPError(http.StatusConflict, appError.CodeURLConflict, "URL has already been shortened", nil)
 		}
+
+		return "", appError.NewHTTPError(http.StatusConflict, appError.CodeAliasTaken, "Alias is already taken", errors.New("alias is used by another URL"))
 	}
 
-	shortURL := fmt.Sprintf("%s/%s", s.baseURL, urlPath)
+	urlPair := model.NewURLPair(alias, validatedURL, nil, userID, false)
+	urlPair.ExpiresAt = expiresAt
+	urlPair.Redirect = redirect
 
-	if isFound || (err != nil && errors.Is(err, repository.ErrorOnConflict)) {
-		return shortURL, appError.NewHTTPError(http.StatusConflict, "", nil)
+	stored, created, err := s.repo.GetOrCreate(ctx, urlPair)
+	if errors.Is(err, repository.ErrorShortTaken) {
+		return "", appError.NewHTTPError(http.StatusConflict, appError.CodeAliasTaken, "Alias is already taken", err)
+	}
+	if err != nil {
+		return "", appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to save URL", err)
+	}
+
+	// The long URL is already shortened under another short URL, which is returned as the non-alias path does
+	if !created {
+		s.notifyAudit(ctx, audit.ActionShorten, audit.OutcomeConflict, userID, stored)
+		return fmt.Sprintf("%s/%s", s.baseURL, stored.Short), appError.NewHTTPError(http.StatusConflict, appError.CodeURLConflict, "URL has already been shortened", nil)
 	}
 
-	s.audit.Notify(audit.Event{
-		TS:     time.Now().Unix(),
-		Action: "shorten",
-		UserID: userID,
-		URL:    validatedURL,
-	})
+	s.notifyAudit(ctx, audit.ActionShorten, audit.OutcomeSuccess, userID, urlPair)
 
 	return shortURL, nil
 }
 
 // BatchShortenURL shortens multiple URLs in a single request.
-func (s *URLService) BatchShortenURL(items []model.BatchShortenURLRequest, userID string) ([]*model.BatchShortenURLResponse, *appError.HTTPError) {
+func (s *URLService) BatchShortenURL(
+	ctx context.Context,
+	items []model.BatchShortenURLRequest,
+	userID string,
+) (_ []*model.BatchShortenURLResponse, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "URLService.BatchShortenURL", attribute.Int("batch.size", len(items)))
+	defer func() { endSpan(span, appErr) }()
+
 	results := make([]*model.BatchShortenURLResponse, 0, len(items))
 	urlPairs := make([]*model.URLPair, 0, len(items))
+	batchPaths := make(map[string]string, len(items))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2000*time.Millisecond)
+	ctx, cancel := withTimeout(ctx, s.timeouts.Shorten)
 	defer cancel()
 
 	for _, item := range items {
 		validatedURL, err := s.validateURL(item.OriginalURL)
 		if err != nil {
-			return nil, appError.NewHTTPError(http.StatusBadRequest, "Invalid URL was provided", err)
+			return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidURL, "Invalid URL was provided", err)
+		}
+
+		if appErr := s.checkDestination(ctx, validatedURL, userID); appErr != nil {
+			return nil, appErr
+		}
+
+		expiresAt, err := s.resolveExpiresAt(item.ExpiresAt, item.TTLSeconds)
+		if err != nil {
+			return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidExpiration, "Invalid expiration was provided", err)
 		}
 
-		urlPath, err := s.generateShortURLPath(ctx, validatedURL)
+		redirect, err := validateRedirectSettings(item.Redirect)
 		if err != nil {
-			return nil, appError.NewHTTPError(http.StatusInternalServerError, "Failed to generate short URL", err)
+			return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRedirect, "Invalid redirect settings were provided", err)
 		}
 
-		urlPairs = append(urlPairs, model.NewURLPair(urlPath, item.OriginalURL, item.CorrelationID, userID, false))
+		urlPath, isFound := batchPaths[validatedURL]
+		if !isFound {
+			urlPath, err = s.generateShortURLPath(ctx, validatedURL, expiresAt)
+			if err != nil {
+				return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to generate short URL", err)
+			}
+			batchPaths[validatedURL] = urlPath
+		}
+
+		urlPair := model.NewURLPair(urlPath, validatedURL, item.CorrelationID, userID, false)
+		urlPair.ExpiresAt = expiresAt
+		urlPair.Redirect = redirect
+
+		urlPairs = append(urlPairs, urlPair)
 		results = append(results, &model.BatchShortenURLResponse{
 			CorrelationID: item.CorrelationID,
-			ShortURL:      fmt.Sprintf("%s/%s", s.baseURL, urlPath),
 		})
 	}
 
-	if err := s.repo.SaveMany(ctx, urlPairs); err != nil {
-		return nil, appError.NewHTTPError(http.StatusInternalServerError, "Failed to batch save URL pairs", err)
+	existing, err := s.saveURLPairs(ctx, urlPairs)
+	if err != nil {
+		return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to batch save URL pairs", err)
+	}
+
+	for i, urlPair := range urlPairs {
+		results[i].ShortURL = fmt.Sprintf("%s/%s", s.baseURL, urlPair.Short)
+
+		if stored, isFound := existing[urlPair.Long]; isFound {
+			results[i].ShortURL = fmt.Sprintf("%s/%s", s.baseURL, stored.Short)
+			results[i].Status = model.BatchStatusConflict
+
+			s.notifyAudit(ctx, audit.ActionShorten, audit.OutcomeConflict, userID, stored)
+			continue
+		}
+
+		s.notifyAudit(ctx, audit.ActionShorten, audit.OutcomeSuccess, userID, urlPair)
 	}
 
 	return results, nil
 }
 
-// ResolveShortURL resolves a short code to the original URL.
-func (s *URLService) ResolveShortURL(shortURL string) (string, *appError.HTTPError) {
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+// ResolveShortURL resolves a short code to the redirect to the original URL and records the click.
+// The redirect follows the settings of the link, the visit query is forwarded when the link allows it
+func (s *URLService) ResolveShortURL(ctx context.Context, shortURL string, visit model.Visit) (_ *model.Redirect, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "URLService.ResolveShortURL")
+	defer func() { endSpan(span, appErr) }()
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.Resolve)
 	defer cancel()
 
-	urlPair, isFound := s.repo.GetByShort(ctx, shortURL)
+	urlPair, appErr := findActiveURLPair(ctx, s.repo, shortURL, time.Now())
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	redirect, err := buildRedirect(urlPair, visit.Query)
+	if err != nil {
+		return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to build redirect", err)
+	}
+
+	s.clickWorker.Enqueue(newClick(urlPair.Short, visit, time.Now()))
+
+	s.notifyAudit(ctx, audit.ActionFollow, audit.OutcomeSuccess, urlPair.UserID, urlPair)
+
+	return redirect, nil
+}
+
+// findActiveURLPair returns the URL pair of a short code that is neither deleted nor expired
+// Returns 404 if the short code is unknown and 410 if the URL is no longer active
+func findActiveURLPair(
+	ctx context.Context,
+	repo repository.URLRepository,
+	short string,
+	now time.Time,
+) (*model.URLPair, *appError.HTTPError) {
+	urlPair, isFound := repo.GetByShort(ctx, short)
 
 	if !isFound {
-		return "", appError.NewHTTPError(
+		return nil, appError.NewHTTPError(
 			http.StatusNotFound,
+			appError.CodeURLNotFound,
 			"Could not resolve provided URL",
 			errors.New("url not found"),
 		)
 	}
 
 	if urlPair.IsDeleted {
-		return "", appError.NewHTTPError(
+		return nil, appError.NewHTTPError(
 			http.StatusGone,
+			appError.CodeURLGone,
 			"URL is no longer active",
 			errors.New("URL has been deleted by the user"),
 		)
 	}
 
-	s.audit.Notify(audit.Event{
-		TS:     time.Now().Unix(),
-		Action: "follow",
-		UserID: urlPair.UserID,
-		URL:    urlPair.Long,
-	})
+	if urlPair.IsExpired(now) {
+		return nil, appError.NewHTTPError(
+			http.StatusGone,
+			appError.CodeURLGone,
+			"URL is no longer active",
+			errors.New("URL has expired"),
+		)
+	}
 
-	return urlPair.Long, nil
+	return urlPair, nil
 }
 
 // GetUserURLs returns all URLs created by a user
-func (s *URLService) GetUserURLs(userID string) ([]*model.URLPairsResponse, *appError.HTTPError) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1000*time.Millisecond)
+func (s *URLService) GetUserURLs(ctx context.Context, userID string) (_ []*model.URLPairsResponse, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "URLService.GetUserURLs")
+	defer func() { endSpan(span, appErr) }()
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.List)
 	defer cancel()
 
 	urlPairs, err := s.repo.GetAllByUserID(ctx, userID)
 	if err != nil {
-		return nil, appError.NewHTTPError(http.StatusInternalServerError, "Failed to get user URLs", err)
+		return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to get user URLs", err)
 	}
 
 	results := make([]*model.URLPairsResponse, 0, len(urlPairs))
@@ -169,12 +346,152 @@ func (s *URLService) GetUserURLs(userID string) ([]*model.URLPairsResponse, *app
 	return results, nil
 }
 
+// ListUserURLs returns a page of URLs created by a user
+// Without a limit and a cursor every URL of the user is returned in a single page
+// The next cursor is empty when there are no more pages
+func (s *URLService) ListUserURLs(ctx context.Context, userID string, query model.UserURLsQuery) (_ *model.UserURLsPage, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "URLService.ListUserURLs")
+	defer func() { endSpan(span, appErr) }()
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.List)
+	defer cancel()
+
+	repoQuery := repository.UserURLsQuery{
+		UserID:         userID,
+		Limit:          query.Limit,
+		Search:         query.Search,
+		IncludeDeleted: query.IncludeDeleted,
+	}
+
+	// Unpaginated listings are read from the storage in pages of the largest size
+	unpaginated := query.Limit == 0 && query.Cursor == ""
+
+	switch {
+	case unpaginated:
+		repoQuery.Limit = MaxPageLimit
+	case repoQuery.Limit == 0:
+		repoQuery.Limit = DefaultPageLimit
+	}
+	if repoQuery.Limit < 0 || repoQuery.Limit > MaxPageLimit {
+		return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidPagination, fmt.Sprintf("Limit must be between 1 and %d", MaxPageLimit), nil)
+	}
+
+	switch query.Sort {
+	case "", SortCreatedAtAsc:
+	case SortCreatedAtDesc:
+		repoQuery.Descending = true
+	default:
+		return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidPagination, "Unsupported sort order", nil)
+	}
+
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidPagination, "Invalid page cursor", err)
+		}
+		repoQuery.After = after
+	}
+
+	// One extra URL pair tells whether there is a next page
+	limit := repoQuery.Limit
+	repoQuery.Limit++
+
+	page := &model.UserURLsPage{Items: make([]*model.URLPairsResponse, 0)}
+
+	for {
+		urlPairs, err := s.repo.GetPageByUserID(ctx, repoQuery)
+		if err != nil {
+			return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to get user URLs", err)
+		}
+
+		hasNext := len(urlPairs) > limit
+		if hasNext {
+			urlPairs = urlPairs[:limit]
+		}
+
+		for _, urlPair := range urlPairs {
+			page.Items = append(page.Items, &model.URLPairsResponse{
+				OriginalURL: urlPair.Long,
+				ShortURL:    fmt.Sprintf("%s/%s", s.baseURL, urlPair.Short),
+				CreatedAt:   urlPair.CreatedAt,
+				IsDeleted:   urlPair.IsDeleted,
+			})
+		}
+
+		if !hasNext {
+			return page, nil
+		}
+
+		last := urlPairs[limit-1]
+		if !unpaginated {
+			page.NextCursor = encodeCursor(last)
+			return page, nil
+		}
+
+		repoQuery.After = &repository.PageCursor{CreatedAt: last.CreatedAt, Short: last.Short}
+	}
+}
+
+// UpdateRedirectSettings replaces the redirect settings of a URL owned by the user
+func (s *URLService) UpdateRedirectSettings(
+	ctx context.Context,
+	userID string,
+	short string,
+	settings model.RedirectSettings,
+) (_ *model.RedirectSettings, appErr *appError.HTTPError) {
+	ctx, span := tracing.Start(ctx, "URLService.UpdateRedirectSettings")
+	defer func() { endSpan(span, appErr) }()
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.Shorten)
+	defer cancel()
+
+	redirect, err := validateRedirectSettings(&settings)
+	if err != nil {
+		return nil, appError.NewHTTPError(http.StatusBadRequest, appError.CodeInvalidRedirect, "Invalid redirect settings were provided", err)
+	}
+
+	urlPair, appErr := findActiveURLPair(ctx, s.repo, short, time.Now())
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if urlPair.UserID != userID {
+		s.notifyAudit(ctx, audit.ActionUpdateRedirect, audit.OutcomeDenied, userID, urlPair)
+		return nil, appError.NewHTTPError(
+			http.StatusForbidden,
+			appError.CodeForbidden,
+			"Access to URL settings is denied",
+			errors.New("url belongs to another user"),
+		)
+	}
+
+	if err := s.repo.UpdateRedirect(ctx, short, redirect); err != nil {
+		return nil, appError.NewHTTPError(http.StatusInternalServerError, appError.CodeInternal, "Failed to update redirect settings", err)
+	}
+
+	s.notifyAudit(ctx, audit.ActionUpdateRedirect, audit.OutcomeSuccess, userID, urlPair)
+
+	return &redirect, nil
+}
+
 // DeleteUserURLs enqueues URL deletion tasks for the user
-func (s *URLService) DeleteUserURLs(userID string, shorts []string) *appError.HTTPError {
+// The request is audited at once, the deletion when the worker deletes the URL
+func (s *URLService) DeleteUserURLs(ctx context.Context, userID string, shorts []string) *appError.HTTPError {
+	ctx, span := tracing.Start(ctx, "URLService.DeleteUserURLs", attribute.Int("batch.size", len(shorts)))
+	defer span.End()
+
+	requestID := audit.OriginFromContext(ctx).RequestID
+
 	for _, short := range shorts {
+		event := audit.NewEvent(ctx, audit.ActionDeleteRequest, audit.OutcomeAccepted)
+		event.UserID = userID
+		event.Short = short
+		s.audit.Notify(event)
+
 		s.deleteURLWorker.Enqueue(model.DeleteURLTask{
-			UserID: userID,
-			Short:  short,
+			UserID:    userID,
+			Short:     short,
+			RequestID: requestID,
 		})
 	}
 
@@ -200,40 +517,169 @@ func (s *URLService) validateURL(originalURL string) (string, error) {
 	return resultURL.String(), nil
 }
 
-// generateShortURLPath generates a unique short path
-func (s *URLService) generateShortURLPath(ctx context.Context, originalURL string) (string, error) {
-	urlPath := s.hashURL(originalURL)
+// checkDestination applies the destination policy to a validated URL
+// Rejected URLs are recorded in the audit log
+func (s *URLService) checkDestination(ctx context.Context, validatedURL string, userID string) *appError.HTTPError {
+	violation := s.policy.Check(validatedURL)
+	if violation == nil {
+		return nil
+	}
 
-	for {
-		urlPair, isFound := s.repo.GetByShort(ctx, urlPath)
+	event := audit.NewEvent(ctx, audit.ActionBlock, audit.OutcomeDenied)
+	event.UserID = userID
+	event.URL = validatedURL
+	event.Reason = violation.Rule
+	s.audit.Notify(event)
 
-		if !isFound || urlPair.Long == originalURL {
-			return urlPath, nil
+	return appError.NewHTTPError(http.StatusUnprocessableEntity, appError.CodeDestinationBlocked, "URL destination is not allowed", violation)
+}
+
+// notifyAudit records an audit event about the URL pair caused by the request of the context
+func (s *URLService) notifyAudit(ctx context.Context, action string, outcome string, userID string, urlPair *model.URLPair) {
+	event := audit.NewEvent(ctx, action, outcome)
+	event.UserID = userID
+	event.URL = urlPair.Long
+	event.Short = urlPair.Short
+
+	s.audit.Notify(event)
+}
+
+// resolveExpiresAt computes the absolute expiry time from either an explicit timestamp or a TTL
+// Returns nil if the URL never expires
+func (s *URLService) resolveExpiresAt(expiresAt *time.Time, ttlSeconds *int64) (*time.Time, error) {
+	now := time.Now()
+
+	switch {
+	case expiresAt != nil && ttlSeconds != nil:
+		return nil, errors.New("only one of expires_at and ttl_seconds can be set")
+
+	case ttlSeconds != nil:
+		if *ttlSeconds <= 0 || *ttlSeconds > maxTTLSeconds {
+			return nil, fmt.Errorf("ttl_seconds must be between 1 and %d", maxTTLSeconds)
 		}
 
-		salted, err := s.addSalt(originalURL)
-		if err != nil {
-			return "", err
+		result := now.Add(time.Duration(*ttlSeconds) * time.Second).UTC()
+		return &result, nil
+
+	case expiresAt != nil:
+		if !expiresAt.After(now) {
+			return nil, errors.New("expires_at must be in the future")
 		}
 
-		originalURL = salted
-		urlPath = s.hashURL(originalURL)
+		result := expiresAt.UTC()
+		return &result, nil
 	}
+
+	return nil, nil
 }
 
-// addSalt appends random data to avoid hash collisions
-func (s *URLService) addSalt(url string) (string, error) {
-	b := make([]byte, 4)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
+// validateAlias checks that an alias is usable as a short code
+func (s *URLService) validateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return fmt.Errorf("alias length must be between %d and %d characters", minAliasLength, maxAliasLength)
+	}
+
+	for _, c := range alias {
+		if !isAliasChar(c) {
+			return fmt.Errorf("alias contains forbidden character %q", c)
+		}
+	}
+
+	if slices.Contains(reservedAliases, strings.ToLower(alias)) {
+		return fmt.Errorf("alias %q is reserved", alias)
+	}
+
+	return nil
+}
+
+// isAliasChar reports whether the rune is allowed in an alias
+func isAliasChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		c == '-' || c == '_'
+}
+
+// getOrCreateURLPair atomically stores the URL under a generated short path unless it is already shortened
+// Returns the stored pair and whether it was created, generating a new path while the short path is taken
+func (s *URLService) getOrCreateURLPair(
+	ctx context.Context,
+	originalURL string,
+	expiresAt *time.Time,
+	redirect model.RedirectSettings,
+	userID string,
+) (*model.URLPair, bool, error) {
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		urlPath, err := s.generator.Generate(ctx, originalURL, attempt)
+		if err != nil {
+			return nil, false, err
+		}
+
+		urlPair := model.NewURLPair(urlPath, originalURL, nil, userID, false)
+		urlPair.ExpiresAt = expiresAt
+		urlPair.Redirect = redirect
+
+		stored, created, err := s.repo.GetOrCreate(ctx, urlPair)
+		if errors.Is(err, repository.ErrorShortTaken) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		return stored, created, nil
 	}
 
-	return url + ":" + base64.RawURLEncoding.EncodeToString(b), nil
+	return nil, false, errors.New("could not find a free short code")
 }
 
-// hashURL generates a short hash for a URL
-func (s *URLService) hashURL(url string) string {
-	hash := sha1.Sum([]byte(url))
-	return base64.URLEncoding.EncodeToString(hash[:])[:7]
+// saveURLPairs stores the URL pairs of a batch
+// URL pairs whose short URL was taken by another long URL after it was generated get new short URLs
+// and the batch is saved again, up to maxGenerateAttempts times
+func (s *URLService) saveURLPairs(ctx context.Context, urlPairs []*model.URLPair) (map[string]*model.URLPair, error) {
+	for attempt := 1; ; attempt++ {
+		existing, err := s.repo.SaveMany(ctx, urlPairs)
+		if !errors.Is(err, repository.ErrorShortTaken) || attempt >= maxGenerateAttempts {
+			return existing, err
+		}
+
+		// URL pairs of the same long URL share the short URL, so they are moved together
+		paths := make(map[string]string)
+
+		for _, urlPair := range urlPairs {
+			stored, isFound := s.repo.GetByShort(ctx, urlPair.Short)
+			if !isFound || (urlPair.ExpiresAt == nil && stored.Long == urlPair.Long && stored.OwnsLong()) {
+				continue
+			}
+
+			urlPath, isFound := paths[urlPair.Long]
+			if !isFound {
+				urlPath, err = s.generateShortURLPath(ctx, urlPair.Long, urlPair.ExpiresAt)
+				if err != nil {
+					return nil, err
+				}
+				paths[urlPair.Long] = urlPath
+			}
+
+			urlPair.Short = urlPath
+		}
+	}
+}
+
+// generateShortURLPath generates a short path that is free or already owned by the same URL
+// A link with an expiry never reuses a stored one, see model.URLPair.OwnsLong
+func (s *URLService) generateShortURLPath(ctx context.Context, originalURL string, expiresAt *time.Time) (string, error) {
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		urlPath, err := s.generator.Generate(ctx, originalURL, attempt)
+		if err != nil {
+			return "", err
+		}
+
+		urlPair, isFound := s.repo.GetByShort(ctx, urlPath)
+		if !isFound || (expiresAt == nil && urlPair.Long == originalURL && urlPair.OwnsLong()) {
+			return urlPath, nil
+		}
+	}
+
+	return "", errors.New("could not find a free short code")
 }
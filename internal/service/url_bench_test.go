@@ -1,39 +1,48 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
 	"testing"
 
+	"github.com/alikhanturusbekov/go-url-shortener/internal/generator"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/policy"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/worker"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
 )
 
 func BenchmarkHashURL(b *testing.B) {
-	svc := NewURLService(repository.NewURLInMemoryRepository(), "http://localhost:8080", worker.NewDeleteURLWorker(repository.NewURLInMemoryRepository(), 10), audit.NewNoop())
+	hashGenerator := newHashGenerator(b)
+	ctx := context.Background()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		_ = svc.hashURL("https://example.com/some/really/long/url/path?with=query&and=values")
+		_, _ = hashGenerator.Generate(ctx, "https://example.com/some/really/long/url/path?with=query&and=values", 0)
 	}
 }
 
 func BenchmarkShortenURL_InMemory(b *testing.B) {
 	repo := repository.NewURLInMemoryRepository()
-	w := worker.NewDeleteURLWorker(repo, 10)
-	svc := NewURLService(repo, "http://localhost:8080", w, audit.NewNoop())
+	w := worker.NewDeleteURLWorker(repo, audit.NewNoop(), 10)
+	cw := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 10)
+	svc := NewURLService(repo, "http://localhost:8080", newHashGenerator(b), newDestinationPolicy(), w, cw, audit.NewNoop(), DefaultTimeouts())
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		_, _ = svc.ShortenURL("https://example.com/some/really/long/url/path?with=query&and=values", "user-1")
+		_, _ = svc.ShortenURL(context.Background(), model.Request{URL: "https://example.com/some/really/long/url/path?with=query&and=values"}, "user-1")
 	}
 }
 
 func BenchmarkBatchShortenURL_InMemory(b *testing.B) {
 	repo := repository.NewURLInMemoryRepository()
-	w := worker.NewDeleteURLWorker(repo, 10)
-	svc := NewURLService(repo, "http://localhost:8080", w, audit.NewNoop())
+	w := worker.NewDeleteURLWorker(repo, audit.NewNoop(), 10)
+	cw := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 10)
+	svc := NewURLService(repo, "http://localhost:8080", newHashGenerator(b), newDestinationPolicy(), w, cw, audit.NewNoop(), DefaultTimeouts())
 
 	items := make([]model.BatchShortenURLRequest, 0, 100)
 	for i := 0; i < 100; i++ {
@@ -47,6 +56,93 @@ func BenchmarkBatchShortenURL_InMemory(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		_, _ = svc.BatchShortenURL(items, "user-1")
+		_, _ = svc.BatchShortenURL(context.Background(), items, "user-1")
 	}
 }
+
+func BenchmarkResolveShortURL_InMemory(b *testing.B) {
+	for _, size := range []int{1_000, 100_000, 1_000_000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			svc, shorts := setupResolveBenchmark(b, size)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, _ = svc.ResolveShortURL(context.Background(), shorts[i%len(shorts)], model.Visit{})
+			}
+		})
+	}
+}
+
+func BenchmarkResolveShortURL_InMemoryParallel(b *testing.B) {
+	for _, size := range []int{1_000, 1_000_000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			svc, shorts := setupResolveBenchmark(b, size)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					_, _ = svc.ResolveShortURL(context.Background(), shorts[i%len(shorts)], model.Visit{})
+					i++
+				}
+			})
+		})
+	}
+}
+
+// setupResolveBenchmark fills an in-memory repository with size URL pairs
+// and returns the service together with a sample of stored short URLs
+func setupResolveBenchmark(b *testing.B, size int) (*URLService, []string) {
+	b.Helper()
+
+	const (
+		chunkSize  = 10_000
+		sampleSize = 1_000
+	)
+
+	repo := repository.NewURLInMemoryRepository()
+	ctx := context.Background()
+
+	chunk := make([]*model.URLPair, 0, chunkSize)
+	for i := 0; i < size; i++ {
+		short := strconv.FormatInt(int64(i), 36)
+		chunk = append(chunk, model.NewURLPair(short, "https://example.com/"+short, &short, "user-"+strconv.Itoa(i%1000), false))
+
+		if len(chunk) == chunkSize || i == size-1 {
+			if _, err := repo.SaveMany(ctx, chunk); err != nil {
+				b.Fatal(err)
+			}
+			chunk = chunk[:0]
+		}
+	}
+
+	shorts := make([]string, 0, sampleSize)
+	for i := 0; i < sampleSize; i++ {
+		shorts = append(shorts, strconv.FormatInt(int64(rand.IntN(size)), 36))
+	}
+
+	w := worker.NewDeleteURLWorker(repo, audit.NewNoop(), 10)
+	cw := worker.NewClickWorker(repository.NewClickInMemoryRepository(), 10)
+	svc := NewURLService(repo, "http://localhost:8080", newHashGenerator(b), newDestinationPolicy(), w, cw, audit.NewNoop(), DefaultTimeouts())
+
+	return svc, shorts
+}
+
+func newHashGenerator(b testing.TB) *generator.HashGenerator {
+	b.Helper()
+
+	hashGenerator, err := generator.NewHashGenerator(generator.DefaultLength, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return hashGenerator
+}
+
+func newDestinationPolicy() *policy.Policy {
+	return policy.NewPolicy(policy.DefaultOptions())
+}
@@ -0,0 +1,308 @@
+// Package transfer provides streaming export and import of URL pairs
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+)
+
+// Supported transfer formats
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// csvHeader lists the CSV columns in the order they are written
+var csvHeader = []string{"uid", "short", "long", "user_id", "is_deleted", "expires_at", "created_at", "redirect_type", "pass_query", "utm"}
+
+// Writer writes URL pairs one by one
+type Writer interface {
+	// Write writes a single URL pair
+	Write(urlPair *model.URLPair) error
+
+	// Flush writes any buffered data to the underlying writer
+	Flush() error
+}
+
+// Reader reads URL pairs one by one
+type Reader interface {
+	// Read returns the next URL pair or io.EOF when there are no more pairs
+	Read() (*model.URLPair, error)
+}
+
+// NewWriter creates a writer of the given format
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		return NewJSONLWriter(w), nil
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	}
+
+	return nil, fmt.Errorf("unknown transfer format %q", format)
+}
+
+// NewReader creates a reader of the given format
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatJSONL:
+		return NewJSONLReader(r), nil
+	case FormatCSV:
+		return NewCSVReader(r), nil
+	}
+
+	return nil, fmt.Errorf("unknown transfer format %q", format)
+}
+
+// JSONLWriter writes URL pairs as JSON lines
+type JSONLWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewJSONLWriter creates a new JSONLWriter instance
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	writer := bufio.NewWriter(w)
+	return &JSONLWriter{writer: writer, encoder: json.NewEncoder(writer)}
+}
+
+// Write writes a single URL pair as a JSON line
+func (w *JSONLWriter) Write(urlPair *model.URLPair) error {
+	return w.encoder.Encode(urlPair)
+}
+
+// Flush writes buffered lines to the underlying writer
+func (w *JSONLWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+// JSONLReader reads URL pairs from JSON lines
+type JSONLReader struct {
+	decoder *json.Decoder
+	line    int
+}
+
+// NewJSONLReader creates a new JSONLReader instance
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	return &JSONLReader{decoder: json.NewDecoder(bufio.NewReader(r))}
+}
+
+// Read decodes the next JSON line into a URL pair
+func (r *JSONLReader) Read() (*model.URLPair, error) {
+	var urlPair model.URLPair
+
+	r.line++
+	if err := r.decoder.Decode(&urlPair); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("record %d: %w", r.line, err)
+	}
+
+	return &urlPair, nil
+}
+
+// CSVWriter writes URL pairs as CSV rows with a header
+type CSVWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter creates a new CSVWriter instance
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{writer: csv.NewWriter(w)}
+}
+
+// Write writes a single URL pair as a CSV row, preceded by the header on the first call
+func (w *CSVWriter) Write(urlPair *model.URLPair) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	expiresAt := ""
+	if urlPair.ExpiresAt != nil {
+		expiresAt = formatTime(*urlPair.ExpiresAt)
+	}
+
+	createdAt := ""
+	if !urlPair.CreatedAt.IsZero() {
+		createdAt = formatTime(urlPair.CreatedAt)
+	}
+
+	redirectType := ""
+	if urlPair.Redirect.Type != 0 {
+		redirectType = strconv.Itoa(urlPair.Redirect.Type)
+	}
+
+	return w.writer.Write([]string{
+		urlPair.ID,
+		urlPair.Short,
+		urlPair.Long,
+		urlPair.UserID,
+		strconv.FormatBool(urlPair.IsDeleted),
+		expiresAt,
+		createdAt,
+		redirectType,
+		strconv.FormatBool(urlPair.Redirect.PassQuery),
+		urlPair.Redirect.UTM.Values().Encode(),
+	})
+}
+
+// Flush writes buffered rows to the underlying writer
+// The header is written even if there were no URL pairs
+func (w *CSVWriter) Flush() error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// CSVReader reads URL pairs from CSV rows
+// Columns are matched by the header, so their order does not matter
+type CSVReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// NewCSVReader creates a new CSVReader instance
+func NewCSVReader(r io.Reader) *CSVReader {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.ReuseRecord = true
+
+	return &CSVReader{reader: reader}
+}
+
+// Read parses the next CSV row into a URL pair
+func (r *CSVReader) Read() (*model.URLPair, error) {
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	record, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	urlPair := &model.URLPair{
+		ID:     r.field(record, "uid"),
+		Short:  r.field(record, "short"),
+		Long:   r.field(record, "long"),
+		UserID: r.field(record, "user_id"),
+	}
+
+	if isDeleted := r.field(record, "is_deleted"); isDeleted != "" {
+		urlPair.IsDeleted, err = strconv.ParseBool(isDeleted)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid is_deleted: %w", line, err)
+		}
+	}
+
+	if expiresAt := r.field(record, "expires_at"); expiresAt != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expires_at: %w", line, err)
+		}
+		urlPair.ExpiresAt = &parsed
+	}
+
+	if createdAt := r.field(record, "created_at"); createdAt != "" {
+		urlPair.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid created_at: %w", line, err)
+		}
+	}
+
+	if redirectType := r.field(record, "redirect_type"); redirectType != "" {
+		urlPair.Redirect.Type, err = strconv.Atoi(redirectType)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid redirect_type: %w", line, err)
+		}
+	}
+
+	if passQuery := r.field(record, "pass_query"); passQuery != "" {
+		urlPair.Redirect.PassQuery, err = strconv.ParseBool(passQuery)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid pass_query: %w", line, err)
+		}
+	}
+
+	if utm := r.field(record, "utm"); utm != "" {
+		urlPair.Redirect.UTM, err = parseUTM(utm)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid utm: %w", line, err)
+		}
+	}
+
+	return urlPair, nil
+}
+
+// readHeader reads the header row and indexes its columns
+func (r *CSVReader) readHeader() error {
+	header, err := r.reader.Read()
+	if err != nil {
+		return err
+	}
+
+	r.columns = make(map[string]int, len(header))
+	for i, column := range header {
+		r.columns[column] = i
+	}
+
+	for _, column := range []string{"short", "long"} {
+		if _, ok := r.columns[column]; !ok {
+			return fmt.Errorf("missing required column %q", column)
+		}
+	}
+
+	return nil
+}
+
+// field returns the value of the named column, empty if the column is absent
+func (r *CSVReader) field(record []string, column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(record) {
+		return ""
+	}
+
+	return record[i]
+}
+
+// formatTime formats a time as an RFC 3339 UTC timestamp
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseUTM parses UTM parameters encoded as a query string
+func parseUTM(value string) (*model.UTMParams, error) {
+	values, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.UTMParams{
+		Source:   values.Get("utm_source"),
+		Medium:   values.Get("utm_medium"),
+		Campaign: values.Get("utm_campaign"),
+		Term:     values.Get("utm_term"),
+		Content:  values.Get("utm_content"),
+	}, nil
+}
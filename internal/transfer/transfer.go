@@ -0,0 +1,95 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/google/uuid"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+)
+
+// Conflict describes an imported URL pair that could not be stored
+type Conflict struct {
+	URLPair *model.URLPair
+	Reason  string
+}
+
+// Conflict reasons reported by Import
+const (
+	ReasonShortTaken   = "short code is used by another URL"
+	ReasonLongConflict = "URL is stored under another short code"
+)
+
+// ImportStats summarizes an import
+type ImportStats struct {
+	Imported  int
+	Skipped   int
+	Conflicts int
+}
+
+// Export writes every URL pair of the repository, including deleted ones
+// Returns the number of exported URL pairs
+func Export(ctx context.Context, repo repository.URLRepository, writer Writer) (int, error) {
+	exported := 0
+
+	err := repo.ForEach(ctx, func(urlPair *model.URLPair) error {
+		if err := writer.Write(urlPair); err != nil {
+			return err
+		}
+		exported++
+
+		return nil
+	})
+	if err != nil {
+		return exported, err
+	}
+
+	return exported, writer.Flush()
+}
+
+// Import stores URL pairs read from the reader, preserving their short codes
+// URL pairs that are already stored unchanged are skipped, other clashes are
+// reported to onConflict and do not stop the import
+func Import(ctx context.Context, repo repository.URLRepository, reader Reader, onConflict func(Conflict)) (ImportStats, error) {
+	var stats ImportStats
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		urlPair, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		if urlPair.ID == "" {
+			urlPair.ID = uuid.NewString()
+		}
+
+		err = repo.Save(ctx, urlPair)
+		switch {
+		case err == nil:
+			stats.Imported++
+		case errors.Is(err, repository.ErrorShortTaken):
+			stored, isFound := repo.GetByShort(ctx, urlPair.Short)
+			if isFound && stored.Long == urlPair.Long {
+				stats.Skipped++
+				continue
+			}
+			stats.Conflicts++
+			onConflict(Conflict{URLPair: urlPair, Reason: ReasonShortTaken})
+		case errors.Is(err, repository.ErrorOnConflict):
+			stats.Conflicts++
+			onConflict(Conflict{URLPair: urlPair, Reason: ReasonLongConflict})
+		default:
+			return stats, err
+		}
+	}
+}
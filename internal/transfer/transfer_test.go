@@ -0,0 +1,148 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+)
+
+func TestExportImport(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 6, time.UTC)
+	urlPairs := []*model.URLPair{
+		{ID: "uid-1", Short: "abc", Long: "https://yandex.ru", UserID: "user-1", CreatedAt: expiresAt.AddDate(-5, 0, 0)},
+		{ID: "uid-2", Short: "def", Long: "https://google.com", UserID: "user-2", IsDeleted: true, Redirect: model.RedirectSettings{
+			Type:      308,
+			PassQuery: true,
+			UTM:       &model.UTMParams{Source: "mail", Campaign: "spring sale"},
+		}},
+		{ID: "uid-3", Short: "ghi", Long: "https://example.com/a,b?q=\"x\"", ExpiresAt: &expiresAt},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+	}{
+		{
+			name:   "Positive case: JSONL round trip",
+			format: FormatJSONL,
+		},
+		{
+			name:   "Positive case: CSV round trip",
+			format: FormatCSV,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			source := repository.NewURLInMemoryRepository()
+			for _, urlPair := range urlPairs {
+				require.NoError(t, source.Save(ctx, urlPair))
+			}
+
+			var buf bytes.Buffer
+			writer, err := NewWriter(tt.format, &buf)
+			require.NoError(t, err)
+
+			exported, err := Export(ctx, source, writer)
+			require.NoError(t, err)
+			assert.Equal(t, len(urlPairs), exported)
+
+			reader, err := NewReader(tt.format, &buf)
+			require.NoError(t, err)
+
+			target := repository.NewURLInMemoryRepository()
+			stats, err := Import(ctx, target, reader, func(conflict Conflict) {
+				t.Errorf("unexpected conflict: %+v", conflict)
+			})
+			require.NoError(t, err)
+			assert.Equal(t, ImportStats{Imported: len(urlPairs)}, stats)
+
+			for _, urlPair := range urlPairs {
+				stored, isFound := target.GetByShort(ctx, urlPair.Short)
+				require.True(t, isFound)
+				assert.Equal(t, urlPair.ID, stored.ID)
+				assert.Equal(t, urlPair.Long, stored.Long)
+				assert.Equal(t, urlPair.UserID, stored.UserID)
+				assert.Equal(t, urlPair.IsDeleted, stored.IsDeleted)
+				assert.True(t, urlPair.CreatedAt.Equal(stored.CreatedAt))
+				assert.Equal(t, urlPair.Redirect, stored.Redirect)
+				if urlPair.ExpiresAt == nil {
+					assert.Nil(t, stored.ExpiresAt)
+				} else {
+					require.NotNil(t, stored.ExpiresAt)
+					assert.True(t, urlPair.ExpiresAt.Equal(*stored.ExpiresAt))
+				}
+			}
+		})
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	target := repository.NewURLInMemoryRepository()
+	require.NoError(t, target.Save(ctx, &model.URLPair{ID: "uid-1", Short: "abc", Long: "https://yandex.ru"}))
+	require.NoError(t, target.Save(ctx, &model.URLPair{ID: "uid-2", Short: "def", Long: "https://google.com"}))
+
+	input := strings.Join([]string{
+		"short,long,user_id",
+		"abc,https://yandex.ru,user-1",
+		"abc,https://example.com,user-1",
+		"xyz,https://google.com,user-1",
+		"new,https://example.org,user-1",
+	}, "\n")
+
+	var conflicts []Conflict
+	stats, err := Import(ctx, target, NewCSVReader(strings.NewReader(input)), func(conflict Conflict) {
+		conflicts = append(conflicts, conflict)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ImportStats{Imported: 1, Skipped: 1, Conflicts: 2}, stats)
+	require.Len(t, conflicts, 2)
+	assert.Equal(t, "abc", conflicts[0].URLPair.Short)
+	assert.Equal(t, ReasonShortTaken, conflicts[0].Reason)
+	assert.Equal(t, "xyz", conflicts[1].URLPair.Short)
+	assert.Equal(t, ReasonLongConflict, conflicts[1].Reason)
+
+	stored, isFound := target.GetByShort(ctx, "new")
+	require.True(t, isFound)
+	assert.NotEmpty(t, stored.ID)
+}
+
+func TestCSVReaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "Negative case: missing long column",
+			input: "short\nabc\n",
+		},
+		{
+			name:  "Negative case: invalid is_deleted",
+			input: "short,long,is_deleted\nabc,https://yandex.ru,maybe\n",
+		},
+		{
+			name:  "Negative case: invalid expires_at",
+			input: "short,long,expires_at\nabc,https://yandex.ru,tomorrow\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCSVReader(strings.NewReader(tt.input)).Read()
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+)
+
+// ClickWorker records short URL clicks asynchronously
+type ClickWorker struct {
+	repository repository.ClickRepository
+	in         chan *model.Click
+}
+
+// NewClickWorker creates a new ClickWorker instance
+func NewClickWorker(
+	repository repository.ClickRepository,
+	bufferSize int,
+) *ClickWorker {
+	return &ClickWorker{
+		repository: repository,
+		in:         make(chan *model.Click, bufferSize),
+	}
+}
+
+// Enqueue adds a click to the worker queue
+// The click is dropped if the queue is full so that redirects are never blocked
+func (w *ClickWorker) Enqueue(click *model.Click) {
+	select {
+	case w.in <- click:
+	default:
+		logger.Log.Warn("click buffer full, dropping click", zap.String("short", click.Short))
+	}
+}
+
+// Run starts the worker loop and stores clicks in batches
+func (w *ClickWorker) Run(ctx context.Context) {
+	const (
+		maxBatchSize = 100
+		flushTimeout = time.Second
+	)
+
+	ticker := time.NewTicker(flushTimeout)
+	defer ticker.Stop()
+
+	buffer := make([]*model.Click, 0, maxBatchSize)
+
+	flush := func(ctx context.Context) {
+		if len(buffer) == 0 {
+			return
+		}
+
+		if err := w.repository.SaveClicks(ctx, buffer); err != nil {
+			logger.Log.Error("could not save clicks", zap.Error(err), zap.Int("count", len(buffer)))
+		}
+
+		buffer = buffer[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case click := <-w.in:
+					buffer = append(buffer, click)
+				default:
+					flush(context.WithoutCancel(ctx))
+					return
+				}
+			}
+
+		case click := <-w.in:
+			buffer = append(buffer, click)
+
+			if len(buffer) >= maxBatchSize {
+				flush(ctx)
+			}
+
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
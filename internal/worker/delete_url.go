@@ -4,24 +4,39 @@ import (
 	"context"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
 	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/audit"
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/metrics"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
 )
 
+// taskKey identifies the deletion of a short URL of a user
+type taskKey struct {
+	userID string
+	short  string
+}
+
 // DeleteURLWorker processes URL deletion tasks asynchronously
+// Every URL it deletes is recorded in the audit log
 type DeleteURLWorker struct {
 	repository repository.URLRepository
+	audit      audit.Publisher
 	in         chan model.DeleteURLTask
 }
 
 // NewDeleteURLWorker creates a new DeleteURLWorker instance
 func NewDeleteURLWorker(
 	repository repository.URLRepository,
+	auditPublisher audit.Publisher,
 	bufferSize int,
 ) *DeleteURLWorker {
 	return &DeleteURLWorker{
 		repository: repository,
+		audit:      auditPublisher,
 		in:         make(chan model.DeleteURLTask, bufferSize),
 	}
 }
@@ -31,6 +46,11 @@ func (w *DeleteURLWorker) Enqueue(task model.DeleteURLTask) {
 	w.in <- task
 }
 
+// QueueLen returns the number of queued deletion tasks
+func (w *DeleteURLWorker) QueueLen() int {
+	return len(w.in)
+}
+
 // Run starts the worker loop and processes tasks in batches
 func (w *DeleteURLWorker) Run(ctx context.Context) {
 	const (
@@ -48,14 +68,34 @@ func (w *DeleteURLWorker) Run(ctx context.Context) {
 			return
 		}
 
+		flushCtx, span := tracing.Start(ctx, "DeleteURLWorker.flush", attribute.Int("batch.size", len(buffer)))
+		defer span.End()
+
+		metrics.DeleteWorkerFlushSize.Observe(float64(len(buffer)))
+
 		grouped := make(map[string][]string)
+		requestIDs := make(map[taskKey]string)
 		for _, task := range buffer {
 			grouped[task.UserID] = append(grouped[task.UserID], task.Short)
+			requestIDs[taskKey{userID: task.UserID, short: task.Short}] = task.RequestID
 		}
 
 		for userID, urls := range grouped {
-			if err := w.repository.DeleteByShorts(ctx, userID, urls); err != nil {
-				logger.Log.Error("could not delete user URLs:" + err.Error())
+			deleted, err := w.repository.DeleteByShorts(flushCtx, userID, urls)
+			if err != nil {
+				span.RecordError(err)
+				logger.FromContext(flushCtx).Error("could not delete user URLs:" + err.Error())
+				continue
+			}
+
+			for _, urlPair := range deleted {
+				requestID := requestIDs[taskKey{userID: userID, short: urlPair.Short}]
+
+				event := audit.NewEvent(audit.WithOrigin(flushCtx, audit.Origin{RequestID: requestID}), audit.ActionDelete, audit.OutcomeSuccess)
+				event.UserID = userID
+				event.URL = urlPair.Long
+				event.Short = urlPair.Short
+				w.audit.Notify(event)
 			}
 		}
 
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+)
+
+// ExpiredURLReaper periodically marks expired URL pairs as deleted
+type ExpiredURLReaper struct {
+	repository repository.URLRepository
+	interval   time.Duration
+}
+
+// NewExpiredURLReaper creates a new ExpiredURLReaper instance
+func NewExpiredURLReaper(
+	repository repository.URLRepository,
+	interval time.Duration,
+) *ExpiredURLReaper {
+	return &ExpiredURLReaper{
+		repository: repository,
+		interval:   interval,
+	}
+}
+
+// Run starts the reaper loop until the context is cancelled
+func (r *ExpiredURLReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case now := <-ticker.C:
+			r.reap(ctx, now)
+		}
+	}
+}
+
+// reap marks URL pairs expired at the given time as deleted
+func (r *ExpiredURLReaper) reap(ctx context.Context, now time.Time) {
+	affected, err := r.repository.DeleteExpired(ctx, now)
+	if err != nil {
+		logger.Log.Error("could not delete expired URLs", zap.Error(err))
+		return
+	}
+
+	if affected > 0 {
+		logger.Log.Info("expired URLs deleted", zap.Int64("count", affected))
+	}
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/internal/model"
+	"github.com/alikhanturusbekov/go-url-shortener/internal/repository"
+)
+
+func TestExpiredURLReaper(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := repository.NewURLInMemoryRepository()
+
+	expired := model.NewURLPair("expired", "https://expired.example", nil, "user-1", false)
+	expired.ExpiresAt = pointerTo(time.Now().Add(-time.Minute))
+	live := model.NewURLPair("live", "https://live.example", nil, "user-1", false)
+	live.ExpiresAt = pointerTo(time.Now().Add(time.Hour))
+
+	require.NoError(t, repo.Save(ctx, expired))
+	require.NoError(t, repo.Save(ctx, live))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewExpiredURLReaper(repo, 10*time.Millisecond).Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		count, err := repo.CountURLs(ctx)
+		return err == nil && count == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+
+	urlPair, isFound := repo.GetByShort(ctx, "expired")
+	require.True(t, isFound)
+	assert.True(t, urlPair.IsDeleted)
+
+	urlPair, isFound = repo.GetByShort(ctx, "live")
+	require.True(t, isFound)
+	assert.False(t, urlPair.IsDeleted)
+}
+
+func pointerTo[T any](v T) *T { return &v }
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_url_pairs_expires_at;
+
+ALTER TABLE url_pairs
+DROP COLUMN IF EXISTS expires_at;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE url_pairs
+    ADD COLUMN expires_at TIMESTAMPTZ;
+
+CREATE INDEX idx_url_pairs_expires_at ON url_pairs (expires_at) WHERE expires_at IS NOT NULL;
\ No newline at end of file
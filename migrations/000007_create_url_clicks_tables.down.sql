@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS url_clicks_daily;
+
+DROP INDEX IF EXISTS idx_url_clicks_short_visitor_id;
+DROP TABLE IF EXISTS url_clicks;
\ No newline at end of file
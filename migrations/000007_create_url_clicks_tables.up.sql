@@ -0,0 +1,18 @@
+CREATE TABLE url_clicks (
+    id BIGSERIAL PRIMARY KEY,
+    short VARCHAR(255) NOT NULL,
+    clicked_at TIMESTAMPTZ NOT NULL,
+    referrer TEXT,
+    user_agent TEXT,
+    ip_bucket VARCHAR(64),
+    visitor_id VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX idx_url_clicks_short_visitor_id ON url_clicks (short, visitor_id);
+
+CREATE TABLE url_clicks_daily (
+    short VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short, day)
+);
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE SEQUENCE IF NOT EXISTS short_code_seq START WITH 1;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_url_pairs_user_id_created_at;
+
+ALTER TABLE url_pairs
+DROP COLUMN IF EXISTS created_at;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE url_pairs
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX idx_url_pairs_user_id_created_at ON url_pairs (user_id, created_at, short);
\ No newline at end of file
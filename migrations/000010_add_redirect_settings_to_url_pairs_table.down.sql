@@ -0,0 +1,4 @@
+ALTER TABLE url_pairs
+DROP COLUMN IF EXISTS utm_params,
+DROP COLUMN IF EXISTS pass_query,
+DROP COLUMN IF EXISTS redirect_type;
\ No newline at end of file
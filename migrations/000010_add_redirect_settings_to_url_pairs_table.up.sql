@@ -0,0 +1,4 @@
+ALTER TABLE url_pairs
+    ADD COLUMN redirect_type SMALLINT NOT NULL DEFAULT 307,
+    ADD COLUMN pass_query BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN utm_params JSONB;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS url_pairs_unique_long;
+
+ALTER TABLE url_pairs
+    ADD CONSTRAINT url_pairs_unique_long UNIQUE (long);
\ No newline at end of file
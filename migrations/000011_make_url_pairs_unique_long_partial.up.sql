@@ -0,0 +1,4 @@
+ALTER TABLE url_pairs
+DROP CONSTRAINT IF EXISTS url_pairs_unique_long;
+
+CREATE UNIQUE INDEX url_pairs_unique_long ON url_pairs (long) WHERE is_deleted = FALSE AND expires_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/metrics"
+)
+
+// DeadLetterRecord is a line of the dead letter file
+type DeadLetterRecord struct {
+	TS       int64  `json:"ts"`
+	Observer string `json:"observer"`
+	Attempts int    `json:"attempts,omitempty"`
+	Error    string `json:"error"`
+	Event    *Event `json:"event,omitempty"`
+	// Raw holds a spooled event that could not be decoded
+	Raw string `json:"raw,omitempty"`
+}
+
+// deadLetter appends events that could not be delivered to a JSON lines file
+// Events are only logged when no file is configured
+type deadLetter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openDeadLetter opens the dead letter file, a nil file is used for an empty path
+func openDeadLetter(path string) (*deadLetter, error) {
+	if path == "" {
+		return &deadLetter{}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deadLetter{file: f}, nil
+}
+
+// write records the event that the observer failed to deliver
+func (d *deadLetter) write(record DeadLetterRecord) {
+	metrics.AuditEventsDeadLetteredTotal.WithLabelValues(record.Observer).Inc()
+
+	record.TS = time.Now().Unix()
+
+	if d.file == nil {
+		logger.Log.Error("audit event dropped",
+			zap.String("observer", record.Observer),
+			zap.Int("attempts", record.Attempts),
+			zap.String("error", record.Error),
+		)
+		return
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		logger.Log.Error("failed to encode dead letter record", zap.Error(err))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.file.Write(append(data, '\n')); err != nil {
+		logger.Log.Error("failed to write dead letter record", zap.String("observer", record.Observer), zap.Error(err))
+	}
+}
+
+// Close closes the dead letter file
+func (d *deadLetter) Close() error {
+	if d.file == nil {
+		return nil
+	}
+
+	return d.file.Close()
+}
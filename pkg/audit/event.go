@@ -1,9 +1,61 @@
 package audit
 
+import (
+	"context"
+	"time"
+)
+
+// SchemaVersion is the version of the event wire format described by event.schema.json
+const SchemaVersion = 2
+
+// Audit event actions
+const (
+	ActionShorten = "shorten"
+	ActionFollow  = "follow"
+	ActionBlock   = "block"
+	// ActionDeleteRequest is recorded when a user asks to delete a URL
+	ActionDeleteRequest = "delete_request"
+	// ActionDelete is recorded when a URL is actually deleted
+	ActionDelete = "delete"
+	// ActionUpdateRedirect is recorded when the redirect settings of a URL change
+	ActionUpdateRedirect = "update_redirect"
+)
+
+// Audit event outcomes
+const (
+	OutcomeSuccess  = "success"
+	OutcomeConflict = "conflict"
+	OutcomeDenied   = "denied"
+	OutcomeAccepted = "accepted"
+)
+
 // Event the event structure to record in audit
 type Event struct {
-	TS     int64  `json:"ts"`
-	Action string `json:"action"` // shorten | follow
-	UserID string `json:"user_id,omitempty"`
-	URL    string `json:"url"`
+	Version   int    `json:"version"`
+	TS        int64  `json:"ts"`
+	Action    string `json:"action"`  // shorten | follow | block | delete_request | delete | update_redirect
+	Outcome   string `json:"outcome"` // success | conflict | denied | accepted
+	UserID    string `json:"user_id,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Short     string `json:"short,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// NewEvent creates an event of the current schema version stamped with the current time
+// and the origin of the request stored in the context
+func NewEvent(ctx context.Context, action string, outcome string) Event {
+	origin := OriginFromContext(ctx)
+
+	return Event{
+		Version:   SchemaVersion,
+		TS:        time.Now().Unix(),
+		Action:    action,
+		Outcome:   outcome,
+		RequestID: origin.RequestID,
+		ClientIP:  origin.ClientIP,
+		UserAgent: origin.UserAgent,
+	}
 }
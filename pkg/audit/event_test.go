@@ -0,0 +1,207 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/requestid"
+)
+
+// update rewrites the golden files with the current wire format
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenEvents has an event of every action in the wire format of the current schema version
+var goldenEvents = []Event{
+	{
+		Version: SchemaVersion, TS: 1735689600, Action: ActionShorten, Outcome: OutcomeSuccess,
+		UserID: "user-1", URL: "https://yandex.ru", Short: "abcdefg",
+		RequestID: "req-1", ClientIP: "192.168.1.10", UserAgent: "curl/8.5.0",
+	},
+	{
+		Version: SchemaVersion, TS: 1735689601, Action: ActionShorten, Outcome: OutcomeConflict,
+		UserID: "user-1", URL: "https://yandex.ru", Short: "abcdefg",
+		RequestID: "req-2", ClientIP: "192.168.1.10",
+	},
+	{
+		Version: SchemaVersion, TS: 1735689602, Action: ActionFollow, Outcome: OutcomeSuccess,
+		UserID: "user-1", URL: "https://yandex.ru", Short: "abcdefg",
+		RequestID: "req-3", ClientIP: "10.0.0.7", UserAgent: "Mozilla/5.0",
+	},
+	{
+		Version: SchemaVersion, TS: 1735689603, Action: ActionBlock, Outcome: OutcomeDenied,
+		UserID: "user-2", URL: "http://127.0.0.1/admin", Reason: "private_host",
+		RequestID: "req-4", ClientIP: "10.0.0.8",
+	},
+	{
+		Version: SchemaVersion, TS: 1735689604, Action: ActionUpdateRedirect, Outcome: OutcomeSuccess,
+		UserID: "user-1", URL: "https://yandex.ru", Short: "abcdefg", RequestID: "req-5",
+	},
+	{
+		Version: SchemaVersion, TS: 1735689605, Action: ActionDeleteRequest, Outcome: OutcomeAccepted,
+		UserID: "user-1", Short: "abcdefg", RequestID: "req-6", ClientIP: "192.168.1.10",
+	},
+	{
+		Version: SchemaVersion, TS: 1735689606, Action: ActionDelete, Outcome: OutcomeSuccess,
+		UserID: "user-1", URL: "https://yandex.ru", Short: "abcdefg", RequestID: "req-6",
+	},
+}
+
+func TestEventWireFormat(t *testing.T) {
+	golden := filepath.Join("testdata", "events.golden.jsonl")
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range goldenEvents {
+		require.NoError(t, enc.Encode(event))
+	}
+
+	if *update {
+		require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0644))
+	}
+
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), buf.String(), "run go test ./pkg/audit -update after an intended wire format change")
+
+	var decoded []Event
+	scanner := bufio.NewScanner(bytes.NewReader(want))
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		decoded = append(decoded, event)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, goldenEvents, decoded)
+}
+
+func TestEventSchema(t *testing.T) {
+	var schema eventSchema
+	require.NoError(t, json.Unmarshal(Schema, &schema))
+
+	t.Run("Positive case: schema describes every event field", func(t *testing.T) {
+		var fields []string
+		eventType := reflect.TypeFor[Event]()
+		for i := range eventType.NumField() {
+			name, _, _ := strings.Cut(eventType.Field(i).Tag.Get("json"), ",")
+			fields = append(fields, name)
+		}
+
+		properties := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			properties = append(properties, name)
+		}
+
+		assert.ElementsMatch(t, fields, properties)
+		assert.Equal(t, float64(SchemaVersion), schema.Properties["version"].Const)
+	})
+
+	t.Run("Positive case: golden events match the schema", func(t *testing.T) {
+		f, err := os.Open(filepath.Join("testdata", "events.golden.jsonl"))
+		require.NoError(t, err)
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var event map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+			assert.NoError(t, schema.validate(event), scanner.Text())
+		}
+		require.NoError(t, scanner.Err())
+	})
+
+	t.Run("Negative case: unknown action violates the schema", func(t *testing.T) {
+		event := map[string]any{"version": float64(SchemaVersion), "ts": float64(1), "action": "rename", "outcome": "success"}
+		assert.Error(t, schema.validate(event))
+	})
+
+	t.Run("Negative case: missing outcome violates the schema", func(t *testing.T) {
+		event := map[string]any{"version": float64(SchemaVersion), "ts": float64(1), "action": "follow"}
+		assert.Error(t, schema.validate(event))
+	})
+}
+
+func TestNewEvent(t *testing.T) {
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	ctx = WithOrigin(ctx, Origin{ClientIP: "10.0.0.1", UserAgent: "curl/8.5.0"})
+
+	event := NewEvent(ctx, ActionFollow, OutcomeSuccess)
+
+	assert.Equal(t, SchemaVersion, event.Version)
+	assert.NotZero(t, event.TS)
+	assert.Equal(t, ActionFollow, event.Action)
+	assert.Equal(t, OutcomeSuccess, event.Outcome)
+	assert.Equal(t, "req-1", event.RequestID)
+	assert.Equal(t, "10.0.0.1", event.ClientIP)
+	assert.Equal(t, "curl/8.5.0", event.UserAgent)
+}
+
+// eventSchema is the subset of JSON schema keywords used by event.schema.json
+type eventSchema struct {
+	Required             []string                  `json:"required"`
+	AdditionalProperties bool                      `json:"additionalProperties"`
+	Properties           map[string]schemaProperty `json:"properties"`
+}
+
+// schemaProperty is the schema of a single event field
+type schemaProperty struct {
+	Type    string `json:"type"`
+	Enum    []any  `json:"enum"`
+	Const   any    `json:"const"`
+	Minimum *int64 `json:"minimum"`
+}
+
+// validate checks a decoded event against the schema
+func (s eventSchema) validate(event map[string]any) error {
+	for _, name := range s.Required {
+		if _, ok := event[name]; !ok {
+			return fmt.Errorf("missing required property %q", name)
+		}
+	}
+
+	for name, value := range event {
+		property, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties {
+				continue
+			}
+			return fmt.Errorf("unknown property %q", name)
+		}
+
+		switch property.Type {
+		case "string":
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("property %q is not a string", name)
+			}
+		case "integer":
+			number, ok := value.(float64)
+			if !ok || number != float64(int64(number)) {
+				return fmt.Errorf("property %q is not an integer", name)
+			}
+			if property.Minimum != nil && number < float64(*property.Minimum) {
+				return fmt.Errorf("property %q is below %d", name, *property.Minimum)
+			}
+		}
+
+		if property.Enum != nil && !slices.Contains(property.Enum, value) {
+			return fmt.Errorf("property %q is not one of %v", name, property.Enum)
+		}
+		if property.Const != nil && property.Const != value {
+			return fmt.Errorf("property %q is not %v", name, property.Const)
+		}
+	}
+
+	return nil
+}
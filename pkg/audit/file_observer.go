@@ -1,36 +1,378 @@
 package audit
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
 )
 
+// rotatedTimeFormat is the timestamp layout inserted into the names of rotated files
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// FileObserverOptions configures rotation and retention of the audit log file
+type FileObserverOptions struct {
+	// MaxSize rotates the file before it grows beyond this many bytes, disabled when zero
+	MaxSize int64
+	// RotateEvery rotates the file once it has been written for this long, disabled when zero
+	RotateEvery time.Duration
+	// MaxBackups is the number of rotated files to keep, all are kept when zero
+	MaxBackups int
+	// MaxAge removes rotated files older than this, disabled when zero
+	MaxAge time.Duration
+	// Compress gzips rotated files
+	Compress bool
+}
+
 // FileObserver structure to observe audit events and writes them to the file
+// The file is rotated by size and time and reopened by Reopen when it is moved by an external tool
 type FileObserver struct {
+	path    string
+	options FileObserverOptions
+	now     func() time.Time
+
+	mu   sync.Mutex
 	file *os.File
+	info os.FileInfo
+	size int64
+	// startedAt is when the current file started being written, RotateEvery counts from it
+	startedAt time.Time
+
+	// wg tracks compression and cleanup of rotated files, cleanupMu runs them one at a time
+	wg        sync.WaitGroup
+	cleanupMu sync.Mutex
 }
 
 // NewFileObserver creates new file observer
-func NewFileObserver(path string) (*FileObserver, error) {
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
+func NewFileObserver(path string, options FileObserverOptions) (*FileObserver, error) {
+	if options.MaxSize < 0 || options.RotateEvery < 0 || options.MaxBackups < 0 || options.MaxAge < 0 {
+		return nil, errors.New("audit file rotation options must not be negative")
+	}
+
+	f := &FileObserver{
+		path:    path,
+		options: options,
+		now:     time.Now,
+	}
+
+	if err := f.open(); err != nil {
 		return nil, err
 	}
 
-	return &FileObserver{file: f}, nil
+	return f, nil
 }
 
 // Send writes the event log to the file
 func (f *FileObserver) Send(event Event) error {
 	data, err := json.Marshal(event)
 	if err != nil {
-		return err
+		return Permanent(err)
+	}
+	data = append(data, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return errors.New("audit file is closed")
 	}
 
-	_, err = f.file.Write(append(data, '\n'))
+	if f.shouldRotate(int64(len(data))) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
 	return err
 }
 
+// Reopen closes the file and opens it again at the same path
+// It is called on SIGHUP after the file was moved by logrotate
+func (f *FileObserver) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return errors.New("audit file is closed")
+	}
+
+	if err := f.file.Close(); err != nil {
+		logger.Log.Warn("failed to close audit file before reopening", zap.Error(err))
+	}
+
+	return f.open()
+}
+
+// Close closes the file and waits for rotated files to be compressed
 func (f *FileObserver) Close() error {
-	return f.file.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.wg.Wait()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the file for appending and records its current size and when it started being written
+// Reopening the same file keeps its start, so that neither restarts nor SIGHUP postpone time rotation
+func (f *FileObserver) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return errors.Join(err, file.Close())
+	}
+
+	switch {
+	case info.Size() == 0:
+		f.startedAt = f.now()
+	case f.info == nil || !os.SameFile(f.info, info):
+		f.startedAt = f.firstWriteTime(info)
+	}
+
+	f.file = file
+	f.info = info
+	f.size = info.Size()
+
+	return nil
+}
+
+// firstWriteTime returns when the existing file started being written, the time of its first event
+// The modification time is used when the first event cannot be read
+func (f *FileObserver) firstWriteTime(info os.FileInfo) time.Time {
+	startedAt := info.ModTime()
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return startedAt
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return startedAt
+	}
+
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil || event.TS <= 0 {
+		return startedAt
+	}
+
+	if ts := time.Unix(event.TS, 0); ts.Before(startedAt) {
+		return ts
+	}
+
+	return startedAt
+}
+
+// shouldRotate reports whether the file must be rotated before writing n more bytes
+// A file is never rotated while empty so one event larger than MaxSize is still written
+func (f *FileObserver) shouldRotate(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+
+	if f.options.MaxSize > 0 && f.size+n > f.options.MaxSize {
+		return true
+	}
+
+	return f.options.RotateEvery > 0 && f.now().Sub(f.startedAt) >= f.options.RotateEvery
+}
+
+// rotate moves the current file aside, opens a new one and processes rotated files in the background
+func (f *FileObserver) rotate() error {
+	if err := f.file.Close(); err != nil {
+		logger.Log.Warn("failed to close audit file before rotation", zap.Error(err))
+	}
+
+	now := f.now()
+	rotated := f.rotatedName(now)
+	if err := os.Rename(f.path, rotated); err != nil {
+		return errors.Join(err, f.open())
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.processRotated(rotated, now)
+	}()
+
+	return nil
+}
+
+// rotatedName returns an unused name of the file rotated at the time
+// The timestamp is inserted before the extension, audit.log becomes audit-<timestamp>.log
+func (f *FileObserver) rotatedName(t time.Time) string {
+	dir, prefix, ext := splitName(f.path)
+
+	for {
+		name := filepath.Join(dir, prefix+t.UTC().Format(rotatedTimeFormat)+ext)
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// processRotated compresses a rotated file when enabled and removes rotated files beyond retention at the time
+func (f *FileObserver) processRotated(rotated string, now time.Time) {
+	f.cleanupMu.Lock()
+	defer f.cleanupMu.Unlock()
+
+	if f.options.Compress {
+		if err := compressFile(rotated); err != nil {
+			logger.Log.Error("failed to compress rotated audit file", zap.String("file", rotated), zap.Error(err))
+		}
+	}
+
+	if err := f.removeExpired(now); err != nil {
+		logger.Log.Error("failed to remove expired audit files", zap.Error(err))
+	}
+}
+
+// removeExpired removes the rotated files beyond MaxBackups or older than MaxAge at the time
+func (f *FileObserver) removeExpired(now time.Time) error {
+	if f.options.MaxBackups == 0 && f.options.MaxAge == 0 {
+		return nil
+	}
+
+	rotated, err := RotatedFiles(f.path)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	cutoff := now.Add(-f.options.MaxAge)
+
+	for i, file := range rotated {
+		expired := f.options.MaxBackups > 0 && len(rotated)-i > f.options.MaxBackups
+		if f.options.MaxAge > 0 && file.RotatedAt.Before(cutoff) {
+			expired = true
+		}
+
+		if !expired {
+			continue
+		}
+		if err := os.Remove(file.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RotatedFile is a file moved aside by rotation of the audit log
+type RotatedFile struct {
+	Path      string
+	RotatedAt time.Time
+}
+
+// RotatedFiles returns the rotated files of the audit log at path from the oldest to the newest
+func RotatedFiles(path string) ([]RotatedFile, error) {
+	dir, prefix, ext := splitName(path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []RotatedFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".gz")
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		rotatedAt, err := time.Parse(rotatedTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, RotatedFile{Path: filepath.Join(dir, entry.Name()), RotatedAt: rotatedAt})
+	}
+
+	slices.SortStableFunc(files, func(a, b RotatedFile) int {
+		return a.RotatedAt.Compare(b.RotatedAt)
+	})
+
+	return files, nil
+}
+
+// splitName splits the path into its directory, rotated name prefix and extension
+func splitName(path string) (string, string, string) {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// compressFile replaces the file with its gzip compressed copy
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(path + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		return errors.Join(err, gz.Close(), dst.Close())
+	}
+	if err := gz.Close(); err != nil {
+		return errors.Join(err, dst.Close())
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// exists reports whether a file exists at the path
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
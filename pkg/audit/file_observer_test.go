@@ -0,0 +1,238 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock of a FileObserver
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestFileObserverRotation(t *testing.T) {
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	event := Event{TS: 1, Action: ActionShorten, URL: "https://yandex.ru"}
+	size := eventSize(t, event)
+
+	tests := []struct {
+		name        string
+		options     FileObserverOptions
+		events      int
+		step        time.Duration
+		wantRotated []string
+		wantCurrent int
+	}{
+		{
+			name:        "Positive case: file is not rotated without limits",
+			events:      3,
+			wantCurrent: 3,
+		},
+		{
+			name:        "Positive case: rotated by size",
+			options:     FileObserverOptions{MaxSize: 2 * size},
+			events:      5,
+			step:        time.Second,
+			wantRotated: []string{"audit-2026-10-17T12-00-02.000.log", "audit-2026-10-17T12-00-04.000.log"},
+			wantCurrent: 1,
+		},
+		{
+			name:        "Positive case: rotated by time",
+			options:     FileObserverOptions{RotateEvery: time.Hour},
+			events:      3,
+			step:        40 * time.Minute,
+			wantRotated: []string{"audit-2026-10-17T13-20-00.000.log"},
+			wantCurrent: 1,
+		},
+		{
+			name:        "Positive case: old backups are removed and rotated files compressed",
+			options:     FileObserverOptions{MaxSize: size, MaxBackups: 2, Compress: true},
+			events:      4,
+			step:        time.Second,
+			wantRotated: []string{"audit-2026-10-17T12-00-02.000.log.gz", "audit-2026-10-17T12-00-03.000.log.gz"},
+			wantCurrent: 1,
+		},
+		{
+			name:        "Positive case: expired backups are removed",
+			options:     FileObserverOptions{MaxSize: size, MaxAge: 90 * time.Minute},
+			events:      4,
+			step:        time.Hour,
+			wantRotated: []string{"audit-2026-10-17T14-00-00.000.log", "audit-2026-10-17T15-00-00.000.log"},
+			wantCurrent: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			clock := &fakeClock{now: start}
+
+			observer := newTestFileObserver(t, path, tt.options, clock)
+			for range tt.events {
+				require.NoError(t, observer.Send(event))
+				clock.now = clock.now.Add(tt.step)
+			}
+			require.NoError(t, observer.Close())
+
+			rotated, err := RotatedFiles(path)
+			require.NoError(t, err)
+
+			var names []string
+			for _, file := range rotated {
+				names = append(names, filepath.Base(file.Path))
+				assert.NotEmpty(t, readAuditFile(t, file.Path))
+			}
+			assert.Equal(t, tt.wantRotated, names)
+			assert.Len(t, readAuditFile(t, path), tt.wantCurrent)
+		})
+	}
+}
+
+func TestFileObserverReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	moved := filepath.Join(dir, "audit.log.1")
+
+	observer, err := NewFileObserver(path, FileObserverOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, observer.Send(Event{TS: 1, Action: ActionShorten}))
+	require.NoError(t, os.Rename(path, moved))
+	require.NoError(t, observer.Send(Event{TS: 2, Action: ActionShorten}))
+
+	require.NoError(t, observer.Reopen())
+	require.NoError(t, observer.Send(Event{TS: 3, Action: ActionShorten}))
+	require.NoError(t, observer.Close())
+
+	assert.Equal(t, []int64{1, 2}, eventTimestamps(readAuditFile(t, moved)))
+	assert.Equal(t, []int64{3}, eventTimestamps(readAuditFile(t, path)))
+
+	assert.Error(t, observer.Send(Event{TS: 4}))
+	assert.Error(t, observer.Reopen())
+}
+
+func TestFileObserverKeepsRotationStart(t *testing.T) {
+	// The clock is behind the modification time of the file, so the start is read from the first event
+	start := time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+	options := FileObserverOptions{RotateEvery: time.Hour}
+
+	tests := []struct {
+		name    string
+		restart func(t *testing.T, observer *FileObserver, path string, clock *fakeClock) *FileObserver
+	}{
+		{
+			name: "Positive case: reopened on SIGHUP",
+			restart: func(t *testing.T, observer *FileObserver, _ string, _ *fakeClock) *FileObserver {
+				require.NoError(t, observer.Reopen())
+				return observer
+			},
+		},
+		{
+			name: "Positive case: restarted",
+			restart: func(t *testing.T, observer *FileObserver, path string, clock *fakeClock) *FileObserver {
+				require.NoError(t, observer.Close())
+				return newTestFileObserver(t, path, options, clock)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			clock := &fakeClock{now: start}
+
+			observer := newTestFileObserver(t, path, options, clock)
+			require.NoError(t, observer.Send(Event{TS: clock.now.Unix(), Action: ActionShorten}))
+
+			clock.now = start.Add(40 * time.Minute)
+			observer = tt.restart(t, observer, path, clock)
+			require.NoError(t, observer.Send(Event{TS: clock.now.Unix(), Action: ActionFollow}))
+
+			clock.now = start.Add(70 * time.Minute)
+			require.NoError(t, observer.Send(Event{TS: clock.now.Unix(), Action: ActionFollow}))
+			require.NoError(t, observer.Close())
+
+			rotated, err := RotatedFiles(path)
+			require.NoError(t, err)
+			require.Len(t, rotated, 1)
+			assert.Len(t, readAuditFile(t, rotated[0].Path), 2)
+			assert.Len(t, readAuditFile(t, path), 1)
+		})
+	}
+}
+
+func TestNewFileObserverOptions(t *testing.T) {
+	_, err := NewFileObserver(filepath.Join(t.TempDir(), "audit.log"), FileObserverOptions{MaxSize: -1})
+	assert.Error(t, err)
+}
+
+// newTestFileObserver creates a file observer reading the time from the clock
+func newTestFileObserver(t *testing.T, path string, options FileObserverOptions, clock *fakeClock) *FileObserver {
+	t.Helper()
+
+	observer := &FileObserver{path: path, options: options, now: clock.Now}
+	require.NoError(t, observer.open())
+
+	return observer
+}
+
+// eventSize returns the number of bytes the event takes in the audit file
+func eventSize(t *testing.T, event Event) int64 {
+	t.Helper()
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	return int64(len(data)) + 1
+}
+
+// readAuditFile reads the events of an audit file, decompressing it when gzipped
+func readAuditFile(t *testing.T, path string) []Event {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		r = gz
+	}
+
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+
+	return events
+}
+
+// eventTimestamps returns the timestamps of the events
+func eventTimestamps(events []Event) []int64 {
+	var timestamps []int64
+	for _, event := range events {
+		timestamps = append(timestamps, event.TS)
+	}
+
+	return timestamps
+}
@@ -5,32 +5,106 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/compress"
+)
+
+// Payload formats of batched audit events
+const (
+	// FormatJSON sends a batch as a JSON array
+	FormatJSON = "json"
+	// FormatNDJSON sends a batch as newline-delimited JSON
+	FormatNDJSON = "ndjson"
 )
 
+// HTTPObserverOptions configures delivery of audit events to the audit service
+type HTTPObserverOptions struct {
+	// BatchSize is the maximum number of events per request, events are sent one by one as JSON objects when it is at most one
+	BatchSize int
+	// FlushInterval is how long a batch may wait to fill up
+	FlushInterval time.Duration
+	// Format is the payload format of batches, FormatJSON or FormatNDJSON
+	Format string
+	// Gzip compresses request bodies
+	Gzip bool
+	// SigningKey signs requests with HMAC-SHA256, requests are not signed when empty
+	SigningKey string
+}
+
+// DefaultHTTPObserverOptions returns options sending every event in its own unsigned request
+func DefaultHTTPObserverOptions() HTTPObserverOptions {
+	return HTTPObserverOptions{
+		BatchSize:     1,
+		FlushInterval: time.Second,
+		Format:        FormatJSON,
+	}
+}
+
 // HTTPObserver structure to observe audit events and sends them to audit service
 type HTTPObserver struct {
-	client *http.Client
-	url    string
+	client    *http.Client
+	url       string
+	options   HTTPObserverOptions
+	namespace uuid.UUID
 }
 
 // NewHTTPObserver creates a new HTTPObserver
-func NewHTTPObserver(url string) *HTTPObserver {
-	return &HTTPObserver{
-		client: &http.Client{Timeout: 5 * time.Second},
-		url:    url,
+func NewHTTPObserver(url string, options HTTPObserverOptions) (*HTTPObserver, error) {
+	if options.Format != FormatJSON && options.Format != FormatNDJSON {
+		return nil, fmt.Errorf("unknown audit payload format %q", options.Format)
 	}
+	if options.BatchSize > 1 && options.FlushInterval <= 0 {
+		return nil, errors.New("audit flush interval must be positive")
+	}
+
+	return &HTTPObserver{
+		client:    &http.Client{Timeout: 5 * time.Second},
+		url:       url,
+		options:   options,
+		namespace: uuid.NewSHA1(uuid.NameSpaceURL, []byte(url)),
+	}, nil
 }
 
 // Send sends event logs to audit service
 func (h *HTTPObserver) Send(event Event) error {
-	data, err := json.Marshal(event)
+	return h.SendBatch([]Event{event})
+}
+
+// SendBatch sends the events to audit service in one request
+// The delivery ID is a name-based UUID of the request body, so every retry of the batch,
+// including redeliveries of spooled events after a restart, carries the same ID
+// Rejections other than timeouts and rate limiting are permanent errors
+func (h *HTTPObserver) SendBatch(events []Event) (err error) {
+	body, err := h.encode(events)
 	if err != nil {
-		return err
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
 	}
 
-	resp, err := h.client.Post(h.url, "application/json", bytes.NewBuffer(data))
+	deliveryID := uuid.NewSHA1(h.namespace, body).String()
+
+	req.Header.Set("Content-Type", h.contentType())
+	req.Header.Set(DeliveryIDHeader, deliveryID)
+	if h.options.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if h.options.SigningKey != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, Sign([]byte(h.options.SigningKey), timestamp, deliveryID, body))
+	}
+
+	resp, err := h.client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -39,8 +113,80 @@ func (h *HTTPObserver) Send(event Event) error {
 	}()
 
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("bad status: %d", resp.StatusCode)
+		err = fmt.Errorf("bad status: %d", resp.StatusCode)
+		if isPermanentStatus(resp.StatusCode) {
+			err = Permanent(err)
+		}
+		return err
 	}
 
 	return nil
 }
+
+// Batching returns the configured batch size and flush interval
+func (h *HTTPObserver) Batching() (int, time.Duration) {
+	return h.options.BatchSize, h.options.FlushInterval
+}
+
+// encode builds the request body of the events, compressed when gzip is enabled
+func (h *HTTPObserver) encode(events []Event) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if !h.options.Gzip {
+		if err := h.writePayload(&buf, events); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	gz := compress.AcquireGzipWriter(&buf)
+	defer compress.ReleaseGzipWriter(gz)
+
+	if err := h.writePayload(gz.W, events); err != nil {
+		return nil, err
+	}
+	if err := gz.W.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writePayload writes the events in the configured format
+func (h *HTTPObserver) writePayload(w io.Writer, events []Event) error {
+	enc := json.NewEncoder(w)
+
+	switch {
+	case h.options.BatchSize <= 1 && len(events) == 1:
+		return enc.Encode(events[0])
+
+	case h.options.Format == FormatNDJSON:
+		for _, event := range events {
+			if err := enc.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return enc.Encode(events)
+	}
+}
+
+// contentType returns the media type of the request body
+func (h *HTTPObserver) contentType() string {
+	if h.options.BatchSize > 1 && h.options.Format == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+
+	return "application/json"
+}
+
+// isPermanentStatus reports whether retrying a request rejected with the status cannot succeed
+func isPermanentStatus(status int) bool {
+	if status == http.StatusRequestTimeout || status == http.StatusTooManyRequests {
+		return false
+	}
+
+	return status >= 400 && status < 500
+}
@@ -0,0 +1,346 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receivedRequest is a request recorded by the test audit server
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// auditServer records the requests of an HTTPObserver
+type auditServer struct {
+	mu       sync.Mutex
+	requests []receivedRequest
+	status   int
+}
+
+func (s *auditServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, receivedRequest{header: r.Header.Clone(), body: body})
+	status := s.status
+	s.mu.Unlock()
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+}
+
+func (s *auditServer) received() []receivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]receivedRequest(nil), s.requests...)
+}
+
+func TestHTTPObserverBatching(t *testing.T) {
+	const signingKey = "audit_key"
+
+	tests := []struct {
+		name        string
+		options     HTTPObserverOptions
+		events      int
+		wantBatches []int
+		wantType    string
+	}{
+		{
+			name:        "Positive case: one event per request",
+			options:     DefaultHTTPObserverOptions(),
+			events:      2,
+			wantBatches: []int{1, 1},
+			wantType:    "application/json",
+		},
+		{
+			name:        "Positive case: JSON array batches flushed by size and time",
+			options:     HTTPObserverOptions{BatchSize: 3, FlushInterval: 50 * time.Millisecond, Format: FormatJSON},
+			events:      4,
+			wantBatches: []int{3, 1},
+			wantType:    "application/json",
+		},
+		{
+			name:        "Positive case: gzipped and signed NDJSON batches",
+			options:     HTTPObserverOptions{BatchSize: 2, FlushInterval: 50 * time.Millisecond, Format: FormatNDJSON, Gzip: true, SigningKey: signingKey},
+			events:      3,
+			wantBatches: []int{2, 1},
+			wantType:    "application/x-ndjson",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &auditServer{}
+			srv := httptest.NewServer(server)
+			defer srv.Close()
+
+			observer, err := NewHTTPObserver(srv.URL, tt.options)
+			require.NoError(t, err)
+
+			svc, err := NewService(context.Background(), DefaultOptions())
+			require.NoError(t, err)
+			require.NoError(t, svc.Register(observer))
+
+			for i := range tt.events {
+				svc.Notify(Event{TS: int64(i), Action: ActionShorten, URL: "https://yandex.ru/" + strconv.Itoa(i)})
+			}
+
+			require.Eventually(t, func() bool { return len(server.received()) == len(tt.wantBatches) }, time.Second, time.Millisecond)
+			require.NoError(t, svc.Close())
+
+			var wantTS int64
+			for i, request := range server.received() {
+				assert.Equal(t, tt.wantType, request.header.Get("Content-Type"))
+
+				body := request.body
+				if tt.options.SigningKey != "" {
+					err := VerifySignature([]byte(signingKey), request.header.Get(TimestampHeader), request.header.Get(DeliveryIDHeader), request.header.Get(SignatureHeader), body, time.Now(), time.Minute)
+					require.NoError(t, err)
+				} else {
+					assert.Empty(t, request.header.Get(SignatureHeader))
+				}
+
+				if tt.options.Gzip {
+					assert.Equal(t, "gzip", request.header.Get("Content-Encoding"))
+					body = gunzip(t, body)
+				}
+
+				events := decodePayload(t, tt.options, body)
+				require.Len(t, events, tt.wantBatches[i])
+				for _, event := range events {
+					assert.Equal(t, wantTS, event.TS)
+					wantTS++
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPObserverPermanentRejection(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantDead bool
+	}{
+		{name: "Negative case: bad request is dead lettered", status: http.StatusBadRequest, wantDead: true},
+		{name: "Negative case: rate limiting is retried", status: http.StatusTooManyRequests},
+		{name: "Negative case: server error is retried", status: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &auditServer{status: tt.status}
+			srv := httptest.NewServer(server)
+			defer srv.Close()
+
+			observer, err := NewHTTPObserver(srv.URL, DefaultHTTPObserverOptions())
+			require.NoError(t, err)
+
+			err = observer.Send(Event{TS: 1, Action: ActionFollow})
+			require.Error(t, err)
+			assert.Equal(t, tt.wantDead, IsPermanent(err))
+		})
+	}
+}
+
+func TestNewHTTPObserverOptions(t *testing.T) {
+	_, err := NewHTTPObserver("http://localhost", HTTPObserverOptions{BatchSize: 1, Format: "xml"})
+	assert.Error(t, err)
+
+	_, err = NewHTTPObserver("http://localhost", HTTPObserverOptions{BatchSize: 10, Format: FormatJSON})
+	assert.Error(t, err)
+}
+
+func TestHTTPObserverDeliveryID(t *testing.T) {
+	const signingKey = "audit_key"
+
+	var (
+		mu       sync.Mutex
+		requests []http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, r.Header.Clone())
+		// The first attempt of every batch fails
+		if len(requests)%2 == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	observer, err := NewHTTPObserver(srv.URL, HTTPObserverOptions{BatchSize: 2, FlushInterval: 10 * time.Millisecond, Format: FormatJSON, SigningKey: signingKey})
+	require.NoError(t, err)
+
+	options := DefaultOptions()
+	options.Retry = fastRetry
+	svc, err := NewService(context.Background(), options)
+	require.NoError(t, err)
+	require.NoError(t, svc.Register(observer))
+
+	svc.Notify(Event{TS: 1, Action: ActionShorten})
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(requests) == 2
+	}, time.Second, time.Millisecond)
+
+	svc.Notify(Event{TS: 2, Action: ActionShorten})
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(requests) == 4
+	}, time.Second, time.Millisecond)
+	require.NoError(t, svc.Close())
+
+	for _, header := range requests {
+		_, err := uuid.Parse(header.Get(DeliveryIDHeader))
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, requests[0].Get(DeliveryIDHeader), requests[1].Get(DeliveryIDHeader))
+	assert.Equal(t, requests[2].Get(DeliveryIDHeader), requests[3].Get(DeliveryIDHeader))
+	assert.NotEqual(t, requests[0].Get(DeliveryIDHeader), requests[2].Get(DeliveryIDHeader))
+}
+
+func TestVerifySignature(t *testing.T) {
+	const deliveryID = "6f1c1f6e-8f5a-5b61-9f0a-3c2d6b7e8a90"
+
+	key := []byte("audit_key")
+	body := []byte(`{"ts":1}`)
+	now := time.Unix(1700000000, 0)
+	signature := Sign(key, now.Unix(), deliveryID, body)
+
+	tests := []struct {
+		name       string
+		key        []byte
+		timestamp  string
+		deliveryID string
+		signature  string
+		body       []byte
+		wantErr    error
+	}{
+		{name: "Positive case: valid signature", key: key, timestamp: "1700000000", deliveryID: deliveryID, signature: signature, body: body},
+		{name: "Negative case: tampered body", key: key, timestamp: "1700000000", deliveryID: deliveryID, signature: signature, body: []byte(`{"ts":2}`), wantErr: ErrInvalidSignature},
+		{name: "Negative case: tampered delivery ID", key: key, timestamp: "1700000000", deliveryID: "other", signature: signature, body: body, wantErr: ErrInvalidSignature},
+		{name: "Negative case: wrong key", key: []byte("other"), timestamp: "1700000000", deliveryID: deliveryID, signature: signature, body: body, wantErr: ErrInvalidSignature},
+		{name: "Negative case: replayed timestamp", key: key, timestamp: "1699999000", deliveryID: deliveryID, signature: Sign(key, 1699999000, deliveryID, body), body: body, wantErr: ErrStaleTimestamp},
+		{name: "Negative case: malformed timestamp", key: key, timestamp: "yesterday", deliveryID: deliveryID, signature: signature, body: body, wantErr: ErrStaleTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.key, tt.timestamp, tt.deliveryID, tt.signature, tt.body, now, 5*time.Minute)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestReplayGuard(t *testing.T) {
+	const window = 5 * time.Minute
+
+	now := time.Unix(1700000000, 0)
+	guard := NewReplayGuard(window)
+
+	require.NoError(t, guard.Accept("first", now))
+	assert.ErrorIs(t, guard.Accept("first", now.Add(window-time.Second)), ErrReplayedDelivery)
+	require.NoError(t, guard.Accept("second", now.Add(time.Second)))
+
+	// A batch the receiver failed to store is accepted when it is retried
+	guard.Forget("second")
+	require.NoError(t, guard.Accept("second", now.Add(2*time.Second)))
+
+	// Delivery IDs are forgotten once their signatures are stale
+	require.NoError(t, guard.Accept("first", now.Add(window)))
+	assert.ErrorIs(t, guard.Accept("first", now.Add(window+time.Second)), ErrReplayedDelivery)
+}
+
+func TestHTTPObserverDeadLetterWithService(t *testing.T) {
+	server := &auditServer{status: http.StatusUnprocessableEntity}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	observer, err := NewHTTPObserver(srv.URL, HTTPObserverOptions{BatchSize: 2, FlushInterval: 10 * time.Millisecond, Format: FormatJSON})
+	require.NoError(t, err)
+
+	deadLetterFile := filepath.Join(t.TempDir(), "dead.jsonl")
+	options := DefaultOptions()
+	options.DeadLetterFile = deadLetterFile
+	options.Retry = fastRetry
+
+	svc, err := NewService(context.Background(), options)
+	require.NoError(t, err)
+	require.NoError(t, svc.Register(observer))
+
+	svc.Notify(Event{TS: 1, Action: ActionShorten})
+	svc.Notify(Event{TS: 2, Action: ActionShorten})
+
+	require.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, svc.Close())
+
+	records := readDeadLetters(t, deadLetterFile)
+	require.Len(t, records, 2)
+	assert.Equal(t, int64(1), records[0].Event.TS)
+	assert.Equal(t, int64(2), records[1].Event.TS)
+}
+
+// gunzip decompresses a gzip request body
+func gunzip(t *testing.T, body []byte) []byte {
+	t.Helper()
+
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return data
+}
+
+// decodePayload decodes the events of a request body in the format of the options
+func decodePayload(t *testing.T, options HTTPObserverOptions, body []byte) []Event {
+	t.Helper()
+
+	if options.BatchSize <= 1 {
+		var event Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		return []Event{event}
+	}
+
+	if options.Format == FormatJSON {
+		var events []Event
+		require.NoError(t, json.Unmarshal(body, &events))
+		return events
+	}
+
+	var events []Event
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+
+	return events
+}
@@ -1,7 +1,22 @@
 package audit
 
+import (
+	"time"
+)
+
 // Observer defines a receiver of audit events
 type Observer interface {
 	// Send delivers an audit event
 	Send(event Event) error
 }
+
+// BatchObserver defines a receiver delivering several audit events at once
+type BatchObserver interface {
+	Observer
+
+	// SendBatch delivers the audit events in order
+	SendBatch(events []Event) error
+
+	// Batching returns the maximum batch size and how long a batch may wait to fill up
+	Batching() (size int, interval time.Duration)
+}
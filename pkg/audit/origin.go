@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/requestid"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+// Origin describes the request that caused an audit event
+type Origin struct {
+	RequestID string
+	ClientIP  string
+	UserAgent string
+}
+
+// originKey is the context key of the request origin
+type originKey struct{}
+
+// WithOrigin returns a copy of the context carrying the request origin
+func WithOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// OriginFromContext returns the request origin stored in the context
+// The request ID is taken from the request ID of the context when the origin has none
+func OriginFromContext(ctx context.Context) Origin {
+	origin, _ := ctx.Value(originKey{}).(Origin)
+	if origin.RequestID == "" {
+		origin.RequestID = requestid.FromContext(ctx)
+	}
+
+	return origin
+}
+
+// OriginMiddleware stores the client address and user agent of every request for audit events
+func OriginMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := WithOrigin(r.Context(), Origin{
+				ClientIP:  subnet.ClientIP(r),
+				UserAgent: r.UserAgent(),
+			})
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
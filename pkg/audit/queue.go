@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// errQueueFull is returned when an in-memory queue has no free slots
+	errQueueFull = errors.New("audit queue is full")
+	// errQueueEmpty is returned by next when the wait channel fires before an event is available
+	errQueueEmpty = errors.New("audit queue is empty")
+	// errQueueClosed is returned by next once the queue is shut down
+	errQueueClosed = errors.New("audit queue is shut down")
+)
+
+// queue buffers the events of one observer until they are delivered
+type queue interface {
+	// push appends an event to the queue
+	push(event Event) error
+
+	// next returns the oldest event that is not returned yet
+	// It blocks until an event is available, the wait channel fires or the queue is shut down
+	next(wait <-chan time.Time) (Event, error)
+
+	// ack removes the events returned by next
+	ack() error
+
+	// durable reports whether queued events survive a restart
+	durable() bool
+
+	// shutdown wakes up next, durable queues stop returning events at once and others once drained
+	shutdown()
+
+	// Close releases the resources of the queue
+	Close() error
+}
+
+// memoryQueue is a bounded in-memory queue, events are lost on restart
+// Events that are still queued on shutdown are drained by next
+type memoryQueue struct {
+	ch chan Event
+}
+
+// newMemoryQueue creates an in-memory queue holding up to size events
+func newMemoryQueue(size int) *memoryQueue {
+	return &memoryQueue{ch: make(chan Event, size)}
+}
+
+// push appends the event or fails when the queue is full
+func (q *memoryQueue) push(event Event) error {
+	select {
+	case q.ch <- event:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+// next returns the oldest event
+func (q *memoryQueue) next(wait <-chan time.Time) (Event, error) {
+	select {
+	case event, ok := <-q.ch:
+		if !ok {
+			return Event{}, errQueueClosed
+		}
+		return event, nil
+	case <-wait:
+		return Event{}, errQueueEmpty
+	}
+}
+
+// ack does nothing as next has already removed the event
+func (q *memoryQueue) ack() error {
+	return nil
+}
+
+// durable reports false as events are kept in memory only
+func (q *memoryQueue) durable() bool {
+	return false
+}
+
+// shutdown closes the channel so next returns once it is drained
+func (q *memoryQueue) shutdown() {
+	close(q.ch)
+}
+
+// Close does nothing
+func (q *memoryQueue) Close() error {
+	return nil
+}
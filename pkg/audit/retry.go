@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy configures redelivery of failed audit events with exponential backoff
+type RetryPolicy struct {
+	// MaxAttempts limits delivery attempts of an event, unlimited when zero
+	MaxAttempts int
+	// InitialInterval is the delay after the first failed attempt
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between attempts
+	MaxInterval time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     10,
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     time.Minute,
+	}
+}
+
+// backoff returns the delay before the attempt following the given one
+// Half of the exponential delay is random so observers do not retry in lockstep
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.InitialInterval <= 0 {
+		return 0
+	}
+
+	delay := p.MaxInterval
+	if shift := attempt - 1; shift < 32 {
+		if exponential := p.InitialInterval << shift; exponential > 0 && (delay <= 0 || exponential < delay) {
+			delay = exponential
+		}
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// PermanentError marks an observer error that redelivery cannot fix
+type PermanentError struct {
+	Err error
+}
+
+// Permanent wraps the error so the event is moved to the dead letter file without retries
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// Error returns the message of the wrapped error
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether the error or any error it wraps is permanent
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
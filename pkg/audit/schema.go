@@ -0,0 +1,10 @@
+package audit
+
+import (
+	_ "embed"
+)
+
+// Schema is the JSON schema of the event wire format
+//
+//go:embed event.schema.json
+var Schema []byte
@@ -2,85 +2,324 @@ package audit
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"reflect"
 	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/metrics"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
 )
 
-// Service implements Publisher and dispatches events to registered observers
+// Options configures delivery of audit events
+type Options struct {
+	// Buffer is the number of events queued in memory per observer when there is no spool
+	Buffer int
+	// SpoolDir keeps undelivered events on disk across restarts, events are queued in memory when empty
+	SpoolDir string
+	// DeadLetterFile receives events that could not be delivered, they are only logged when empty
+	DeadLetterFile string
+	// Retry configures redelivery of failed events
+	Retry RetryPolicy
+}
+
+// DefaultOptions returns in-memory delivery options with the default retry policy
+func DefaultOptions() Options {
+	return Options{
+		Buffer: 100,
+		Retry:  DefaultRetryPolicy(),
+	}
+}
+
+// Service implements Publisher and delivers events to every registered observer
+// Each observer has its own queue and goroutine so a slow observer does not delay the others
 type Service struct {
-	observers []Observer
-	ch        chan Event
-	wg        sync.WaitGroup
-	closeOnce sync.Once
-	ctx       context.Context
+	options    Options
+	deadLetter *deadLetter
+	mu         sync.RWMutex
+	workers    []*observerWorker
+	names      map[string]int
+	closed     bool
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
-// NewService creates a new audit service with a buffered channel
-func NewService(ctx context.Context, buffer int) *Service {
-	s := &Service{
-		ch:  make(chan Event, buffer),
-		ctx: ctx,
+// NewService creates a new audit service
+// Cancelling the context stops retries, queued events are then handled as on Close
+func NewService(ctx context.Context, options Options) (*Service, error) {
+	deadLetter, err := openDeadLetter(options.DeadLetterFile)
+	if err != nil {
+		return nil, err
 	}
 
-	s.wg.Add(1)
-	go s.worker()
+	ctx, cancel := context.WithCancel(ctx)
 
-	return s
+	return &Service{
+		options:    options,
+		deadLetter: deadLetter,
+		names:      make(map[string]int),
+		ctx:        ctx,
+		cancel:     cancel,
+	}, nil
 }
 
-// Register adds an observer to receive audit events
-func (s *Service) Register(o Observer) {
-	s.observers = append(s.observers, o)
+// Register adds an observer to receive audit events and starts its delivery goroutine
+func (s *Service) Register(o Observer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("audit service is closed")
+	}
+
+	name := observerName(o)
+	s.names[name]++
+	if count := s.names[name]; count > 1 {
+		name = fmt.Sprintf("%s-%d", name, count)
+	}
+
+	w := &observerWorker{
+		name:       name,
+		observer:   o,
+		retry:      s.options.Retry,
+		deadLetter: s.deadLetter,
+		stop:       s.ctx.Done(),
+	}
+
+	if s.options.SpoolDir != "" {
+		spool, err := openSpool(s.options.SpoolDir, name, w.corrupt)
+		if err != nil {
+			return fmt.Errorf("open audit spool of %s: %w", name, err)
+		}
+		w.queue = spool
+	} else {
+		w.queue = newMemoryQueue(s.options.Buffer)
+	}
+
+	s.workers = append(s.workers, w)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		w.run()
+	}()
+
+	return nil
 }
 
-// Notify enqueues an audit event for asynchronous delivery
+// Notify enqueues an audit event for asynchronous delivery to every observer
 func (s *Service) Notify(event Event) {
-	select {
-	case s.ch <- event:
-	default:
-		log.Println("audit buffer full, dropping event")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		metrics.AuditEventsDroppedTotal.Inc()
+		logger.Log.Warn("audit service closed, dropping event", zap.String("action", event.Action))
+		return
+	}
+
+	for _, w := range s.workers {
+		if err := w.queue.push(event); err != nil {
+			metrics.AuditEventsDroppedTotal.Inc()
+			logger.Log.Warn("failed to queue audit event, dropping it",
+				zap.String("observer", w.name),
+				zap.String("action", event.Action),
+				zap.Error(err),
+			)
+		}
 	}
 }
 
-// worker processes events and forwards them to observers
-func (s *Service) worker() {
-	defer s.wg.Done()
+// Close stops the service and waits for the delivery goroutines
+// Spooled events are kept for the next start, in-memory events get a final delivery attempt
+// and are moved to the dead letter file if it fails
+func (s *Service) Close() error {
+	var err error
+
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+
+		s.cancel()
+		for _, w := range s.workers {
+			w.queue.shutdown()
+		}
+		s.wg.Wait()
+
+		for _, w := range s.workers {
+			err = errors.Join(err, w.queue.Close())
+		}
+		err = errors.Join(err, s.deadLetter.Close())
+	})
 
+	return err
+}
+
+// observerWorker delivers the events of one observer in order
+type observerWorker struct {
+	name       string
+	observer   Observer
+	queue      queue
+	retry      RetryPolicy
+	deadLetter *deadLetter
+	stop       <-chan struct{}
+}
+
+// run delivers queued events until the queue is shut down
+func (w *observerWorker) run() {
 	for {
-		select {
-		case <-s.ctx.Done():
-			for event := range s.ch {
-				s.dispatch(event)
-			}
+		events, ok := w.collect()
+		if !ok {
 			return
+		}
+
+		if !w.deliver(events) {
+			// Undelivered durable events wait for the next start
+			return
+		}
 
-		case event, ok := <-s.ch:
-			if !ok {
-				return
+		if err := w.queue.ack(); err != nil {
+			logger.Log.Error("failed to acknowledge audit events", zap.String("observer", w.name), zap.Error(err))
+		}
+	}
+}
+
+// collect waits for the next events to deliver, false is returned once the queue is shut down
+// Batch observers get the events queued until their batch is full or the batch interval elapses
+func (w *observerWorker) collect() ([]Event, bool) {
+	event, err := w.queue.next(nil)
+	if err != nil {
+		return nil, false
+	}
+	events := []Event{event}
+
+	batchObserver, ok := w.observer.(BatchObserver)
+	if !ok {
+		return events, true
+	}
+
+	size, interval := batchObserver.Batching()
+	if size <= 1 {
+		return events, true
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for len(events) < size {
+		event, err := w.queue.next(timer.C)
+		if errors.Is(err, errQueueClosed) && w.queue.durable() {
+			return nil, false
+		}
+		if err != nil {
+			break
+		}
+		events = append(events, event)
+	}
+
+	return events, true
+}
+
+// deliver sends the events with retries and reports whether they are settled,
+// either delivered or moved to the dead letter file
+// Durable events are left unsettled when the service stops while they wait for a retry,
+// in-memory events would be lost and are moved to the dead letter file instead
+func (w *observerWorker) deliver(events []Event) bool {
+	ctx, span := tracing.Start(context.Background(), "audit.deliver",
+		attribute.String("audit.observer", w.name),
+		attribute.Int("audit.batch.size", len(events)),
+	)
+	defer span.End()
+
+	for attempt := 1; ; attempt++ {
+		err := w.send(events)
+		if err == nil {
+			return true
+		}
+
+		span.RecordError(err, trace.WithAttributes(attribute.Int("audit.attempt", attempt)))
+		metrics.AuditEventsFailedTotal.WithLabelValues(w.name).Add(float64(len(events)))
+		logger.FromContext(ctx).Warn("audit send error",
+			zap.String("observer", w.name),
+			zap.Int("attempt", attempt),
+			zap.Int("events", len(events)),
+			zap.Error(err),
+		)
+
+		if IsPermanent(err) || (w.retry.MaxAttempts > 0 && attempt >= w.retry.MaxAttempts) {
+			span.SetStatus(codes.Error, "audit events dead lettered")
+			w.deadLetterEvents(events, attempt, err)
+			return true
+		}
+
+		select {
+		case <-time.After(w.retry.backoff(attempt)):
+		case <-w.stop:
+			span.SetStatus(codes.Error, "audit delivery interrupted")
+			logger.FromContext(ctx).Warn("audit delivery interrupted by shutdown", zap.String("observer", w.name))
+
+			if w.queue.durable() {
+				return false
 			}
-			s.dispatch(event)
+
+			w.deadLetterEvents(events, attempt, fmt.Errorf("delivery interrupted by shutdown: %w", err))
+			return true
 		}
 	}
 }
 
-// Close stops the service and waits for pending events to be processed
-func (s *Service) Close() error {
-	s.closeOnce.Do(func() {
-		close(s.ch)
-	})
-	s.wg.Wait()
-	return nil
+// deadLetterEvents moves the events that failed to be delivered to the dead letter file
+func (w *observerWorker) deadLetterEvents(events []Event, attempts int, err error) {
+	for i := range events {
+		w.deadLetter.write(DeadLetterRecord{
+			Observer: w.name,
+			Attempts: attempts,
+			Error:    err.Error(),
+			Event:    &events[i],
+		})
+	}
 }
 
-// dispatch notifies all the observers for the event
-func (s *Service) dispatch(event Event) {
-	for _, observer := range s.observers {
-		if err := observer.Send(event); err != nil {
-			logger.Log.Error("audit send error", zap.Error(err))
+// send delivers the events in one batch when the observer supports it
+func (w *observerWorker) send(events []Event) error {
+	if batchObserver, ok := w.observer.(BatchObserver); ok {
+		return batchObserver.SendBatch(events)
+	}
+
+	for _, event := range events {
+		if err := w.observer.Send(event); err != nil {
+			return err
 		}
 	}
+
+	return nil
+}
+
+// corrupt moves a spooled line that cannot be decoded to the dead letter file
+func (w *observerWorker) corrupt(line []byte, err error) {
+	w.deadLetter.write(DeadLetterRecord{
+		Observer: w.name,
+		Error:    err.Error(),
+		Raw:      string(line),
+	})
+}
+
+// observerName returns the observer type name used as a metric label
+func observerName(observer Observer) string {
+	t := reflect.TypeOf(observer)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Name()
 }
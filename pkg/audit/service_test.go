@@ -0,0 +1,349 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingObserver records delivered events and fails while fail returns an error
+type recordingObserver struct {
+	mu       sync.Mutex
+	events   []Event
+	attempts int
+	fail     func(attempt int) error
+}
+
+func (o *recordingObserver) Send(event Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.attempts++
+	if o.fail != nil {
+		if err := o.fail(o.attempts); err != nil {
+			return err
+		}
+	}
+
+	o.events = append(o.events, event)
+	return nil
+}
+
+func (o *recordingObserver) delivered() []Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Event(nil), o.events...)
+}
+
+// blockingObserver blocks every delivery until release is closed
+type blockingObserver struct {
+	release chan struct{}
+}
+
+func (o *blockingObserver) Send(Event) error {
+	<-o.release
+	return nil
+}
+
+// fastRetry retries quickly so tests do not wait for the default backoff
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond}
+
+func TestServiceRetries(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	tests := []struct {
+		name          string
+		fail          func(attempt int) error
+		wantDelivered int
+		wantAttempts  int
+		wantDead      bool
+	}{
+		{
+			name: "Positive case: delivered after failed attempts",
+			fail: func(attempt int) error {
+				if attempt < 3 {
+					return errUnavailable
+				}
+				return nil
+			},
+			wantDelivered: 1,
+			wantAttempts:  3,
+		},
+		{
+			name:         "Negative case: attempts are exhausted",
+			fail:         func(int) error { return errUnavailable },
+			wantAttempts: 3,
+			wantDead:     true,
+		},
+		{
+			name:         "Negative case: permanent error is not retried",
+			fail:         func(int) error { return Permanent(errUnavailable) },
+			wantAttempts: 1,
+			wantDead:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadLetterFile := filepath.Join(t.TempDir(), "dead.jsonl")
+
+			options := DefaultOptions()
+			options.DeadLetterFile = deadLetterFile
+			options.Retry = fastRetry
+
+			svc, err := NewService(context.Background(), options)
+			require.NoError(t, err)
+
+			observer := &recordingObserver{fail: tt.fail}
+			require.NoError(t, svc.Register(observer))
+
+			svc.Notify(Event{TS: 1, Action: ActionShorten, URL: "https://yandex.ru"})
+
+			require.Eventually(t, func() bool {
+				if tt.wantDead {
+					info, err := os.Stat(deadLetterFile)
+					return err == nil && info.Size() > 0
+				}
+				return len(observer.delivered()) == tt.wantDelivered
+			}, time.Second, time.Millisecond)
+			require.NoError(t, svc.Close())
+
+			assert.Equal(t, tt.wantAttempts, observer.attempts)
+			assert.Len(t, observer.delivered(), tt.wantDelivered)
+
+			records := readDeadLetters(t, deadLetterFile)
+			if !tt.wantDead {
+				assert.Empty(t, records)
+				return
+			}
+
+			require.Len(t, records, 1)
+			assert.Equal(t, "recordingObserver", records[0].Observer)
+			assert.Equal(t, tt.wantAttempts, records[0].Attempts)
+			assert.Equal(t, errUnavailable.Error(), records[0].Error)
+			require.NotNil(t, records[0].Event)
+			assert.Equal(t, "https://yandex.ru", records[0].Event.URL)
+		})
+	}
+}
+
+func TestServiceSlowObserverDoesNotBlockOthers(t *testing.T) {
+	svc, err := NewService(context.Background(), DefaultOptions())
+	require.NoError(t, err)
+
+	slow := &blockingObserver{release: make(chan struct{})}
+	fast := &recordingObserver{}
+	require.NoError(t, svc.Register(slow))
+	require.NoError(t, svc.Register(fast))
+
+	for i := range 3 {
+		svc.Notify(Event{TS: int64(i), Action: ActionFollow})
+	}
+
+	require.Eventually(t, func() bool { return len(fast.delivered()) == 3 }, time.Second, time.Millisecond)
+
+	close(slow.release)
+	require.NoError(t, svc.Close())
+}
+
+func TestServiceSpoolSurvivesRestart(t *testing.T) {
+	spoolDir := t.TempDir()
+
+	options := DefaultOptions()
+	options.SpoolDir = spoolDir
+	options.Retry = RetryPolicy{InitialInterval: time.Hour, MaxInterval: time.Hour}
+
+	svc, err := NewService(context.Background(), options)
+	require.NoError(t, err)
+
+	failing := &recordingObserver{fail: func(int) error { return errors.New("unavailable") }}
+	require.NoError(t, svc.Register(failing))
+
+	svc.Notify(Event{TS: 1, Action: ActionShorten, URL: "https://yandex.ru"})
+	svc.Notify(Event{TS: 2, Action: ActionFollow, URL: "https://google.com"})
+
+	require.Eventually(t, func() bool {
+		failing.mu.Lock()
+		defer failing.mu.Unlock()
+		return failing.attempts > 0
+	}, time.Second, time.Millisecond)
+	require.NoError(t, svc.Close())
+
+	svc, err = NewService(context.Background(), options)
+	require.NoError(t, err)
+
+	recovered := &recordingObserver{}
+	require.NoError(t, svc.Register(recovered))
+
+	require.Eventually(t, func() bool { return len(recovered.delivered()) == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, svc.Close())
+
+	events := recovered.delivered()
+	assert.Equal(t, "https://yandex.ru", events[0].URL)
+	assert.Equal(t, "https://google.com", events[1].URL)
+
+	info, err := os.Stat(filepath.Join(spoolDir, "recordingObserver.spool"))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestSpoolRestore(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := json.Marshal(Event{TS: 1, Action: ActionShorten})
+	require.NoError(t, err)
+	content := string(first) + "\n" + "{not json}\n" + `{"ts":3,"act`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "observer.spool"), []byte(content), 0644))
+
+	var corrupt []string
+	s, err := openSpool(dir, "observer", func(line []byte, err error) {
+		corrupt = append(corrupt, string(line))
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	event, err := s.next(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), event.TS)
+	require.NoError(t, s.ack())
+
+	require.NoError(t, s.push(Event{TS: 4, Action: ActionFollow}))
+
+	event, err = s.next(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), event.TS)
+	assert.Equal(t, []string{"{not json}\n"}, corrupt)
+	require.NoError(t, s.ack())
+
+	info, err := os.Stat(filepath.Join(dir, "observer.spool"))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestServiceDeadLettersInMemoryEventsOnClose(t *testing.T) {
+	deadLetterFile := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	options := DefaultOptions()
+	options.DeadLetterFile = deadLetterFile
+	options.Retry = RetryPolicy{InitialInterval: time.Hour, MaxInterval: time.Hour}
+
+	svc, err := NewService(context.Background(), options)
+	require.NoError(t, err)
+
+	failing := &recordingObserver{fail: func(int) error { return errors.New("unavailable") }}
+	require.NoError(t, svc.Register(failing))
+
+	svc.Notify(Event{TS: 1, Action: ActionShorten})
+	require.Eventually(t, func() bool {
+		failing.mu.Lock()
+		defer failing.mu.Unlock()
+		return failing.attempts > 0
+	}, time.Second, time.Millisecond)
+
+	svc.Notify(Event{TS: 2, Action: ActionFollow})
+	require.NoError(t, svc.Close())
+
+	records := readDeadLetters(t, deadLetterFile)
+	require.Len(t, records, 2)
+	for i, record := range records {
+		require.NotNil(t, record.Event)
+		assert.Equal(t, int64(i+1), record.Event.TS)
+		assert.Contains(t, record.Error, "interrupted by shutdown")
+	}
+}
+
+func TestSpoolCompaction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "observer.spool")
+
+	s, err := openSpool(dir, "observer", func([]byte, error) {})
+	require.NoError(t, err)
+	s.compactAt = 1
+
+	for i := range 3 {
+		require.NoError(t, s.push(Event{TS: int64(i + 1), Action: ActionShorten}))
+	}
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	event, err := s.next(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), event.TS)
+	require.NoError(t, s.ack())
+
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, before.Size()*2/3, after.Size())
+
+	require.NoError(t, s.push(Event{TS: 4, Action: ActionFollow}))
+	require.NoError(t, s.Close())
+
+	s, err = openSpool(dir, "observer", func([]byte, error) {})
+	require.NoError(t, err)
+	defer s.Close()
+
+	for _, want := range []int64{2, 3, 4} {
+		event, err := s.next(nil)
+		require.NoError(t, err)
+		assert.Equal(t, want, event.TS)
+		require.NoError(t, s.ack())
+	}
+
+	drained, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, drained.Size())
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 100 * time.Millisecond},
+		{attempt: 2, max: 200 * time.Millisecond},
+		{attempt: 4, max: 800 * time.Millisecond},
+		{attempt: 5, max: time.Second},
+		{attempt: 100, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			delay := policy.backoff(tt.attempt)
+			assert.GreaterOrEqual(t, delay, tt.max/2)
+			assert.LessOrEqual(t, delay, tt.max)
+		}
+	}
+}
+
+// readDeadLetters reads the records of the dead letter file
+func readDeadLetters(t *testing.T, path string) []DeadLetterRecord {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []DeadLetterRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record DeadLetterRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+
+	return records
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of signed audit requests
+const (
+	// TimestampHeader carries the Unix time in seconds when the request was signed
+	TimestampHeader = "X-Audit-Timestamp"
+	// SignatureHeader carries the HMAC-SHA256 signature of the timestamp, the delivery ID and the request body
+	SignatureHeader = "X-Audit-Signature"
+	// DeliveryIDHeader carries the UUID of the batch, the same for every retry of the batch
+	DeliveryIDHeader = "X-Audit-Delivery-ID"
+)
+
+// signaturePrefix names the algorithm in the signature header value
+const signaturePrefix = "sha256="
+
+var (
+	// ErrInvalidSignature is returned when the signature does not match the request
+	ErrInvalidSignature = errors.New("invalid audit signature")
+	// ErrStaleTimestamp is returned when the request was signed outside of the accepted window
+	ErrStaleTimestamp = errors.New("stale audit timestamp")
+	// ErrReplayedDelivery is returned when a delivery ID is received again within the accepted window
+	ErrReplayedDelivery = errors.New("replayed audit delivery")
+)
+
+// Sign returns the signature header value of the body sent at the timestamp
+// The signed message is the timestamp, a dot, the delivery ID, a dot and the body bytes as sent, compressed or not
+func Sign(key []byte, timestamp int64, deliveryID string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write([]byte(deliveryID))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the signature, timestamp and delivery ID header values of a received body
+// Requests signed more than tolerance away from now are rejected. A captured request may still be
+// replayed within the tolerance, receivers reject those with a ReplayGuard of the same window
+func VerifySignature(key []byte, timestamp string, deliveryID string, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+
+	if diff := now.Sub(time.Unix(ts, 0)); diff > tolerance || diff < -tolerance {
+		return ErrStaleTimestamp
+	}
+
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(Sign(key, ts, deliveryID, body)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// ReplayGuard remembers the delivery IDs accepted within a window to reject repeated deliveries
+//
+// A receiver calls Accept once the signature is verified and Forget if it then fails to store
+// the batch, so that the retry of the batch, signed anew under the same delivery ID, is accepted.
+// The window should not be shorter than the timestamp tolerance of VerifySignature.
+type ReplayGuard struct {
+	window    time.Duration
+	seen      map[string]time.Time
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// NewReplayGuard creates a new ReplayGuard remembering delivery IDs for the window
+func NewReplayGuard(window time.Duration) *ReplayGuard {
+	return &ReplayGuard{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Accept records the delivery ID received at now
+// Returns ErrReplayedDelivery if the ID was accepted within the window
+func (g *ReplayGuard) Accept(deliveryID string, now time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.prune(now)
+
+	if acceptedAt, isFound := g.seen[deliveryID]; isFound && now.Sub(acceptedAt) < g.window {
+		return ErrReplayedDelivery
+	}
+
+	g.seen[deliveryID] = now
+
+	return nil
+}
+
+// Forget removes the delivery ID so that it is accepted again
+func (g *ReplayGuard) Forget(deliveryID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.seen, deliveryID)
+}
+
+// prune drops the delivery IDs accepted before the window, at most once per window
+// The caller must hold the lock
+func (g *ReplayGuard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < g.window {
+		return
+	}
+
+	for deliveryID, acceptedAt := range g.seen {
+		if now.Sub(acceptedAt) >= g.window {
+			delete(g.seen, deliveryID)
+		}
+	}
+
+	g.lastPrune = now
+}
@@ -0,0 +1,303 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+)
+
+const (
+	// spoolOffsetSize is the size of the persisted read offset in bytes
+	spoolOffsetSize = 8
+	// spoolRetryInterval is the delay before reading the spool again after a failure
+	spoolRetryInterval = time.Second
+	// spoolCompactThreshold is the size of delivered events after which the spool is compacted
+	spoolCompactThreshold = 4 << 20
+)
+
+// spool is a disk-backed queue of the events of one observer
+// Events are appended to a JSON lines file and the offset of the first undelivered event
+// is kept in a sidecar file, both files are truncated once every event is delivered.
+// An observer that never catches up has its delivered events compacted away instead.
+type spool struct {
+	mu          sync.Mutex
+	file        *os.File
+	offsetFile  *os.File
+	readOffset  int64
+	writeOffset int64
+	// compactAt is the read offset from which the delivered events are compacted away
+	compactAt int64
+	// pending is the length of the lines returned by next and not acknowledged yet
+	pending int64
+	// corrupt receives lines that cannot be decoded, they are skipped afterwards
+	corrupt func(line []byte, err error)
+	notify  chan struct{}
+	stop    chan struct{}
+}
+
+// openSpool opens the spool of the observer in the directory and restores its read position
+func openSpool(dir string, name string, corrupt func(line []byte, err error)) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, name+".spool"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	offsetFile, err := os.OpenFile(filepath.Join(dir, name+".offset"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+
+	s := &spool{
+		file:       file,
+		offsetFile: offsetFile,
+		compactAt:  spoolCompactThreshold,
+		corrupt:    corrupt,
+		notify:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+
+	if err := s.restore(); err != nil {
+		return nil, errors.Join(err, s.Close())
+	}
+
+	return s, nil
+}
+
+// restore reads the persisted read offset and drops a partially written last event
+func (s *spool) restore() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	buf := make([]byte, spoolOffsetSize)
+	n, err := s.offsetFile.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if n == spoolOffsetSize {
+		s.readOffset = int64(binary.BigEndian.Uint64(buf))
+	}
+
+	// The spool was truncated without its offset, every remaining event is delivered again
+	if s.readOffset > size {
+		s.readOffset = 0
+	}
+
+	end := s.readOffset
+	reader := bufio.NewReader(io.NewSectionReader(s.file, s.readOffset, size-s.readOffset))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		end += int64(len(line))
+	}
+
+	if end < size {
+		if err := s.file.Truncate(end); err != nil {
+			return err
+		}
+	}
+	s.writeOffset = end
+
+	if s.readOffset == s.writeOffset {
+		return s.reset()
+	}
+
+	return nil
+}
+
+// push appends the event to the spool file
+func (s *spool) push(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.WriteAt(data, s.writeOffset); err != nil {
+		return err
+	}
+	s.writeOffset += int64(len(data))
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// next returns the oldest event that is not returned yet, events stay in the spool after shutdown
+func (s *spool) next(wait <-chan time.Time) (Event, error) {
+	for {
+		select {
+		case <-s.stop:
+			return Event{}, errQueueClosed
+		default:
+		}
+
+		event, ok, err := s.peek()
+		if ok {
+			return event, nil
+		}
+
+		wake, retry := s.notify, (<-chan time.Time)(nil)
+		if err != nil {
+			logger.Log.Error("failed to read audit spool", zap.Error(err))
+			wake, retry = nil, time.After(spoolRetryInterval)
+		}
+
+		select {
+		case <-wake:
+		case <-retry:
+		case <-wait:
+			return Event{}, errQueueEmpty
+		case <-s.stop:
+			return Event{}, errQueueClosed
+		}
+	}
+}
+
+// peek decodes the event following the pending ones, undecodable lines are reported and skipped
+func (s *spool) peek() (Event, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for offset := s.readOffset + s.pending; offset < s.writeOffset; offset = s.readOffset + s.pending {
+		reader := bufio.NewReader(io.NewSectionReader(s.file, offset, s.writeOffset-offset))
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Event{}, false, err
+		}
+		s.pending += int64(len(line))
+
+		var event Event
+		err = json.Unmarshal(line, &event)
+		if err == nil {
+			return event, true, nil
+		}
+		s.corrupt(line, err)
+	}
+
+	return Event{}, false, nil
+}
+
+// ack removes the events returned by next from the spool
+func (s *spool) ack() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.advance()
+}
+
+// advance moves the read offset past the pending lines and persists it
+func (s *spool) advance() error {
+	s.readOffset += s.pending
+	s.pending = 0
+
+	if s.readOffset >= s.writeOffset {
+		return s.reset()
+	}
+
+	if s.readOffset >= s.compactAt {
+		return s.compact()
+	}
+
+	buf := make([]byte, spoolOffsetSize)
+	binary.BigEndian.PutUint64(buf, uint64(s.readOffset))
+	_, err := s.offsetFile.WriteAt(buf, 0)
+
+	return err
+}
+
+// reset truncates the drained spool so it does not grow while events are delivered
+func (s *spool) reset() error {
+	s.readOffset = 0
+	s.writeOffset = 0
+
+	return errors.Join(s.file.Truncate(0), s.offsetFile.Truncate(0))
+}
+
+// compact moves the undelivered events into a new spool file so that delivered ones stop taking space
+// The read offset is cleared before the new file replaces the old one, a crash in between
+// delivers the events of the old file again rather than losing any
+func (s *spool) compact() error {
+	path := s.file.Name()
+	tmpPath := path + ".tmp"
+
+	if err := s.writeUndelivered(tmpPath); err != nil {
+		return errors.Join(err, os.Remove(tmpPath))
+	}
+
+	if err := s.offsetFile.Truncate(0); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	old := s.file
+	s.file = file
+	s.writeOffset -= s.readOffset
+	s.readOffset = 0
+
+	return old.Close()
+}
+
+// writeUndelivered writes the events following the read offset into the file and flushes it to disk
+func (s *spool) writeUndelivered(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, io.NewSectionReader(s.file, s.readOffset, s.writeOffset-s.readOffset)); err != nil {
+		return errors.Join(err, file.Close())
+	}
+
+	return errors.Join(file.Sync(), file.Close())
+}
+
+// durable reports true as events are kept on disk
+func (s *spool) durable() bool {
+	return true
+}
+
+// shutdown makes next stop returning events
+func (s *spool) shutdown() {
+	close(s.stop)
+}
+
+// Close closes the spool files
+func (s *spool) Close() error {
+	return errors.Join(s.file.Close(), s.offsetFile.Close())
+}
@@ -0,0 +1,260 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Facility is a syslog facility code
+type Facility int
+
+// Syslog facilities of RFC 5424
+const (
+	FacilityKern     Facility = 0
+	FacilityUser     Facility = 1
+	FacilityDaemon   Facility = 3
+	FacilityAuth     Facility = 4
+	FacilitySyslog   Facility = 5
+	FacilityAuthPriv Facility = 10
+	FacilityAudit    Facility = 13
+	FacilityLocal0   Facility = 16
+	FacilityLocal7   Facility = 23
+)
+
+// facilityNames maps the names accepted by ParseFacility to facility codes
+var facilityNames = map[string]Facility{
+	"kern":     FacilityKern,
+	"user":     FacilityUser,
+	"daemon":   FacilityDaemon,
+	"auth":     FacilityAuth,
+	"syslog":   FacilitySyslog,
+	"authpriv": FacilityAuthPriv,
+	"audit":    FacilityAudit,
+	"local0":   FacilityLocal0,
+	"local1":   FacilityLocal0 + 1,
+	"local2":   FacilityLocal0 + 2,
+	"local3":   FacilityLocal0 + 3,
+	"local4":   FacilityLocal0 + 4,
+	"local5":   FacilityLocal0 + 5,
+	"local6":   FacilityLocal0 + 6,
+	"local7":   FacilityLocal7,
+}
+
+// ParseFacility returns the facility of a name such as authpriv or local0
+func ParseFacility(name string) (Facility, error) {
+	facility, ok := facilityNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", name)
+	}
+
+	return facility, nil
+}
+
+// Syslog severities used for audit events
+const (
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+// syslogTimeout bounds dialing the syslog server and writing one message
+const syslogTimeout = 5 * time.Second
+
+// SyslogObserverOptions configures delivery of audit events to syslog
+type SyslogObserverOptions struct {
+	// Facility of the messages
+	Facility Facility
+	// AppName identifies the application in the messages
+	AppName string
+	// Hostname identifies the host in the messages, the name reported by the kernel when empty
+	Hostname string
+}
+
+// DefaultSyslogObserverOptions returns options logging to the authpriv facility as the shortener application
+func DefaultSyslogObserverOptions() SyslogObserverOptions {
+	return SyslogObserverOptions{
+		Facility: FacilityAuthPriv,
+		AppName:  "shortener",
+	}
+}
+
+// SyslogObserver structure to observe audit events and sends them to syslog as RFC 5424 messages
+// Messages are sent one per datagram over udp and unixgram, and with octet counting framing of RFC 6587 over tcp and unix streams
+type SyslogObserver struct {
+	network  string
+	address  string
+	options  SyslogObserverOptions
+	hostname string
+	procID   string
+
+	mu   sync.Mutex
+	conn net.Conn
+	// framed is set when the connection is a stream that needs message framing
+	framed bool
+}
+
+// NewSyslogObserver creates a new SyslogObserver for an address such as udp://host:514, tcp://host:601 or unix:///dev/log
+// The connection is established by the first Send and reestablished after a failed one
+func NewSyslogObserver(address string, options SyslogObserverOptions) (*SyslogObserver, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("parse syslog address: %w", err)
+	}
+
+	var addr string
+	switch u.Scheme {
+	case "udp", "tcp":
+		addr = u.Host
+	case "unix", "unixgram":
+		addr = u.Path
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", u.Scheme)
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("syslog address %q has no host or path", address)
+	}
+
+	if options.Facility < FacilityKern || options.Facility > FacilityLocal7 {
+		return nil, fmt.Errorf("invalid syslog facility %d", options.Facility)
+	}
+
+	hostname := options.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	return &SyslogObserver{
+		network:  u.Scheme,
+		address:  addr,
+		options:  options,
+		hostname: headerField(hostname, 255),
+		procID:   strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+// Send writes the event to syslog
+func (s *SyslogObserver) Send(event Event) error {
+	msg, err := s.format(event)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, network, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.framed = network == "tcp" || network == "unix"
+	}
+
+	if s.framed {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout)); err != nil {
+		return errors.Join(err, s.reset())
+	}
+
+	if _, err := s.conn.Write(msg); err != nil {
+		return errors.Join(err, s.reset())
+	}
+
+	return nil
+}
+
+// Close closes the connection to syslog
+func (s *SyslogObserver) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reset()
+}
+
+// format builds the RFC 5424 message of the event with the event JSON as its body
+func (s *SyslogObserver) format(event Event) ([]byte, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	severity := severityInfo
+	if event.Outcome == OutcomeDenied {
+		severity = severityWarning
+	}
+
+	ts := time.Now()
+	if event.TS != 0 {
+		ts = time.Unix(event.TS, 0)
+	}
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	header := fmt.Sprintf("<%d>1 %s %s %s %s %s - ",
+		int(s.options.Facility)*8+severity,
+		ts.UTC().Format(time.RFC3339),
+		s.hostname,
+		headerField(s.options.AppName, 48),
+		s.procID,
+		headerField(event.Action, 32),
+	)
+
+	return append([]byte(header), body...), nil
+}
+
+// dial connects to syslog and returns the network of the connection
+// A unix socket is tried as a datagram socket first and as a stream socket then
+func (s *SyslogObserver) dial() (net.Conn, string, error) {
+	if s.network != "unix" {
+		conn, err := net.DialTimeout(s.network, s.address, syslogTimeout)
+		return conn, s.network, err
+	}
+
+	conn, err := net.DialTimeout("unixgram", s.address, syslogTimeout)
+	if err == nil {
+		return conn, "unixgram", nil
+	}
+
+	conn, err = net.DialTimeout("unix", s.address, syslogTimeout)
+	return conn, "unix", err
+}
+
+// reset closes the connection so the next Send reconnects
+func (s *SyslogObserver) reset() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// headerField returns the value as an RFC 5424 header field of printable ASCII of at most limit characters
+// An empty value is written as the nil value
+func headerField(value string, limit int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+
+	if len(value) > limit {
+		value = value[:limit]
+	}
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
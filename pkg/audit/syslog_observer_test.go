@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syslogMessage matches an RFC 5424 message and captures its priority, message ID and body
+var syslogMessage = regexp.MustCompile(`^<(\d+)>1 2023-11-14T22:13:20Z test-host shortener \d+ (\S+) - (\{.*\})$`)
+
+func TestSyslogObserver(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		listen  func(t *testing.T) (string, func() string)
+	}{
+		{name: "Positive case: udp", network: "udp", listen: listenSyslogUDP},
+		{name: "Positive case: tcp", network: "tcp", listen: listenSyslogTCP},
+		{name: "Positive case: unix datagram socket", network: "unix", listen: listenSyslogUnix},
+	}
+
+	events := []struct {
+		event        Event
+		wantPriority int
+	}{
+		{event: Event{TS: 1700000000, Action: ActionShorten, Outcome: OutcomeSuccess, URL: "https://yandex.ru"}, wantPriority: 10*8 + 6},
+		{event: Event{TS: 1700000000, Action: ActionBlock, Outcome: OutcomeDenied, Reason: "blocklisted"}, wantPriority: 10*8 + 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, receive := tt.listen(t)
+
+			options := DefaultSyslogObserverOptions()
+			options.Hostname = "test-host"
+
+			observer, err := NewSyslogObserver(tt.network+"://"+address, options)
+			require.NoError(t, err)
+			defer observer.Close()
+
+			for _, e := range events {
+				require.NoError(t, observer.Send(e.event))
+
+				match := syslogMessage.FindStringSubmatch(receive())
+				require.NotNil(t, match)
+				assert.Equal(t, strconv.Itoa(e.wantPriority), match[1])
+				assert.Equal(t, e.event.Action, match[2])
+
+				var got Event
+				require.NoError(t, json.Unmarshal([]byte(match[3]), &got))
+				assert.Equal(t, e.event, got)
+			}
+		})
+	}
+}
+
+func TestSyslogObserverReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	observer, err := NewSyslogObserver("tcp://"+listener.Addr().String(), DefaultSyslogObserverOptions())
+	require.NoError(t, err)
+	defer observer.Close()
+
+	require.NoError(t, observer.Send(Event{TS: 1, Action: ActionFollow}))
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// A write to a connection closed by the server fails on one of the next attempts
+	require.Eventually(t, func() bool { return observer.Send(Event{TS: 2, Action: ActionFollow}) != nil }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, observer.Send(Event{TS: 3, Action: ActionFollow}))
+
+	conn, err = listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	frame := readSyslogFrame(t, bufio.NewReader(conn))
+	assert.Contains(t, frame, `"ts":3`)
+}
+
+func TestNewSyslogObserverOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		options SyslogObserverOptions
+	}{
+		{name: "Negative case: unsupported network", address: "http://localhost:514", options: DefaultSyslogObserverOptions()},
+		{name: "Negative case: missing host", address: "udp://", options: DefaultSyslogObserverOptions()},
+		{name: "Negative case: invalid facility", address: "udp://localhost:514", options: SyslogObserverOptions{Facility: 24}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSyslogObserver(tt.address, tt.options)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseFacility(t *testing.T) {
+	facility, err := ParseFacility("LOCAL3")
+	require.NoError(t, err)
+	assert.Equal(t, Facility(19), facility)
+
+	_, err = ParseFacility("local8")
+	assert.Error(t, err)
+}
+
+// listenSyslogUDP listens for syslog datagrams and returns its address and a receive function
+func listenSyslogUDP(t *testing.T) (string, func() string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().String(), receiveDatagram(t, conn)
+}
+
+// listenSyslogUnix listens for syslog datagrams on a unix socket and returns its path and a receive function
+func listenSyslogUnix(t *testing.T) (string, func() string) {
+	t.Helper()
+
+	// Unix socket paths are limited to about a hundred bytes, so a short temporary directory is used
+	dir, err := os.MkdirTemp("", "syslog")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	conn, err := net.ListenPacket("unixgram", filepath.Join(dir, "log.sock"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().String(), receiveDatagram(t, conn)
+}
+
+// listenSyslogTCP listens for syslog frames over tcp and returns its address and a receive function
+func listenSyslogTCP(t *testing.T) (string, func() string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	var r *bufio.Reader
+	return listener.Addr().String(), func() string {
+		if r == nil {
+			conn, err := listener.Accept()
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+			r = bufio.NewReader(conn)
+		}
+
+		return readSyslogFrame(t, r)
+	}
+}
+
+// receiveDatagram returns a function reading one datagram from the connection
+func receiveDatagram(t *testing.T, conn net.PacketConn) func() string {
+	return func() string {
+		buf := make([]byte, 64*1024)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+
+		return string(buf[:n])
+	}
+}
+
+// readSyslogFrame reads one octet counted frame of RFC 6587
+func readSyslogFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	length, err := r.ReadString(' ')
+	require.NoError(t, err)
+
+	n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	require.NoError(t, err)
+
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	require.NoError(t, err)
+
+	return string(msg)
+}
@@ -0,0 +1,48 @@
+package authorization
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataKey is the gRPC metadata key carrying the JWT
+const metadataKey = "authorization"
+
+// bearerPrefix is the scheme prefix of the authorization metadata value
+const bearerPrefix = "Bearer "
+
+// UnaryServerInterceptor provides JWT-based authentication for gRPC unary calls
+// It mirrors AuthMiddleware: a missing or invalid token results in a new user
+// whose token is returned in the "authorization" response header
+func UnaryServerInterceptor(jwtKey []byte) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			for _, value := range md.Get(metadataKey) {
+				tokenStr := strings.TrimPrefix(value, bearerPrefix)
+
+				if claims, err := parseToken(tokenStr, jwtKey); err == nil && claims.UserID != "" {
+					return handler(withClaims(ctx, claims), req)
+				}
+			}
+		}
+
+		userID := uuid.NewString()
+
+		token, err := createToken(userID, jwtKey)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "could not create token")
+		}
+
+		if err := grpc.SetHeader(ctx, metadata.Pairs(metadataKey, bearerPrefix+token)); err != nil {
+			return nil, status.Error(codes.Internal, "could not send token")
+		}
+
+		return handler(withNewUser(ctx, userID), req)
+	}
+}
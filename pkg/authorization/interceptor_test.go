@@ -0,0 +1,102 @@
+package authorization
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerStream is a grpc.ServerTransportStream recording the response header
+type headerStream struct {
+	header metadata.MD
+}
+
+func (s *headerStream) Method() string {
+	return "/test/Method"
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *headerStream) SetTrailer(metadata.MD) error {
+	return nil
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	jwtKey := []byte("test_key")
+
+	token, err := createToken("user-1", jwtKey)
+	require.NoError(t, err)
+	foreignToken, err := createToken("user-1", []byte("other_key"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		token       string
+		wantUserID  string
+		wantNewUser bool
+	}{
+		{
+			name:       "Positive case: valid token",
+			token:      token,
+			wantUserID: "user-1",
+		},
+		{
+			name:        "Negative case: missing token",
+			wantNewUser: true,
+		},
+		{
+			name:        "Negative case: token signed with another key",
+			token:       foreignToken,
+			wantNewUser: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(metadataKey, bearerPrefix+tt.token))
+			}
+			stream := &headerStream{}
+			ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+
+			var (
+				userID    string
+				isNewUser bool
+				issuedAt  bool
+			)
+			_, err := UnaryServerInterceptor(jwtKey)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+				userID, _ = UserIDFromContext(ctx)
+				isNewUser = IsNewUser(ctx)
+				_, issuedAt = IssuedAtFromContext(ctx)
+				return nil, nil
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantNewUser, isNewUser)
+			assert.True(t, issuedAt)
+
+			if !tt.wantNewUser {
+				assert.Equal(t, tt.wantUserID, userID)
+				assert.Empty(t, stream.header.Get(metadataKey))
+				return
+			}
+
+			require.Len(t, stream.header.Get(metadataKey), 1)
+			claims, err := parseToken(stream.header.Get(metadataKey)[0][len(bearerPrefix):], jwtKey)
+			require.NoError(t, err)
+			assert.Equal(t, userID, claims.UserID)
+		})
+	}
+}
@@ -12,7 +12,11 @@ import (
 
 type contextKey string
 
-const userIDContextKey contextKey = "userID"
+const (
+	userIDContextKey   contextKey = "userID"
+	newUserContextKey  contextKey = "newUser"
+	issuedAtContextKey contextKey = "issuedAt"
+)
 
 const (
 	cookieName = "auth_token"
@@ -32,14 +36,29 @@ func UserIDFromContext(ctx context.Context) (string, bool) {
 	return userID, ok
 }
 
+// IsNewUser reports whether the user ID was issued while handling the current request
+// rather than read from a valid authorization cookie
+func IsNewUser(ctx context.Context) bool {
+	isNew, _ := ctx.Value(newUserContextKey).(bool)
+
+	return isNew
+}
+
+// IssuedAtFromContext returns when the token of the user was issued
+// Tokens without an issue time report false
+func IssuedAtFromContext(ctx context.Context) (time.Time, bool) {
+	issuedAt, ok := ctx.Value(issuedAtContextKey).(time.Time)
+
+	return issuedAt, ok
+}
+
 // AuthMiddleware provides JWT-based authentication middleware
 func AuthMiddleware(jwtKey []byte) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if cookie, err := r.Cookie(cookieName); err == nil {
 				if claims, err := parseToken(cookie.Value, jwtKey); err == nil && claims.UserID != "" {
-					ctx := context.WithValue(r.Context(), userIDContextKey, claims.UserID)
-					next.ServeHTTP(w, r.WithContext(ctx))
+					next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
 					return
 				}
 			}
@@ -54,12 +73,29 @@ func AuthMiddleware(jwtKey []byte) func(http.Handler) http.Handler {
 
 			setAuthCookie(w, token)
 
-			ctx := context.WithValue(r.Context(), userIDContextKey, userID)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r.WithContext(withNewUser(r.Context(), userID)))
 		})
 	}
 }
 
+// withClaims returns a copy of the context carrying the user of a valid token
+func withClaims(ctx context.Context, claims *Claims) context.Context {
+	ctx = context.WithValue(ctx, userIDContextKey, claims.UserID)
+	if claims.IssuedAt != nil {
+		ctx = context.WithValue(ctx, issuedAtContextKey, claims.IssuedAt.Time)
+	}
+
+	return ctx
+}
+
+// withNewUser returns a copy of the context carrying a user issued for the current request
+func withNewUser(ctx context.Context, userID string) context.Context {
+	ctx = context.WithValue(ctx, userIDContextKey, userID)
+	ctx = context.WithValue(ctx, newUserContextKey, true)
+
+	return context.WithValue(ctx, issuedAtContextKey, time.Now())
+}
+
 // createToken generates a signed JWT for the given user ID
 func createToken(userID string, jwtKey []byte) (string, error) {
 	now := time.Now()
@@ -0,0 +1,18 @@
+package compress
+
+import (
+	"io"
+)
+
+// AcquireGzipWriter takes a gzip writer from the pool and makes it write to w
+func AcquireGzipWriter(w io.Writer) *PooledGzipWriter {
+	gz := gzipWriterPool.Get()
+	gz.W.Reset(w)
+
+	return gz
+}
+
+// ReleaseGzipWriter returns the gzip writer to the pool, it must be closed beforehand
+func ReleaseGzipWriter(gz *PooledGzipWriter) {
+	gzipWriterPool.Put(gz)
+}
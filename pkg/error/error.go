@@ -1,18 +1,66 @@
 package error
 
+// ErrorCode is a stable machine-readable identifier of an application error
+type ErrorCode string
+
+// Error codes returned to API clients
+const (
+	// CodeInvalidRequest means the request body or parameters could not be parsed
+	CodeInvalidRequest ErrorCode = "invalid_request"
+	// CodeInvalidURL means the provided URL is not a valid absolute URL
+	CodeInvalidURL ErrorCode = "invalid_url"
+	// CodeInvalidExpiration means the provided expiry time or TTL is not acceptable
+	CodeInvalidExpiration ErrorCode = "invalid_expiration"
+	// CodeInvalidAlias means the provided alias cannot be used as a short code
+	CodeInvalidAlias ErrorCode = "invalid_alias"
+	// CodeInvalidQRCodeOptions means the requested QR code parameters are not supported
+	CodeInvalidQRCodeOptions ErrorCode = "invalid_qr_code_options"
+	// CodeInvalidRedirect means the requested redirect type or UTM parameters are not supported
+	CodeInvalidRedirect ErrorCode = "invalid_redirect"
+	// CodeInvalidPagination means the requested page size, cursor or sort order is not supported
+	CodeInvalidPagination ErrorCode = "invalid_pagination"
+	// CodeDestinationBlocked means the URL points to a destination the policy does not allow
+	CodeDestinationBlocked ErrorCode = "destination_blocked"
+	// CodeAliasTaken means the alias is already used by another URL
+	CodeAliasTaken ErrorCode = "alias_taken"
+	// CodeURLConflict means the URL has already been shortened
+	CodeURLConflict ErrorCode = "url_conflict"
+	// CodeURLNotFound means the short URL is unknown
+	CodeURLNotFound ErrorCode = "url_not_found"
+	// CodeURLGone means the short URL has been deleted or has expired
+	CodeURLGone ErrorCode = "url_gone"
+	// CodeUnauthorized means the request carries no valid user identity
+	CodeUnauthorized ErrorCode = "unauthorized"
+	// CodeForbidden means the user has no access to the resource
+	CodeForbidden ErrorCode = "forbidden"
+	// CodeRateLimited means the client has exceeded the request rate limit
+	CodeRateLimited ErrorCode = "rate_limited"
+	// CodeInternal means the request failed because of a server-side error
+	CodeInternal ErrorCode = "internal_error"
+)
+
 // HTTPError represents an application-level HTTP error
 type HTTPError struct {
-	Code    int
-	Message string
-	Error   error
+	Code      int
+	ErrorCode ErrorCode
+	Message   string
+	Error     error
 }
 
 // NewHTTPError creates a new HTTPError instance
-func NewHTTPError(code int, message string, error error) *HTTPError {
-	return &HTTPError{Code: code, Message: message, Error: error}
+func NewHTTPError(code int, errorCode ErrorCode, message string, error error) *HTTPError {
+	return &HTTPError{Code: code, ErrorCode: errorCode, Message: message, Error: error}
 }
 
 // GetFullMessage returns the combined message and error text
+// Either part is omitted when it is empty
 func (h *HTTPError) GetFullMessage() string {
+	switch {
+	case h.Error == nil:
+		return h.Message
+	case h.Message == "":
+		return h.Error.Error()
+	}
+
 	return h.Message + ": " + h.Error.Error()
 }
@@ -0,0 +1,39 @@
+package error
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFullMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		appErr  *HTTPError
+		message string
+	}{
+		{
+			name:    "Message and error",
+			appErr:  NewHTTPError(http.StatusBadRequest, CodeInvalidURL, "Invalid URL was provided", errors.New("empty host")),
+			message: "Invalid URL was provided: empty host",
+		},
+		{
+			name:    "Message without error",
+			appErr:  NewHTTPError(http.StatusConflict, CodeURLConflict, "URL has already been shortened", nil),
+			message: "URL has already been shortened",
+		},
+		{
+			name:    "Error without message",
+			appErr:  NewHTTPError(http.StatusInternalServerError, CodeInternal, "", errors.New("storage is unavailable")),
+			message: "storage is unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.message, tt.appErr.GetFullMessage())
+		})
+	}
+}
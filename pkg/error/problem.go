@@ -0,0 +1,75 @@
+package error
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix turns error codes into problem type URIs
+const problemTypePrefix = "urn:go-url-shortener:problem:"
+
+// Problem is an RFC 7807 problem details body extended with the error code
+type Problem struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     ErrorCode `json:"code"`
+}
+
+// Problem converts the error into problem details of the request
+// Details of server errors are not exposed to clients
+func (h *HTTPError) Problem(r *http.Request) *Problem {
+	errorCode := h.ErrorCode
+	if errorCode == "" {
+		errorCode = CodeInternal
+	}
+
+	title := h.Message
+	if title == "" {
+		title = http.StatusText(h.Code)
+	}
+
+	problem := &Problem{
+		Type:     problemTypePrefix + string(errorCode),
+		Title:    title,
+		Status:   h.Code,
+		Instance: r.URL.Path,
+		Code:     errorCode,
+	}
+
+	if h.Error != nil && h.Code < http.StatusInternalServerError {
+		problem.Detail = h.Error.Error()
+	}
+
+	return problem
+}
+
+// WriteProblem writes the error as RFC 7807 problem details
+func WriteProblem(w http.ResponseWriter, r *http.Request, appErr *HTTPError) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(appErr.Code)
+
+	_ = json.NewEncoder(w).Encode(appErr.Problem(r))
+}
+
+// WriteText writes the error as plain text
+// Details of server errors are not exposed to clients
+func WriteText(w http.ResponseWriter, appErr *HTTPError) {
+	if appErr.Code < http.StatusInternalServerError {
+		http.Error(w, appErr.GetFullMessage(), appErr.Code)
+		return
+	}
+
+	message := appErr.Message
+	if message == "" {
+		message = http.StatusText(appErr.Code)
+	}
+
+	http.Error(w, message, appErr.Code)
+}
@@ -0,0 +1,45 @@
+package error
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		name   string
+		appErr *HTTPError
+		body   string
+	}{
+		{
+			name:   "Positive case: client error with details",
+			appErr: NewHTTPError(http.StatusBadRequest, CodeInvalidURL, "Invalid URL was provided", errors.New("empty host")),
+			body:   "Invalid URL was provided: empty host\n",
+		},
+		{
+			name:   "Positive case: server error hides details",
+			appErr: NewHTTPError(http.StatusInternalServerError, CodeInternal, "Failed to shorten URL", errors.New("dial tcp 10.0.0.5:5432: connection refused")),
+			body:   "Failed to shorten URL\n",
+		},
+		{
+			name:   "Positive case: server error without message",
+			appErr: NewHTTPError(http.StatusServiceUnavailable, CodeInternal, "", errors.New("storage is unavailable")),
+			body:   "Service Unavailable\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			WriteText(w, tt.appErr)
+
+			assert.Equal(t, tt.appErr.Code, w.Code)
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
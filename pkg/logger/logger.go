@@ -1,7 +1,12 @@
 package logger
 
 import (
+	"context"
+
 	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/requestid"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/tracing"
 )
 
 // Log is the global application logger
@@ -24,3 +29,18 @@ func Initialize(level string) error {
 	Log = zapLogger
 	return nil
 }
+
+// FromContext returns the global logger annotated with the request ID and the trace of the context
+func FromContext(ctx context.Context) *zap.Logger {
+	fields := make([]zap.Field, 0, 3)
+
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		fields = append(fields, zap.String("request_id", requestID))
+	}
+
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		fields = append(fields, zap.String("trace_id", traceID), zap.String("span_id", tracing.SpanID(ctx)))
+	}
+
+	return Log.With(fields...)
+}
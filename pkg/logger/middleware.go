@@ -52,7 +52,7 @@ func RequestLogger() func(http.Handler) http.Handler {
 			next.ServeHTTP(&lw, r)
 			duration := time.Since(start)
 
-			Log.Info("request completed",
+			FromContext(r.Context()).Info("request completed",
 				zap.Any("request", requestInfo{
 					Method: r.Method,
 					URI:    r.RequestURI,
@@ -0,0 +1,106 @@
+// Package metrics provides Prometheus metrics of the application
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of all application metrics
+const namespace = "shortener"
+
+// Registry holds all application metrics together with Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration observes HTTP request latencies per method and route pattern
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latencies per method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// HTTPRequestsTotal counts HTTP requests per method, route pattern and status code
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests per method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	// RepositoryOperationDuration observes storage operation latencies per backend and operation
+	RepositoryOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_operation_duration_seconds",
+		Help:      "Storage operation latencies per backend and operation.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"backend", "operation"})
+
+	// DeleteWorkerFlushSize observes the number of deletion tasks flushed at once
+	DeleteWorkerFlushSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delete_worker_flush_size",
+		Help:      "Number of URL deletion tasks flushed at once.",
+		Buckets:   []float64{1, 5, 10, 25, 50, 100},
+	})
+
+	// AuditEventsDroppedTotal counts audit events dropped because they could not be queued
+	AuditEventsDroppedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_events_dropped_total",
+		Help:      "Audit events dropped because they could not be queued.",
+	})
+
+	// AuditEventsFailedTotal counts failed audit event delivery attempts per observer
+	AuditEventsFailedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_events_failed_total",
+		Help:      "Failed audit event delivery attempts per observer.",
+	}, []string{"observer"})
+
+	// AuditEventsDeadLetteredTotal counts audit events given up on per observer
+	AuditEventsDeadLetteredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_events_dead_lettered_total",
+		Help:      "Audit events moved to the dead letter file per observer.",
+	}, []string{"observer"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsTotal,
+		RepositoryOperationDuration,
+		DeleteWorkerFlushSize,
+		AuditEventsDroppedTotal,
+		AuditEventsFailedTotal,
+		AuditEventsDeadLetteredTotal,
+	)
+}
+
+// Handler returns the HTTP handler exposing the registry in the Prometheus format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RegisterDeleteWorkerQueueDepth exposes the number of queued URL deletion tasks reported by the function
+func RegisterDeleteWorkerQueueDepth(depth func() int) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "delete_worker_queue_depth",
+		Help:      "Number of queued URL deletion tasks.",
+	}, func() float64 {
+		return float64(depth())
+	}))
+}
+
+// ObserveRepositoryOperation records the latency of a storage operation started at the given time
+func ObserveRepositoryOperation(backend string, operation string, start time.Time) {
+	RepositoryOperationDuration.WithLabelValues(backend, operation).Observe(time.Since(start).Seconds())
+}
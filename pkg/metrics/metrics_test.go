@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(HTTPMiddleware())
+	router.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	for _, path := range []string{"/abc", "/xyz", "/abc/stats"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	ObserveRepositoryOperation("memory", "get_by_short", time.Now())
+	DeleteWorkerFlushSize.Observe(3)
+	RegisterDeleteWorkerQueueDepth(func() int { return 7 })
+	AuditEventsDroppedTotal.Inc()
+	AuditEventsFailedTotal.WithLabelValues("http").Inc()
+	AuditEventsDeadLetteredTotal.WithLabelValues("http").Add(2)
+
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	series := []string{
+		`shortener_http_requests_total{method="GET",route="/{id}",status="307"} 2`,
+		`shortener_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`shortener_http_request_duration_seconds_count{method="GET",route="/{id}"} 2`,
+		`shortener_repository_operation_duration_seconds_count{backend="memory",operation="get_by_short"} 1`,
+		`shortener_delete_worker_flush_size_sum 3`,
+		`shortener_delete_worker_queue_depth 7`,
+		`shortener_audit_events_dropped_total 1`,
+		`shortener_audit_events_failed_total{observer="http"} 1`,
+		`shortener_audit_events_dead_lettered_total{observer="http"} 2`,
+		`go_goroutines`,
+	}
+	for _, s := range series {
+		assert.Contains(t, string(body), s)
+	}
+}
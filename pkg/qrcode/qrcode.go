@@ -0,0 +1,153 @@
+// Package qrcode renders QR codes as PNG or SVG images
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Format is the image format of a rendered QR code
+type Format string
+
+const (
+	// FormatPNG renders the QR code as a PNG image
+	FormatPNG Format = "png"
+	// FormatSVG renders the QR code as an SVG image
+	FormatSVG Format = "svg"
+)
+
+// quietZone is the number of white modules around the code required by the specification
+const quietZone = 4
+
+// ErrSizeTooSmall is returned when the image is too small to fit every module of the code
+var ErrSizeTooSmall = errors.New("image size is too small for the QR code")
+
+// Options configures QR code rendering
+type Options struct {
+	// Format is the image format
+	Format Format
+	// Size is the width and height of the image in pixels
+	Size int
+	// Level is the error correction level
+	Level qr.Level
+}
+
+// ParseFormat parses the image format name
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatPNG, FormatSVG:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unknown image format %q", name)
+}
+
+// ParseLevel parses the error correction level name: L, M, Q or H
+func ParseLevel(name string) (qr.Level, error) {
+	switch strings.ToUpper(name) {
+	case "L":
+		return qr.L, nil
+	case "M":
+		return qr.M, nil
+	case "Q":
+		return qr.Q, nil
+	case "H":
+		return qr.H, nil
+	}
+
+	return 0, fmt.Errorf("unknown error correction level %q", name)
+}
+
+// ContentType returns the media type of the image format
+func (f Format) ContentType() string {
+	if f == FormatSVG {
+		return "image/svg+xml"
+	}
+
+	return "image/png"
+}
+
+// Render encodes the content as a QR code image
+// The output is deterministic for the same content and options
+func Render(content string, options Options) ([]byte, error) {
+	code, err := qr.Encode(content, options.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := code.Size + 2*quietZone
+	if options.Size < modules {
+		return nil, ErrSizeTooSmall
+	}
+
+	switch options.Format {
+	case FormatPNG:
+		return renderPNG(code, options.Size)
+	case FormatSVG:
+		return renderSVG(code, options.Size), nil
+	}
+
+	return nil, fmt.Errorf("unknown image format %q", options.Format)
+}
+
+// renderPNG draws the code with whole pixels per module, centered in the image
+func renderPNG(code *qr.Code, size int) ([]byte, error) {
+	scale := size / (code.Size + 2*quietZone)
+	offset := (size - scale*code.Size) / 2
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+
+			for py := 0; py < scale; py++ {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := 0; px < scale; px++ {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderSVG draws the code as a single path scaled to the image size
+func renderSVG(code *qr.Code, size int) []byte {
+	modules := code.Size + 2*quietZone
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules,
+	)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes()
+}
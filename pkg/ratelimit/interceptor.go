@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+// MethodLimit is the limit of a gRPC method together with the scope of its buckets
+// Methods sharing a scope with HTTP routes share their buckets too
+type MethodLimit struct {
+	Scope string
+	Limit Limit
+}
+
+// UnaryServerInterceptor rejects gRPC unary calls of clients that exceeded the limit of the method
+// with ResourceExhausted and a retry-after header, methods without a limit are not restricted
+// Calls are keyed as KeyByUserOrIP keys requests, by the client IP resolved by subnet.UnaryServerInterceptor
+func (l *Limiter) UnaryServerInterceptor(limits map[string]MethodLimit) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		methodLimit, ok := limits[info.FullMethod]
+		if !ok || !methodLimit.Limit.enabled() {
+			return handler(ctx, req)
+		}
+
+		key := methodLimit.Scope + ":" + keyByUserOrIP(ctx, grpcClientIP(ctx))
+
+		allowed, retryAfter, err := l.store.Take(ctx, key, methodLimit.Limit, l.now())
+		if err != nil {
+			logger.FromContext(ctx).Warn("rate limit store failed", zap.String("scope", methodLimit.Scope), zap.Error(err))
+			return handler(ctx, req)
+		}
+
+		if !allowed {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))))
+			return nil, status.Error(codes.ResourceExhausted, "Too many requests")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// grpcClientIP returns the client IP resolved by subnet.UnaryServerInterceptor or the peer address
+func grpcClientIP(ctx context.Context) string {
+	if ip, ok := subnet.ClientIPFromContext(ctx); ok {
+		return ip
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+
+	return ""
+}
@@ -0,0 +1,61 @@
+// Package ratelimit provides token bucket rate limiting of HTTP requests and gRPC calls
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit defines a token bucket that holds up to Burst tokens and refills at Rate tokens per second
+// A limit with a non-positive rate or burst does not restrict requests
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Every returns a limit that allows count requests per period with bursts of up to count requests
+func Every(count int, period time.Duration) Limit {
+	if count <= 0 || period <= 0 {
+		return Limit{}
+	}
+
+	return Limit{Rate: float64(count) / period.Seconds(), Burst: count}
+}
+
+// ParseLimit parses a limit in the count/period form, e.g. 100/1m or 10/s
+// An empty string or zero count disables the limit
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return Limit{}, nil
+	}
+
+	countValue, periodValue, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected count/period", value)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(countValue))
+	if err != nil || count < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a non-negative integer", value)
+	}
+
+	periodValue = strings.TrimSpace(periodValue)
+	if periodValue != "" && (periodValue[0] < '0' || periodValue[0] > '9') {
+		periodValue = "1" + periodValue
+	}
+
+	period, err := time.ParseDuration(periodValue)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", value)
+	}
+
+	return Every(count, period), nil
+}
+
+// enabled reports whether the limit restricts requests
+func (l Limit) enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
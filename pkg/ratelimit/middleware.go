@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/logger"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+// KeyFunc identifies the client a request is counted against
+type KeyFunc func(r *http.Request) string
+
+// establishedUserAge is how long ago the token of a user must have been issued for the user to get its own bucket
+const establishedUserAge = 24 * time.Hour
+
+// KeyByUserOrIP identifies clients by their user ID or, for requests without
+// an established authorization cookie, by their IP address
+// Anyone can get a new user ID, so fresh ones share the bucket of their IP address,
+// otherwise requesting a new cookie would reset the limit
+func KeyByUserOrIP(r *http.Request) string {
+	return keyByUserOrIP(r.Context(), subnet.ClientIP(r))
+}
+
+// keyByUserOrIP returns the key of the established user in the context or of the client IP
+func keyByUserOrIP(ctx context.Context, clientIP string) string {
+	if userID, ok := authorization.UserIDFromContext(ctx); ok && !authorization.IsNewUser(ctx) {
+		if issuedAt, ok := authorization.IssuedAtFromContext(ctx); ok && time.Since(issuedAt) >= establishedUserAge {
+			return "user:" + userID
+		}
+	}
+
+	return "ip:" + clientIP
+}
+
+// Limiter applies rate limits kept in a store
+type Limiter struct {
+	store   Store
+	keyFunc KeyFunc
+	now     func() time.Time
+}
+
+// NewLimiter creates a new Limiter instance
+func NewLimiter(store Store, keyFunc KeyFunc) *Limiter {
+	return &Limiter{store: store, keyFunc: keyFunc, now: time.Now}
+}
+
+// Middleware rejects requests of clients that exceeded the limit with 429 Too Many Requests
+// The scope separates the buckets of differently limited routes
+// Requests are let through if the store fails, so that its outage does not take the service down
+func (l *Limiter) Middleware(scope string, limit Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !limit.enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := l.store.Take(r.Context(), scope+":"+l.keyFunc(r), limit, l.now())
+			if err != nil {
+				logger.FromContext(r.Context()).Warn("rate limit store failed", zap.String("scope", scope), zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				writeError(w, r, appError.NewHTTPError(http.StatusTooManyRequests, appError.CodeRateLimited, "Too many requests", nil))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeError writes problem details to JSON clients and plain text to the others
+func writeError(w http.ResponseWriter, r *http.Request, appErr *appError.HTTPError) {
+	if strings.Contains(r.Header.Get("Content-Type"), "json") || strings.Contains(r.Header.Get("Accept"), "json") {
+		appError.WriteProblem(w, r, appErr)
+		return
+	}
+
+	appError.WriteText(w, appErr)
+}
@@ -0,0 +1,297 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/authorization"
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+	"github.com/alikhanturusbekov/go-url-shortener/pkg/subnet"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{name: "Positive case: per minute", value: "120/1m", want: Limit{Rate: 2, Burst: 120}},
+		{name: "Positive case: unit without count", value: "10/s", want: Limit{Rate: 10, Burst: 10}},
+		{name: "Positive case: empty disables", value: "", want: Limit{}},
+		{name: "Positive case: zero disables", value: "0", want: Limit{}},
+		{name: "Negative case: missing period", value: "10", wantErr: true},
+		{name: "Negative case: invalid count", value: "ten/1m", wantErr: true},
+		{name: "Negative case: invalid period", value: "10/soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := ParseLimit(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, limit)
+		})
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Every(2, time.Second)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := store.Take(ctx, "client", limit, now)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter, err := store.Take(ctx, "client", limit, now)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	allowed, _, err = store.Take(ctx, "other", limit, now)
+	require.NoError(t, err)
+	assert.True(t, allowed, "buckets of other keys are independent")
+
+	allowed, _, err = store.Take(ctx, "client", limit, now.Add(500*time.Millisecond))
+	require.NoError(t, err)
+	assert.True(t, allowed, "a token is refilled after the retry period")
+
+	store.Take(ctx, "client", limit, now.Add(2*memoryStoreSweepInterval))
+	assert.Len(t, store.buckets, 1, "idle full buckets are removed")
+}
+
+func TestMiddleware(t *testing.T) {
+	type want struct {
+		statusCodes []int
+		retryAfter  string
+		contentType string
+	}
+	tests := []struct {
+		name    string
+		limit   Limit
+		headers map[string]string
+		want    want
+	}{
+		{
+			name:  "Positive case: disabled limit",
+			limit: Limit{},
+			want:  want{statusCodes: []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+		},
+		{
+			name:  "Negative case: plain text client is limited",
+			limit: Every(2, time.Minute),
+			want: want{
+				statusCodes: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+				retryAfter:  "30",
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name:    "Negative case: JSON client gets problem details",
+			limit:   Every(1, time.Hour),
+			headers: map[string]string{"Content-Type": "application/json"},
+			want: want{
+				statusCodes: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests},
+				retryAfter:  "3600",
+				contentType: appError.ProblemContentType,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			limiter := NewLimiter(NewMemoryStore(), KeyByUserOrIP)
+			limiter.now = func() time.Time { return now }
+
+			h := limiter.Middleware("test", tt.limit)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var last *http.Response
+			for _, statusCode := range tt.want.statusCodes {
+				request := httptest.NewRequest(http.MethodGet, "/abc", nil)
+				request.RemoteAddr = "192.0.2.1:1234"
+				for name, value := range tt.headers {
+					request.Header.Set(name, value)
+				}
+
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, request)
+
+				last = w.Result()
+				last.Body.Close()
+				assert.Equal(t, statusCode, last.StatusCode)
+			}
+
+			if tt.want.retryAfter != "" {
+				assert.Equal(t, tt.want.retryAfter, last.Header.Get("Retry-After"))
+				assert.Equal(t, tt.want.contentType, last.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestKeyByUserOrIP(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	request.RemoteAddr = "198.51.100.7:1234"
+	request.Header.Set("X-Real-IP", "203.0.113.1")
+
+	assert.Equal(t, "ip:198.51.100.7", KeyByUserOrIP(request), "proxy headers are not believed without trusted proxies")
+
+	var key string
+	authorization.AuthMiddleware([]byte(testJWTKey))(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		key = KeyByUserOrIP(r)
+	})).ServeHTTP(httptest.NewRecorder(), request)
+
+	assert.Equal(t, "ip:198.51.100.7", key, "user IDs issued for the request are not used as keys")
+
+	request.AddCookie(signedCookie(t, "user-1", time.Now().Add(-48*time.Hour)))
+	authorization.AuthMiddleware([]byte(testJWTKey))(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		key = KeyByUserOrIP(r)
+	})).ServeHTTP(httptest.NewRecorder(), request)
+
+	assert.Equal(t, "user:user-1", key, "established users have their own bucket")
+}
+
+func TestMiddlewareCannotBeBypassed(t *testing.T) {
+	proxies, err := subnet.ParseTrustedProxies("10.0.0.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		proxies    subnet.TrustedProxies
+		remoteAddr string
+		prepare    func(t *testing.T, request *http.Request, i int)
+		want       []int
+	}{
+		{
+			name:       "Negative case: rotated X-Forwarded-For without trusted proxies",
+			remoteAddr: "192.0.2.1:1234",
+			prepare: func(_ *testing.T, request *http.Request, i int) {
+				request.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))
+				request.Header.Set("X-Real-IP", fmt.Sprintf("198.51.100.%d", i))
+			},
+			want: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+		{
+			name:       "Negative case: rotated X-Forwarded-For entries before a trusted proxy",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.1:1234",
+			prepare: func(_ *testing.T, request *http.Request, i int) {
+				request.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d, 192.0.2.1", i))
+			},
+			want: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+		{
+			name:       "Negative case: rotated fresh cookies",
+			remoteAddr: "192.0.2.1:1234",
+			prepare: func(t *testing.T, request *http.Request, _ int) {
+				request.AddCookie(freshCookie(t))
+			},
+			want: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+		{
+			name:       "Positive case: established users behind one address have their own buckets",
+			remoteAddr: "192.0.2.1:1234",
+			prepare: func(t *testing.T, request *http.Request, i int) {
+				request.AddCookie(signedCookie(t, fmt.Sprintf("user-%d", i%2), time.Now().Add(-48*time.Hour)))
+			},
+			want: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewLimiter(NewMemoryStore(), KeyByUserOrIP)
+
+			h := subnet.ClientIPMiddleware(tt.proxies)(
+				authorization.AuthMiddleware([]byte(testJWTKey))(
+					limiter.Middleware("test", Every(2, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+						w.WriteHeader(http.StatusOK)
+					})),
+				),
+			)
+
+			for i, want := range tt.want {
+				request := httptest.NewRequest(http.MethodPost, "/", nil)
+				request.RemoteAddr = tt.remoteAddr
+				tt.prepare(t, request, i)
+
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, request)
+
+				assert.Equal(t, want, w.Code, "request %d", i)
+			}
+		})
+	}
+}
+
+// testJWTKey signs the authorization cookies of the tests
+const testJWTKey = "test_key"
+
+// freshCookie returns the authorization cookie handed out to a new user, e.g. by /ping
+func freshCookie(t *testing.T) *http.Cookie {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	authorization.AuthMiddleware([]byte(testJWTKey))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	return cookies[0]
+}
+
+// signedCookie returns an authorization cookie of the user issued at the time
+func signedCookie(t *testing.T, userID string, issuedAt time.Time) *http.Cookie {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &authorization.Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+
+	signed, err := token.SignedString([]byte(testJWTKey))
+	require.NoError(t, err)
+
+	return &http.Cookie{Name: "auth_token", Value: signed}
+}
+
+// failingStore is a Store whose every call fails
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (bool, time.Duration, error) {
+	return false, 0, assert.AnError
+}
+
+func TestMiddlewareStoreFailure(t *testing.T) {
+	h := NewLimiter(failingStore{}, KeyByUserOrIP).Middleware("test", Every(1, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
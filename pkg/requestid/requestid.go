@@ -0,0 +1,64 @@
+// Package requestid provides generation and propagation of request IDs
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header is the HTTP header carrying the request ID
+const Header = "X-Request-ID"
+
+// maxLength limits the length of request IDs accepted from clients
+const maxLength = 128
+
+// contextKey is the context key of the request ID
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the request ID
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// FromContext returns the request ID stored in the context, empty if there is none
+func FromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// Middleware reuses the request ID sent by the client or generates a new one
+// The request ID is stored in the request context, echoed in the response and attached to the current span
+func Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(Header)
+			if !isValid(requestID) {
+				requestID = uuid.NewString()
+			}
+
+			w.Header().Set(Header, requestID)
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", requestID))
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), requestID)))
+		})
+	}
+}
+
+// isValid reports whether a client request ID is short and consists of printable ASCII characters
+func isValid(requestID string) bool {
+	if requestID == "" || len(requestID) > maxLength {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,40 @@
+package subnet
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Metadata keys carrying the client address set by the proxy
+const (
+	realIPMetadataKey       = "x-real-ip"
+	forwardedForMetadataKey = "x-forwarded-for"
+)
+
+// UnaryServerInterceptor resolves the client IP of every gRPC unary call for ClientIPFromContext
+// It mirrors ClientIPMiddleware: proxy metadata is believed only on calls coming from the trusted proxies
+func UnaryServerInterceptor(proxies TrustedProxies) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var remoteAddr string
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			remoteAddr = p.Addr.String()
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		ip := proxies.Resolve(remoteAddr, firstValue(md, realIPMetadataKey), firstValue(md, forwardedForMetadataKey))
+
+		return handler(WithClientIP(ctx, ip), req)
+	}
+}
+
+// firstValue returns the first metadata value of the key, empty if there is none
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,81 @@
+package subnet
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		proxies TrustedProxies
+		peer    net.Addr
+		md      metadata.MD
+		want    string
+	}{
+		{
+			name: "Positive case: metadata is ignored without trusted proxies",
+			peer: &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 1234},
+			md:   metadata.Pairs("x-real-ip", "203.0.113.1"),
+			want: "198.51.100.7",
+		},
+		{
+			name:    "Positive case: metadata of untrusted peers is ignored",
+			proxies: proxies,
+			peer:    &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 1234},
+			md:      metadata.Pairs("x-real-ip", "203.0.113.1", "x-forwarded-for", "203.0.113.2"),
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "Positive case: x-real-ip of a trusted proxy",
+			proxies: proxies,
+			peer:    &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1234},
+			md:      metadata.Pairs("x-real-ip", "203.0.113.1"),
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "Positive case: x-forwarded-for of a trusted proxy",
+			proxies: proxies,
+			peer:    &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1234},
+			md:      metadata.Pairs("x-forwarded-for", "1.1.1.1, 203.0.113.2, 10.0.0.3"),
+			want:    "203.0.113.2",
+		},
+		{
+			name: "Negative case: unknown peer",
+			md:   metadata.Pairs("x-real-ip", "203.0.113.1"),
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			if tt.peer != nil {
+				ctx = peer.NewContext(ctx, &peer.Peer{Addr: tt.peer})
+			}
+
+			var (
+				got string
+				ok  bool
+			)
+			_, err := UnaryServerInterceptor(tt.proxies)(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+				got, ok = ClientIPFromContext(ctx)
+				return nil, nil
+			})
+			require.NoError(t, err)
+
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package subnet provides access restriction by client subnet
+package subnet
+
+import (
+	"net"
+	"net/http"
+
+	appError "github.com/alikhanturusbekov/go-url-shortener/pkg/error"
+)
+
+// errForbidden is returned to clients outside of the trusted subnet
+var errForbidden = appError.NewHTTPError(http.StatusForbidden, appError.CodeForbidden, "Access is forbidden", nil)
+
+// ParseTrustedSubnet parses the trusted subnet in CIDR notation
+// Returns nil if the subnet is not configured
+func ParseTrustedSubnet(cidr string) (*net.IPNet, error) {
+	if cidr == "" {
+		return nil, nil
+	}
+
+	_, trusted, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	return trusted, nil
+}
+
+// ClientIP returns the client IP address resolved by ClientIPMiddleware
+// Without the middleware the remote address is used, proxy headers are never believed
+func ClientIP(r *http.Request) string {
+	if ip, ok := ClientIPFromContext(r.Context()); ok {
+		return ip
+	}
+
+	return hostOf(r.RemoteAddr)
+}
+
+// TrustedSubnetMiddleware allows only requests whose client IP belongs to the trusted subnet
+// The client IP is the one resolved by ClientIPMiddleware, so proxy headers are believed only from the trusted proxies
+// All requests are forbidden if the trusted subnet is not configured
+func TrustedSubnetMiddleware(trusted *net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if trusted == nil {
+				appError.WriteProblem(w, r, errForbidden)
+				return
+			}
+
+			ip := net.ParseIP(ClientIP(r))
+			if ip == nil || !trusted.Contains(ip) {
+				appError.WriteProblem(w, r, errForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package subnet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedSubnetMiddleware(t *testing.T) {
+	trusted, err := ParseTrustedSubnet("192.168.1.0/24")
+	require.NoError(t, err)
+	proxies, err := ParseTrustedProxies("10.0.0.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		want       int
+	}{
+		{
+			name:       "Positive case: direct client inside the trusted subnet",
+			remoteAddr: "192.168.1.15:1234",
+			want:       http.StatusOK,
+		},
+		{
+			name:       "Positive case: client inside the trusted subnet behind a trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			realIP:     "192.168.1.15",
+			want:       http.StatusOK,
+		},
+		{
+			name:       "Negative case: direct client spoofing X-Real-IP",
+			remoteAddr: "198.51.100.7:1234",
+			realIP:     "192.168.1.15",
+			want:       http.StatusForbidden,
+		},
+		{
+			name:       "Negative case: client outside the trusted subnet behind a trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			realIP:     "203.0.113.1",
+			want:       http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ClientIPMiddleware(proxies)(TrustedSubnetMiddleware(trusted)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			request := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			request.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				request.Header.Set(realIPHeader, tt.realIP)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
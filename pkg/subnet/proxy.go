@@ -0,0 +1,130 @@
+package subnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// realIPHeader is the header carrying the client IP address set by the proxy
+const realIPHeader = "X-Real-IP"
+
+// forwardedForHeader is the header listing the addresses a request was forwarded for
+const forwardedForHeader = "X-Forwarded-For"
+
+// TrustedProxies is the set of reverse proxies whose client address headers are believed
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses comma separated addresses or subnets in CIDR notation
+// Returns nil if no proxy is configured, client address headers are then ignored
+func ParseTrustedProxies(cidrs string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+
+	for value := range strings.SplitSeq(cidrs, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+// Contains reports whether the address belongs to a trusted proxy
+func (p TrustedProxies) Contains(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve returns the client IP of a connection from the peer address and the proxy headers
+// The headers are believed only when the peer is a trusted proxy: X-Real-IP is taken as is
+// and X-Forwarded-For is walked from the right, skipping the trusted proxies that appended to it
+func (p TrustedProxies) Resolve(remoteAddr string, realIP string, forwardedFor string) string {
+	peer := hostOf(remoteAddr)
+	if !p.Contains(peer) {
+		return peer
+	}
+
+	if ip := strings.TrimSpace(realIP); ip != "" {
+		return ip
+	}
+
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !p.Contains(hop) {
+			return hop
+		}
+		peer = hop
+	}
+
+	return peer
+}
+
+// clientIPKey is the context key of the resolved client IP
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of the context carrying the client IP
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext returns the client IP stored in the context
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(string)
+
+	return ip, ok
+}
+
+// ClientIPMiddleware resolves the client IP of every request for ClientIP
+// Proxy headers are believed only on requests coming from the trusted proxies
+func ClientIPMiddleware(proxies TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := proxies.Resolve(r.RemoteAddr, r.Header.Get(realIPHeader), r.Header.Get(forwardedForHeader))
+			next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), ip)))
+		})
+	}
+}
+
+// hostOf returns the host of an address with or without a port
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
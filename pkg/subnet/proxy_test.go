@@ -0,0 +1,84 @@
+package subnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedProxiesResolve(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		proxies      TrustedProxies
+		remoteAddr   string
+		realIP       string
+		forwardedFor string
+		want         string
+	}{
+		{
+			name:         "Positive case: headers are ignored without trusted proxies",
+			remoteAddr:   "198.51.100.7:1234",
+			realIP:       "203.0.113.1",
+			forwardedFor: "203.0.113.2",
+			want:         "198.51.100.7",
+		},
+		{
+			name:       "Positive case: headers of untrusted peers are ignored",
+			proxies:    proxies,
+			remoteAddr: "198.51.100.7:1234",
+			realIP:     "203.0.113.1",
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "Positive case: X-Real-IP of a trusted proxy",
+			proxies:    proxies,
+			remoteAddr: "192.0.2.1:1234",
+			realIP:     "203.0.113.1",
+			want:       "203.0.113.1",
+		},
+		{
+			name:         "Positive case: X-Forwarded-For is walked from the right past trusted proxies",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.2:1234",
+			forwardedFor: "1.1.1.1, 203.0.113.1, 10.0.0.3",
+			want:         "203.0.113.1",
+		},
+		{
+			name:         "Positive case: chain of trusted proxies only",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.2:1234",
+			forwardedFor: "10.0.0.4, 10.0.0.3",
+			want:         "10.0.0.4",
+		},
+		{
+			name:       "Positive case: remote address without port",
+			remoteAddr: "198.51.100.7",
+			want:       "198.51.100.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.proxies.Resolve(tt.remoteAddr, tt.realIP, tt.forwardedFor))
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("")
+	require.NoError(t, err)
+	assert.Nil(t, proxies)
+
+	proxies, err = ParseTrustedProxies("::ffff:192.0.2.1, 2001:db8::/32")
+	require.NoError(t, err)
+	assert.True(t, proxies.Contains("192.0.2.1"))
+	assert.True(t, proxies.Contains("2001:db8::1"))
+	assert.False(t, proxies.Contains("192.0.2.2"))
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	assert.Error(t, err)
+}